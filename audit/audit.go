@@ -0,0 +1,351 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+// Package audit keeps a tamper-evident trail of the payment instructions sent
+// with base.Client and the callbacks acknowledged with base.Replier.
+//
+// Every Record holds the hash of the previous one, so removing, reordering or
+// editing a record breaks the chain and is detected by Verify (and by the
+// auditverify command).
+//
+//	sink, err := audit.NewFileSink("/var/lib/app/audit.jsonl")
+//	auditor, err := audit.New(sink, audit.WithNames("c2b", "disbursement"), audit.WithMSISDNKey(key))
+//	client := base.NewClient(base.WithExchangeHook(auditor.Record))
+//	receiver := base.NewReceiver(os.Stderr, false, base.ExchangeHookOption(auditor.Record))
+//	replier := base.NewReplier(os.Stderr, false, base.ExchangeHookOption(auditor.Record))
+package audit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/techcraftlabs/base"
+)
+
+const (
+	KindInstruction = "instruction"
+	KindCallback    = "callback"
+)
+
+const defaultReplyTimeout = time.Minute
+
+type (
+	// Record is an entry of the audit trail. Hash is the hex encoded SHA-256 of
+	// PrevHash followed by the JSON encoding of the record without Hash.
+	Record struct {
+		Seq        uint64    `json:"seq"`
+		Time       time.Time `json:"time"`
+		Kind       string    `json:"kind"`
+		Name       string    `json:"name"`
+		RequestID  string    `json:"request_id,omitempty"`
+		Actor      string    `json:"actor,omitempty"`
+		MSISDNHash string    `json:"msisdn_hash,omitempty"`
+		Amount     string    `json:"amount,omitempty"`
+		Status     int       `json:"status"`
+		Error      string    `json:"error,omitempty"`
+		Started    time.Time `json:"started"`
+		Finished   time.Time `json:"finished"`
+		PrevHash   string    `json:"prev_hash"`
+		Hash       string    `json:"hash,omitempty"`
+	}
+
+	Option func(a *Auditor)
+
+	// Auditor turns base.Exchange values into chained Records and appends
+	// them to a Sink
+	Auditor struct {
+		mu        sync.Mutex
+		sink      Sink
+		last      *Record
+		names     map[string]bool
+		extractor Extractor
+		msisdnKey []byte
+		actor     string
+		onError   func(err error)
+		err       error
+		pending   map[string]*Record
+		timeout   time.Duration
+		now       func() time.Time
+	}
+
+	actorKey struct{}
+)
+
+// ComputeHash returns the hash of the record given the hash of the previous one
+func (r Record) ComputeHash() string {
+	r.Hash = ""
+	b, _ := json.Marshal(r)
+	sum := sha256.Sum256(append([]byte(r.PrevHash), b...))
+	return hex.EncodeToString(sum[:])
+}
+
+// WithActor returns a copy of ctx that carries the actor (user, service or
+// job) on whose behalf requests are made
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// WithNames restricts the trail to the requests with the given names, all
+// exchanges are recorded by default
+func WithNames(names ...string) Option {
+	return func(a *Auditor) {
+		a.names = make(map[string]bool)
+		for _, name := range names {
+			a.names[strings.ToLower(name)] = true
+		}
+	}
+}
+
+// WithExtractor sets the Extractor of the MSISDN and the amount,
+// FieldExtractor with the default field names is used by default
+func WithExtractor(extractor Extractor) Option {
+	return func(a *Auditor) {
+		if extractor != nil {
+			a.extractor = extractor
+		}
+	}
+}
+
+// WithMSISDNKey makes MSISDN hashes HMAC-SHA256 with key instead of plain
+// SHA-256, which can be reversed by hashing every possible number
+func WithMSISDNKey(key []byte) Option {
+	return func(a *Auditor) {
+		a.msisdnKey = key
+	}
+}
+
+// WithDefaultActor sets the actor of the exchanges whose context has none
+func WithDefaultActor(actor string) Option {
+	return func(a *Auditor) {
+		a.actor = actor
+	}
+}
+
+// WithErrorHandler sets the func called when a record can not be appended
+// to the sink, without one the first error is returned by Close
+func WithErrorHandler(fn func(err error)) Option {
+	return func(a *Auditor) {
+		a.onError = fn
+	}
+}
+
+// WithReplyTimeout sets how long a received callback waits for its
+// acknowledgement before it is recorded with a zero status, default is
+// a minute
+func WithReplyTimeout(timeout time.Duration) Option {
+	return func(a *Auditor) {
+		if timeout > 0 {
+			a.timeout = timeout
+		}
+	}
+}
+
+// New returns an Auditor that continues the chain of the records in sink
+func New(sink Sink, opts ...Option) (*Auditor, error) {
+	last, err := sink.Last()
+	if err != nil {
+		return nil, fmt.Errorf("audit: could not read the last record: %w", err)
+	}
+
+	a := &Auditor{
+		sink:      sink,
+		last:      last,
+		extractor: FieldExtractor(DefaultMSISDNFields, DefaultAmountFields),
+		pending:   make(map[string]*Record),
+		timeout:   defaultReplyTimeout,
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a, nil
+}
+
+// Record is a base.ExchangeHook. Requests sent by a Client are recorded right
+// away, a callback read by a Receiver is recorded when the Replier acknowledges
+// it (matched by request ID, see base.WithResponseRequestID) or, with a zero
+// status, when it is not acknowledged within the reply timeout.
+func (a *Auditor) Record(exchange *base.Exchange) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.expire(a.now())
+
+	switch exchange.Kind {
+	case base.ExchangeOutgoing:
+		if !a.audited(exchange.Name) {
+			return
+		}
+		record := a.record(KindInstruction, exchange)
+		if exchange.Response != nil {
+			record.Status = exchange.Response.StatusCode
+		}
+		a.append(record)
+
+	case base.ExchangeReceived:
+		if !a.audited(exchange.Name) {
+			return
+		}
+		record := a.record(KindCallback, exchange)
+		if exchange.RequestID == "" || exchange.Err != nil {
+			// there is no way to match the acknowledgement
+			a.append(record)
+			return
+		}
+		if displaced, ok := a.pending[exchange.RequestID]; ok {
+			// a retried callback with the same request ID, the first one is
+			// recorded unacknowledged instead of being lost
+			if displaced.Error == "" {
+				displaced.Error = "not acknowledged: a callback with the same request id was received"
+			}
+			a.append(displaced)
+		}
+		a.pending[exchange.RequestID] = record
+
+	case base.ExchangeReplied:
+		record, ok := a.pending[exchange.RequestID]
+		if !ok {
+			return
+		}
+		delete(a.pending, exchange.RequestID)
+		if exchange.Response != nil {
+			record.Status = exchange.Response.StatusCode
+		}
+		if exchange.Err != nil {
+			record.Error = exchange.Err.Error()
+		}
+		record.Finished = exchange.Started.Add(exchange.Duration).UTC()
+		a.append(record)
+	}
+}
+
+// expire records the callbacks that waited too long for their acknowledgement,
+// in the order they were received
+func (a *Auditor) expire(now time.Time) {
+	var expired []*Record
+	for id, record := range a.pending {
+		if now.Sub(record.Started) >= a.timeout {
+			delete(a.pending, id)
+			expired = append(expired, record)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool {
+		if expired[i].Started.Equal(expired[j].Started) {
+			return expired[i].RequestID < expired[j].RequestID
+		}
+		return expired[i].Started.Before(expired[j].Started)
+	})
+	for _, record := range expired {
+		a.append(record)
+	}
+}
+
+func (a *Auditor) audited(name string) bool {
+	return a.names == nil || a.names[strings.ToLower(name)]
+}
+
+func (a *Auditor) record(kind string, exchange *base.Exchange) *Record {
+	record := &Record{
+		Kind:      kind,
+		Name:      exchange.Name,
+		RequestID: exchange.RequestID,
+		Actor:     a.actor,
+		Started:   exchange.Started.UTC(),
+		Finished:  exchange.Started.Add(exchange.Duration).UTC(),
+	}
+	if exchange.Err != nil {
+		record.Error = exchange.Err.Error()
+	}
+	if exchange.Request != nil {
+		if actor := ActorFromContext(exchange.Request.Context()); actor != "" {
+			record.Actor = actor
+		}
+	}
+
+	details := a.extractor(exchange)
+	if details.MSISDN != "" {
+		record.MSISDNHash = a.hashMSISDN(details.MSISDN)
+	}
+	record.Amount = details.Amount
+	return record
+}
+
+func (a *Auditor) hashMSISDN(msisdn string) string {
+	msisdn = strings.TrimPrefix(strings.TrimSpace(msisdn), "+")
+	if a.msisdnKey == nil {
+		sum := sha256.Sum256([]byte(msisdn))
+		return hex.EncodeToString(sum[:])
+	}
+	mac := hmac.New(sha256.New, a.msisdnKey)
+	mac.Write([]byte(msisdn))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (a *Auditor) append(record *Record) {
+	record.Time = a.now().UTC()
+	if a.last != nil {
+		record.Seq = a.last.Seq + 1
+		record.PrevHash = a.last.Hash
+	}
+	record.Hash = record.ComputeHash()
+
+	if err := a.sink.Append(record); err != nil {
+		err = fmt.Errorf("audit: could not append record %d: %w", record.Seq, err)
+		switch {
+		case a.onError != nil:
+			a.onError(err)
+		case a.err == nil:
+			a.err = err
+		}
+		return
+	}
+	a.last = record
+}
+
+// Close records the callbacks that have not been acknowledged, with a zero
+// status, and closes the sink. It returns the first error of the sink when
+// there is no error handler.
+func (a *Auditor) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.expire(a.now().Add(a.timeout))
+	if err := a.sink.Close(); err != nil {
+		return err
+	}
+	return a.err
+}
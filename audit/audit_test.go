@@ -0,0 +1,229 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/techcraftlabs/base"
+)
+
+func TestAuditor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	sink, err := NewFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auditor, err := New(sink, WithNames("c2b", "callback"), WithDefaultActor("system"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	receiver := base.NewReceiver(io.Discard, false, base.ExchangeHookOption(auditor.Record))
+	replier := base.NewReplier(io.Discard, false, base.ExchangeHookOption(auditor.Record))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receipt, _ := receiver.Receive(r.Context(), "callback", r, nil)
+		replier.Reply(w, base.NewResponse(http.StatusAccepted, nil, base.WithResponseRequestID(receipt.RequestID)))
+	}))
+	defer server.Close()
+
+	client := base.NewClient(base.WithDebugMode(false), base.WithExchangeHook(auditor.Record))
+	ctx := WithActor(context.Background(), "teller-7")
+	for _, name := range []string{"c2b", "balance"} {
+		payload := map[string]interface{}{"input_CustomerMSISDN": "255754000000", "input_Amount": 15000000}
+		if _, err := client.Do(ctx, base.NewRequest(name, http.MethodPost, server.URL, payload), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := auditor.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// c2b, the callback of c2b and the callback of balance
+	if n, err := Verify(bytes.NewReader(b)); err != nil || n != 3 {
+		t.Fatalf("Verify() = %d, %v, want 3 records", n, err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	for _, want := range []string{`"actor":"teller-7"`, `"amount":"15000000"`, `"status":202`} {
+		if !strings.Contains(lines[0]+lines[1], want) {
+			t.Errorf("records do not contain %s:\n%s", want, b)
+		}
+	}
+	if strings.Contains(string(b), "255754000000") {
+		t.Errorf("records contain the MSISDN in clear")
+	}
+
+	tests := []struct {
+		name   string
+		lines  []string
+		reason string
+	}{
+		{"edited", []string{lines[0], strings.Replace(lines[1], "202", "200", 1), lines[2]}, "hash"},
+		{"removed", []string{lines[0], lines[2]}, "seq"},
+		{"reordered", []string{lines[1], lines[0], lines[2]}, "first record"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Verify(strings.NewReader(strings.Join(tt.lines, "\n")))
+			var chainErr *ChainError
+			if !errors.As(err, &chainErr) || !strings.Contains(chainErr.Reason, tt.reason) {
+				t.Errorf("Verify() error = %v, want reason containing %q", err, tt.reason)
+			}
+		})
+	}
+
+	// reopening continues the chain
+	sink, err = NewFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last, _ := sink.Last(); last == nil || last.Seq != 2 {
+		t.Errorf("Last() = %+v, want seq 2", last)
+	}
+	_ = sink.Close()
+}
+
+// memorySink keeps the records in memory and fails the appends after failAt
+type memorySink struct {
+	records []*Record
+	failAt  int
+}
+
+func (s *memorySink) Append(record *Record) error {
+	if s.failAt > 0 && len(s.records) >= s.failAt {
+		return errors.New("disk full")
+	}
+	s.records = append(s.records, record)
+	return nil
+}
+
+func (s *memorySink) Last() (*Record, error) { return nil, nil }
+
+func (s *memorySink) Close() error { return nil }
+
+func TestAuditor_ReplyTimeout(t *testing.T) {
+	sink := &memorySink{}
+	auditor, err := New(sink, WithReplyTimeout(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	now := started
+	auditor.now = func() time.Time { return now }
+
+	// the reply of cb-1 has no request ID so it never matches
+	for _, id := range []string{"cb-2", "cb-1"} {
+		auditor.Record(&base.Exchange{Kind: base.ExchangeReceived, Name: "callback", RequestID: id, Started: started})
+	}
+	auditor.Record(&base.Exchange{Kind: base.ExchangeReplied, Started: started})
+	if len(sink.records) != 0 {
+		t.Fatalf("got %d records before the timeout, want 0", len(sink.records))
+	}
+
+	now = started.Add(time.Minute)
+	auditor.Record(&base.Exchange{Kind: base.ExchangeOutgoing, Name: "c2b", Started: now})
+	if len(sink.records) != 3 || len(auditor.pending) != 0 {
+		t.Fatalf("got %d records and %d pending after the timeout, want 3 and 0", len(sink.records), len(auditor.pending))
+	}
+	for i, want := range []string{"cb-1", "cb-2"} {
+		if record := sink.records[i]; record.RequestID != want || record.Status != 0 {
+			t.Errorf("record %d = %s with status %d, want %s with status 0", i, record.RequestID, record.Status, want)
+		}
+	}
+}
+
+func TestAuditor_SinkErrors(t *testing.T) {
+	exchange := &base.Exchange{Kind: base.ExchangeOutgoing, Name: "c2b", Started: time.Now()}
+
+	sink := &memorySink{failAt: 1}
+	auditor, err := New(sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		auditor.Record(exchange)
+	}
+	if err := auditor.Close(); err == nil || !strings.Contains(err.Error(), "could not append record 1: disk full") {
+		t.Errorf("Close() error = %v, want the first append error", err)
+	}
+
+	var reported []error
+	auditor, err = New(&memorySink{failAt: 1}, WithErrorHandler(func(err error) {
+		reported = append(reported, err)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		auditor.Record(exchange)
+	}
+	if err := auditor.Close(); err != nil || len(reported) != 2 {
+		t.Errorf("Close() error = %v with %d reported errors, want nil and 2", err, len(reported))
+	}
+}
+
+func TestAuditor_RetriedCallback(t *testing.T) {
+	sink := &memorySink{}
+	auditor, err := New(sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := time.Now()
+
+	// the MNO retries the callback with the same request ID before it is acknowledged
+	for i := 0; i < 2; i++ {
+		auditor.Record(&base.Exchange{Kind: base.ExchangeReceived, Name: "callback", RequestID: "cb-1",
+			Started: started.Add(time.Duration(i) * time.Second)})
+	}
+	auditor.Record(&base.Exchange{Kind: base.ExchangeReplied, RequestID: "cb-1", Started: started.Add(2 * time.Second),
+		Response: &http.Response{StatusCode: http.StatusOK}})
+
+	if len(sink.records) != 2 {
+		t.Fatalf("got %d records, want 2", len(sink.records))
+	}
+	first, second := sink.records[0], sink.records[1]
+	if first.Status != 0 || first.Error == "" || !first.Started.Equal(started.UTC()) {
+		t.Errorf("first callback = %+v, want it unacknowledged", first)
+	}
+	if second.Status != http.StatusOK || second.RequestID != "cb-1" {
+		t.Errorf("second callback = %+v, want it acknowledged with 200", second)
+	}
+}
@@ -0,0 +1,123 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/techcraftlabs/base"
+)
+
+var (
+	DefaultMSISDNFields = []string{
+		"msisdn", "customerMSISDN", "input_CustomerMSISDN", "phone", "phoneNumber", "mobileNumber",
+	}
+	DefaultAmountFields = []string{
+		"amount", "input_Amount", "txnAmount", "transactionAmount",
+	}
+)
+
+type (
+	// Details are the fields of a request body that go into a Record
+	Details struct {
+		MSISDN string
+		Amount string
+	}
+
+	// Extractor returns the Details of an exchange, empty fields are left out
+	// of the Record
+	Extractor func(exchange *base.Exchange) Details
+)
+
+// FieldExtractor looks for the first JSON field or XML element named like one
+// of msisdnFields and amountFields (case insensitive) in the request body
+func FieldExtractor(msisdnFields, amountFields []string) Extractor {
+	return func(exchange *base.Exchange) Details {
+		body := exchange.RequestBody
+		if len(body) == 0 {
+			return Details{}
+		}
+
+		// UseNumber keeps amounts as they were written
+		var v interface{}
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if err := decoder.Decode(&v); err == nil {
+			return Details{
+				MSISDN: jsonField(v, msisdnFields),
+				Amount: jsonField(v, amountFields),
+			}
+		}
+		return Details{
+			MSISDN: xmlField(body, msisdnFields),
+			Amount: xmlField(body, amountFields),
+		}
+	}
+}
+
+func jsonField(v interface{}, names []string) string {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for _, name := range names {
+			for key, field := range value {
+				if !strings.EqualFold(key, name) {
+					continue
+				}
+				switch field.(type) {
+				case string, json.Number:
+					return fmt.Sprint(field)
+				}
+			}
+		}
+		for _, field := range value {
+			if found := jsonField(field, names); found != "" {
+				return found
+			}
+		}
+	case []interface{}:
+		for _, item := range value {
+			if found := jsonField(item, names); found != "" {
+				return found
+			}
+		}
+	}
+	return ""
+}
+
+func xmlField(body []byte, names []string) string {
+	for _, name := range names {
+		re := regexp.MustCompile(fmt.Sprintf(`(?is)<(?:[\w-]+:)?%[1]s(?:\s[^>]*)?>\s*([^<]*?)\s*</(?:[\w-]+:)?%[1]s>`,
+			regexp.QuoteMeta(name)))
+		if match := re.FindSubmatch(body); match != nil {
+			return string(match[1])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,168 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+var (
+	_ Sink = (*FileSink)(nil)
+)
+
+// ErrBrokenChain is wrapped by the errors of Verify
+var ErrBrokenChain = errors.New("audit: broken chain")
+
+type (
+	// Sink stores the records. Append must not return before the record is
+	// stored, Last returns the last stored record or nil when there is none
+	Sink interface {
+		Append(record *Record) error
+		Last() (*Record, error)
+		Close() error
+	}
+
+	// FileSink appends the records to a file as JSON lines
+	FileSink struct {
+		mu   sync.Mutex
+		file *os.File
+		last *Record
+	}
+
+	// ChainError tells which record breaks the chain
+	ChainError struct {
+		Line   int
+		Seq    uint64
+		Reason string
+	}
+)
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("%v: line %d (seq %d): %s", ErrBrokenChain, e.Line, e.Seq, e.Reason)
+}
+
+func (e *ChainError) Unwrap() error {
+	return ErrBrokenChain
+}
+
+// NewFileSink opens (or creates) the file at path in append only mode. The
+// existing records are verified so that a broken chain is not extended.
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+
+	last, _, err := verify(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	return &FileSink{file: file, last: last}, nil
+}
+
+func (s *FileSink) Append(record *Record) error {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+	s.last = record
+	return nil
+}
+
+func (s *FileSink) Last() (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last, nil
+}
+
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// Verify reads JSON lines records from reader and checks that every record
+// has the right hash, follows the previous one and holds its hash. It returns
+// the number of records, errors that break the chain are *ChainError.
+func Verify(reader io.Reader) (int, error) {
+	_, n, err := verify(reader)
+	return n, err
+}
+
+func verify(reader io.Reader) (*Record, int, error) {
+	var (
+		last    *Record
+		n, line int
+		scanner = bufio.NewScanner(reader)
+	)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		record := new(Record)
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			return nil, n, &ChainError{Line: line, Reason: fmt.Sprintf("invalid record: %v", err)}
+		}
+
+		switch {
+		case record.Hash != record.ComputeHash():
+			return nil, n, &ChainError{Line: line, Seq: record.Seq, Reason: "hash does not match the content"}
+		case last == nil && (record.Seq != 0 || record.PrevHash != ""):
+			return nil, n, &ChainError{Line: line, Seq: record.Seq, Reason: "first record does not start the chain"}
+		case last != nil && record.Seq != last.Seq+1:
+			return nil, n, &ChainError{Line: line, Seq: record.Seq, Reason: fmt.Sprintf("expected seq %d", last.Seq+1)}
+		case last != nil && record.PrevHash != last.Hash:
+			return nil, n, &ChainError{Line: line, Seq: record.Seq, Reason: "previous hash does not match"}
+		}
+
+		last = record
+		n++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, n, fmt.Errorf("audit: %w", err)
+	}
+	return last, n, nil
+}
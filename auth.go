@@ -0,0 +1,135 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package base
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/techcraftlabs/base/crypto"
+)
+
+// DefaultAssertionTTL is the lifetime of the assertions of JWTAssertion
+const DefaultAssertionTTL = 5 * time.Minute
+
+var ErrUnauthorized = errors.New("unauthorized")
+
+var (
+	_ Authenticator = AuthenticatorFunc(nil)
+	_ Authenticator = (*JWTAuthenticator)(nil)
+)
+
+type (
+	// Authenticator checks the credentials captured in a Receipt, a Receiver
+	// with an Authenticator returns an *AuthError when it fails
+	Authenticator interface {
+		Authenticate(receipt *Receipt) error
+	}
+
+	AuthenticatorFunc func(receipt *Receipt) error
+
+	// AuthError is returned by Receiver.Receive when the Authenticator
+	// rejects the request, errors.Is(err, ErrUnauthorized) is true
+	AuthError struct {
+		Err error
+	}
+
+	// JWTAuthenticator verifies the bearer JWT of a Receipt and sets its Claims
+	JWTAuthenticator struct {
+		Keys    crypto.KeySource
+		Options crypto.ValidationOptions
+	}
+)
+
+func (f AuthenticatorFunc) Authenticate(receipt *Receipt) error {
+	return f(receipt)
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrUnauthorized, e.Err)
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+func (e *AuthError) Is(target error) bool {
+	return target == ErrUnauthorized
+}
+
+// NewJWTAuthenticator returns a JWTAuthenticator that verifies the bearer
+// tokens with the keys of keys, e.g. a *crypto.JWKS or a crypto.StaticKey
+func NewJWTAuthenticator(keys crypto.KeySource, opts crypto.ValidationOptions) *JWTAuthenticator {
+	return &JWTAuthenticator{
+		Keys:    keys,
+		Options: opts,
+	}
+}
+
+func (a *JWTAuthenticator) Authenticate(receipt *Receipt) error {
+	if receipt.BearerToken == "" {
+		return errors.New("missing bearer token")
+	}
+	claims, err := crypto.ParseJWT(receipt.BearerToken, a.Keys, a.Options)
+	if err != nil {
+		return err
+	}
+	receipt.Claims = claims
+	return nil
+}
+
+// JWTAssertion returns a RequestModifier that sets the Authorization header
+// to a bearer JWT signed with key. Every request gets a new token with the
+// claims, iat, exp (ttl later, DefaultAssertionTTL when ttl <= 0) and a
+// random jti. The audience defaults to the URL of the request without query.
+func JWTAssertion(alg crypto.Algorithm, kid string, claims crypto.Claims, key interface{}, ttl time.Duration) RequestModifier {
+	if ttl <= 0 {
+		ttl = DefaultAssertionTTL
+	}
+	return func(request *http.Request) error {
+		c := claims
+		now := time.Now()
+		c.IssuedAt = now
+		c.ExpiresAt = now.Add(ttl)
+		if c.ID == "" {
+			c.ID = NewRequestID()
+		}
+		if len(c.Audience) == 0 {
+			u := *request.URL
+			u.RawQuery, u.Fragment = "", ""
+			c.Audience = crypto.Audience{u.String()}
+		}
+
+		token, err := crypto.IssueJWT(alg, kid, c, key)
+		if err != nil {
+			return fmt.Errorf("jwt assertion: %w", err)
+		}
+		request.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+}
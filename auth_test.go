@@ -0,0 +1,94 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package base
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/techcraftlabs/base/crypto"
+)
+
+func TestJWTAuthenticator(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	authenticator := NewJWTAuthenticator(crypto.StaticKey{Key: secret}, crypto.ValidationOptions{
+		Issuer:     "merchant",
+		Algorithms: []crypto.Algorithm{crypto.HS256},
+	})
+	receiver := NewReceiver(io.Discard, false, AuthenticatorOption(authenticator))
+
+	var (
+		receipt *Receipt
+		err     error
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receipt, err = receiver.Receive(r.Context(), "callback", r, nil)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		modifiers []RequestModifier
+		wantErr   bool
+	}{
+		{"no token", nil, true},
+		{"valid assertion", []RequestModifier{JWTAssertion(crypto.HS256, "", crypto.Claims{Issuer: "merchant"}, secret, time.Minute)}, false},
+		{"wrong issuer", []RequestModifier{JWTAssertion(crypto.HS256, "", crypto.Claims{Issuer: "other"}, secret, time.Minute)}, true},
+		{"wrong key", []RequestModifier{JWTAssertion(crypto.HS256, "", crypto.Claims{Issuer: "merchant"}, []byte("other"), time.Minute)}, true},
+		{"no expiry", []RequestModifier{func(request *http.Request) error {
+			token, err := crypto.IssueJWT(crypto.HS256, "", crypto.Claims{Issuer: "merchant"}, secret)
+			request.Header.Set("Authorization", "Bearer "+token)
+			return err
+		}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(WithDebugMode(false))
+			request := NewRequest("callback", http.MethodPost, server.URL+"/callback?ref=1", nil)
+			if _, doErr := client.Do(context.Background(), request, nil, tt.modifiers...); doErr != nil {
+				t.Fatal(doErr)
+			}
+
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnauthorized) {
+					t.Errorf("Receive() error = %v, want %v", err, ErrUnauthorized)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Receive() unexpected error: %v", err)
+			}
+			if want := server.URL + "/callback"; !receipt.Claims.Audience.Contains(want) {
+				t.Errorf("Claims.Audience = %v, want %q", receipt.Claims.Audience, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,412 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+// Package basetest provides a scriptable fake mobile money server for tests of
+// code built on base.Client, base.Receiver and base.Replier.
+//
+//	server := basetest.NewServer(t)
+//	server.Expect(http.MethodPost, "/ussd/push").
+//		RespondJSON(http.StatusOK, PushResponse{Status: "PENDING"}).
+//		Callback(100*time.Millisecond, base.NewRequest("callback", http.MethodPost, callbackURL, result))
+//
+//	// point the code under test at server.URL
+//
+//	server.AssertExpectations(t)
+package basetest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/techcraftlabs/base"
+)
+
+const (
+	cTypeJSON = "application/json"
+	cTypeXML  = "application/xml"
+	cTypeSOAP = "text/xml; charset=utf-8"
+)
+
+type (
+	// Server is an httptest.Server that answers requests according to
+	// the expectations registered with Expect
+	Server struct {
+		*httptest.Server
+		mu           sync.Mutex
+		expectations []*Expectation
+		requests     []*Request
+		unmatched    []*Request
+		callbacks    sync.WaitGroup
+		client       *base.Client
+		errors       []error
+	}
+
+	// Request is a request received by the Server
+	Request struct {
+		Method string
+		Path   string
+		Header http.Header
+		Query  map[string][]string
+		Body   []byte
+	}
+
+	// Expectation is a scripted route of the Server
+	Expectation struct {
+		mu        sync.Mutex
+		method    string
+		path      string
+		matchers  []func(r *Request) bool
+		status    int
+		header    http.Header
+		body      []byte
+		delay     time.Duration
+		drop      bool
+		times     int
+		calls     int
+		callbacks []callback
+	}
+
+	callback struct {
+		delay   time.Duration
+		request func(r *Request) *base.Request
+	}
+)
+
+// NewServer starts a Server that is closed when the test finishes
+func NewServer(t testing.TB) *Server {
+	s := &Server{
+		client: base.NewClient(base.WithDebugMode(false)),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Close waits for the pending callbacks and shuts the server down
+func (s *Server) Close() {
+	s.callbacks.Wait()
+	s.Server.Close()
+}
+
+// Expect registers a route, requests are matched against the expectations
+// in the order they were registered
+func (s *Server) Expect(method, path string) *Expectation {
+	e := &Expectation{
+		method: method,
+		path:   path,
+		status: http.StatusOK,
+		header: make(http.Header),
+	}
+	s.mu.Lock()
+	s.expectations = append(s.expectations, e)
+	s.mu.Unlock()
+	return e
+}
+
+// Requests returns all the requests received so far
+func (s *Server) Requests() []*Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Request(nil), s.requests...)
+}
+
+// WaitCallbacks blocks until all the callbacks that have been scheduled are sent
+func (s *Server) WaitCallbacks() {
+	s.callbacks.Wait()
+}
+
+// AssertExpectations fails the test if a request did not match any expectation,
+// an expectation set with Times was not called exactly that many times or a
+// callback could not be delivered
+func (s *Server) AssertExpectations(t testing.TB) {
+	t.Helper()
+	s.callbacks.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.unmatched {
+		t.Errorf("basetest: unexpected request %s %s", r.Method, r.Path)
+	}
+	for _, e := range s.expectations {
+		e.mu.Lock()
+		if e.times > 0 && e.calls != e.times {
+			t.Errorf("basetest: %s %s called %d times, want %d", e.method, e.path, e.calls, e.times)
+		}
+		e.mu.Unlock()
+	}
+	for _, err := range s.errors {
+		t.Errorf("basetest: %v", err)
+	}
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	req := &Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Header: r.Header.Clone(),
+		Query:  r.URL.Query(),
+		Body:   body,
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	var matched *Expectation
+	for _, e := range s.expectations {
+		if e.match(req) {
+			matched = e
+			break
+		}
+	}
+	if matched == nil {
+		s.unmatched = append(s.unmatched, req)
+	}
+	s.mu.Unlock()
+
+	if matched == nil {
+		http.Error(w, fmt.Sprintf("basetest: no expectation for %s %s", r.Method, r.URL.Path), http.StatusNotFound)
+		return
+	}
+
+	// callbacks may be added while the server is running. They are counted
+	// before the response is written, so that a client that calls
+	// WaitCallbacks once it has the response also waits for them
+	matched.mu.Lock()
+	callbacks := append([]callback(nil), matched.callbacks...)
+	matched.mu.Unlock()
+	s.callbacks.Add(len(callbacks))
+
+	matched.respond(w, r)
+
+	for _, cb := range callbacks {
+		go func(cb callback) {
+			defer s.callbacks.Done()
+			time.Sleep(cb.delay)
+			if err := s.send(cb.request(req)); err != nil {
+				s.mu.Lock()
+				s.errors = append(s.errors, err)
+				s.mu.Unlock()
+			}
+		}(cb)
+	}
+}
+
+func (s *Server) send(request *base.Request) error {
+	response, err := s.client.Do(context.Background(), request, nil)
+	if err != nil {
+		return fmt.Errorf("callback %s failed: %w", request.Name, err)
+	}
+	if response.Error != nil {
+		return fmt.Errorf("callback %s rejected with status %d", request.Name, response.StatusCode)
+	}
+	return nil
+}
+
+// match is called with the Server lock held, an expectation that has been
+// called Times times does not match anymore so the next one can take over
+func (e *Expectation) match(r *Request) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.method != r.Method || e.path != r.Path {
+		return false
+	}
+	if e.times > 0 && e.calls >= e.times {
+		return false
+	}
+	for _, m := range e.matchers {
+		if !m(r) {
+			return false
+		}
+	}
+	e.calls++
+	return true
+}
+
+func (e *Expectation) respond(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	delay, drop, status := e.delay, e.drop, e.status
+	header, body := e.header.Clone(), e.body
+	e.mu.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if drop {
+		if hj, ok := w.(http.Hijacker); ok {
+			conn, _, err := hj.Hijack()
+			if err == nil {
+				_ = conn.Close()
+				return
+			}
+		}
+		panic(http.ErrAbortHandler)
+	}
+
+	for key, values := range header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+// MatchHeader restricts the expectation to requests with the header value
+func (e *Expectation) MatchHeader(key, value string) *Expectation {
+	return e.MatchFunc(func(r *Request) bool {
+		return r.Header.Get(key) == value
+	})
+}
+
+// MatchBody restricts the expectation to requests whose body contains s
+func (e *Expectation) MatchBody(s string) *Expectation {
+	return e.MatchFunc(func(r *Request) bool {
+		return bytes.Contains(r.Body, []byte(s))
+	})
+}
+
+// MatchFunc restricts the expectation to requests accepted by fn
+func (e *Expectation) MatchFunc(fn func(r *Request) bool) *Expectation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.matchers = append(e.matchers, fn)
+	return e
+}
+
+// Respond sets a canned response
+func (e *Expectation) Respond(status int, contentType string, body []byte) *Expectation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.status = status
+	if contentType != "" {
+		e.header.Set("Content-Type", contentType)
+	}
+	e.body = body
+	return e
+}
+
+func (e *Expectation) RespondJSON(status int, v interface{}) *Expectation {
+	body, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("basetest: invalid json response: %v", err))
+	}
+	return e.Respond(status, cTypeJSON, body)
+}
+
+func (e *Expectation) RespondXML(status int, v interface{}) *Expectation {
+	body, err := xml.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("basetest: invalid xml response: %v", err))
+	}
+	return e.Respond(status, cTypeXML, body)
+}
+
+// RespondSOAP wraps body in a SOAP 1.1 envelope
+func (e *Expectation) RespondSOAP(status int, body string) *Expectation {
+	return e.Respond(status, cTypeSOAP, []byte(SOAPEnvelope(body)))
+}
+
+// Header adds a response header
+func (e *Expectation) Header(key, value string) *Expectation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.header.Add(key, value)
+	return e
+}
+
+// Delay waits d before responding, use it to trigger client timeouts
+func (e *Expectation) Delay(d time.Duration) *Expectation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.delay = d
+	return e
+}
+
+// DropConnection closes the connection without responding
+func (e *Expectation) DropConnection() *Expectation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.drop = true
+	return e
+}
+
+// Times limits the expectation to n calls and makes AssertExpectations check
+// that it was called exactly n times. Register several expectations of the
+// same route with Times to script a sequence of responses.
+func (e *Expectation) Times(n int) *Expectation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.times = n
+	return e
+}
+
+// Once is Times(1)
+func (e *Expectation) Once() *Expectation {
+	return e.Times(1)
+}
+
+// Callback sends request after delay once the response has been written
+func (e *Expectation) Callback(delay time.Duration, request *base.Request) *Expectation {
+	return e.CallbackFunc(delay, func(*Request) *base.Request {
+		copied := *request
+		return &copied
+	})
+}
+
+// CallbackFunc is like Callback but the callback request is built from the
+// request that triggered it, e.g. to echo its transaction ID
+func (e *Expectation) CallbackFunc(delay time.Duration, fn func(r *Request) *base.Request) *Expectation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.callbacks = append(e.callbacks, callback{delay: delay, request: fn})
+	return e
+}
+
+// SOAPEnvelope wraps body in a SOAP 1.1 envelope
+func SOAPEnvelope(body string) string {
+	builder := strings.Builder{}
+	builder.WriteString(xml.Header)
+	builder.WriteString(`<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/">`)
+	builder.WriteString(`<soapenv:Header/><soapenv:Body>`)
+	builder.WriteString(body)
+	builder.WriteString(`</soapenv:Body></soapenv:Envelope>`)
+	return builder.String()
+}
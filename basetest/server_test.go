@@ -0,0 +1,178 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package basetest
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/techcraftlabs/base"
+)
+
+type (
+	pushRequest struct {
+		Reference string `json:"reference"`
+		Amount    int    `json:"amount"`
+	}
+
+	pushResponse struct {
+		Status string `json:"status"`
+	}
+
+	pushResult struct {
+		Reference string `json:"reference"`
+		Status    string `json:"status"`
+	}
+)
+
+func TestServer(t *testing.T) {
+	results := make(chan pushResult, 1)
+	receiver := base.NewReceiver(io.Discard, false)
+	replier := base.NewReplier(io.Discard, false)
+	callbacks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result := new(pushResult)
+		if _, err := receiver.Receive(r.Context(), "push result", r, result); err != nil {
+			replier.Reply(w, base.NewResponse(http.StatusBadRequest, pushResponse{Status: err.Error()}))
+			return
+		}
+		results <- *result
+		replier.Reply(w, base.NewResponse(http.StatusOK, pushResponse{Status: "RECEIVED"}))
+	}))
+	defer callbacks.Close()
+
+	server := NewServer(t)
+	server.Expect(http.MethodPost, "/push").Once().
+		RespondJSON(http.StatusServiceUnavailable, pushResponse{Status: "BUSY"})
+	server.Expect(http.MethodPost, "/push").Once().
+		MatchHeader("X-Api-Key", "key").
+		RespondJSON(http.StatusOK, pushResponse{Status: "PENDING"}).
+		CallbackFunc(10*time.Millisecond, func(r *Request) *base.Request {
+			push := new(pushRequest)
+			_ = json.Unmarshal(r.Body, push)
+			return base.NewRequest("push result", http.MethodPost, callbacks.URL,
+				pushResult{Reference: push.Reference, Status: "SUCCESS"})
+		})
+
+	client := base.NewClient(base.WithDebugMode(false))
+	push := func() *base.Response {
+		request := base.NewRequest("push", http.MethodPost, server.URL+"/push",
+			pushRequest{Reference: "REF-1", Amount: 1000},
+			base.WithMoreHeaders(map[string]string{"X-Api-Key": "key"}))
+		response, err := client.Do(context.TODO(), request, new(pushResponse))
+		if err != nil {
+			t.Fatalf("Do() unexpected error: %v", err)
+		}
+		return response
+	}
+
+	if response := push(); response.StatusCode != http.StatusServiceUnavailable || response.Error == nil {
+		t.Errorf("first push = %d, %v want 503 with error", response.StatusCode, response.Error)
+	}
+	if response := push(); response.Body.(*pushResponse).Status != "PENDING" {
+		t.Errorf("second push = %+v want PENDING", response.Body)
+	}
+
+	select {
+	case result := <-results:
+		if result.Reference != "REF-1" || result.Status != "SUCCESS" {
+			t.Errorf("callback = %+v", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback not received")
+	}
+
+	server.AssertExpectations(t)
+	if n := len(server.Requests()); n != 2 {
+		t.Errorf("server received %d requests, want 2", n)
+	}
+}
+
+func TestServer_CallbacksAddedWhileServing(t *testing.T) {
+	sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer sink.Close()
+
+	server := NewServer(t)
+	expectation := server.Expect(http.MethodGet, "/status").RespondJSON(http.StatusOK, pushResponse{Status: "OK"})
+	callback := base.NewRequest("status result", http.MethodPost, sink.URL, pushResult{Status: "SUCCESS"})
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			expectation.Callback(0, callback)
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	client := base.NewClient(base.WithDebugMode(false))
+	for i := 0; i < 20; i++ {
+		request := base.NewRequest("status", http.MethodGet, server.URL+"/status", nil)
+		if _, err := client.Do(context.TODO(), request, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	<-done
+	server.WaitCallbacks()
+}
+
+func TestServer_WaitCallbacksAfterResponse(t *testing.T) {
+	var received int32
+	sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&received, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer sink.Close()
+
+	server := NewServer(t)
+	server.Expect(http.MethodGet, "/status").RespondJSON(http.StatusOK, pushResponse{Status: "OK"}).
+		Callback(0, base.NewRequest("status result", http.MethodPost, sink.URL, pushResult{Status: "SUCCESS"}))
+
+	client := base.NewClient(base.WithDebugMode(false))
+	for i := int32(1); i <= 50; i++ {
+		request := base.NewRequest("status", http.MethodGet, server.URL+"/status", nil)
+		if _, err := client.Do(context.TODO(), request, nil); err != nil {
+			t.Fatal(err)
+		}
+		server.WaitCallbacks()
+		if got := atomic.LoadInt32(&received); got != i {
+			t.Fatalf("%d callbacks received after WaitCallbacks, want %d", got, i)
+		}
+	}
+}
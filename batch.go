@@ -0,0 +1,388 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package base
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const defaultBatchConcurrency = 10
+
+// ErrBatchFailed is wrapped by *BatchError
+var ErrBatchFailed = errors.New("batch has failed items")
+
+type (
+	// BatchConfig controls a Batch.
+	//
+	// Limiters are keyed by Request.MNO (case insensitive) and are waited on
+	// in addition to the rate limiter of the Client. NewBody returns the value
+	// the response of a request is decoded into, it can be nil. Succeeded tells
+	// whether a response is a success, by default when Response.Error is nil.
+	//
+	// When Checkpoint is set the keys of the succeeded requests are appended to
+	// that file and the requests whose key is already in it are skipped, so a
+	// batch that stopped can be run again with the same input. Key defaults to
+	// Request.RequestID, or the position of the request in the input when it is
+	// empty, set stable request IDs (see WithRequestID) when the input order may
+	// change between runs. OpenCheckpoint opens the checkpoint file, it
+	// defaults to os.OpenFile.
+	//
+	// DryRun only builds the http.Request of every request to validate it,
+	// nothing is sent.
+	BatchConfig struct {
+		Concurrency int
+		Limiters    map[string]RateLimiter
+		NewBody     func(request *Request) interface{}
+		Succeeded   func(response *Response) bool
+		Modifiers   []RequestModifier
+		Checkpoint  string
+		Key         func(index int, request *Request) string
+		DryRun      bool
+
+		OpenCheckpoint func(name string, flag int, perm os.FileMode) (*os.File, error)
+	}
+
+	BatchResult struct {
+		Index    int
+		Key      string
+		Request  *Request
+		Response *Response
+		Err      error
+
+		// Skipped is true when the request has been done in a previous run
+		Skipped bool
+	}
+
+	// BatchReport holds the results in input order
+	BatchReport struct {
+		Results   []BatchResult
+		Succeeded int
+		Failed    int
+		Skipped   int
+	}
+
+	BatchError struct {
+		Failed int
+		Total  int
+	}
+
+	// Batch runs many requests with a Client with bounded concurrency
+	Batch struct {
+		client   *Client
+		config   BatchConfig
+		limiters map[string]RateLimiter
+	}
+
+	batchJob struct {
+		index   int
+		request *Request
+	}
+)
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("%v: %d of %d requests failed", ErrBatchFailed, e.Failed, e.Total)
+}
+
+func (e *BatchError) Unwrap() error {
+	return ErrBatchFailed
+}
+
+// Failures returns the results of the failed requests
+func (r *BatchReport) Failures() []BatchResult {
+	var failures []BatchResult
+	for _, result := range r.Results {
+		if result.Err != nil {
+			failures = append(failures, result)
+		}
+	}
+	return failures
+}
+
+// Err returns a *BatchError when some requests failed
+func (r *BatchReport) Err() error {
+	if r.Failed == 0 {
+		return nil
+	}
+	return &BatchError{Failed: r.Failed, Total: len(r.Results)}
+}
+
+func NewBatch(client *Client, config BatchConfig) *Batch {
+	if config.Concurrency <= 0 {
+		config.Concurrency = defaultBatchConcurrency
+	}
+	if config.Succeeded == nil {
+		config.Succeeded = func(response *Response) bool {
+			return response.Error == nil
+		}
+	}
+	if config.Key == nil {
+		config.Key = func(index int, request *Request) string {
+			if request.RequestID != "" {
+				return request.RequestID
+			}
+			return strconv.Itoa(index)
+		}
+	}
+	if config.OpenCheckpoint == nil {
+		config.OpenCheckpoint = os.OpenFile
+	}
+
+	limiters := make(map[string]RateLimiter, len(config.Limiters))
+	for mno, limiter := range config.Limiters {
+		limiters[strings.ToUpper(mno)] = limiter
+	}
+
+	return &Batch{client: client, config: config, limiters: limiters}
+}
+
+// Run runs the requests, see RunChan
+func (b *Batch) Run(ctx context.Context, requests []*Request) (*BatchReport, error) {
+	// feedCtx stops the feeding when RunChan returns early
+	feedCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ch := make(chan *Request)
+	go func() {
+		defer close(ch)
+		for _, request := range requests {
+			select {
+			case ch <- request:
+			case <-feedCtx.Done():
+				return
+			}
+		}
+	}()
+	return b.RunChan(ctx, ch)
+}
+
+// RunChan runs the requests received from requests until it is closed. Failed
+// requests do not stop the batch, they are reported in the BatchReport (see
+// BatchReport.Err). The returned error is not nil when the checkpoint file can
+// not be used or ctx is done, the report then holds the results so far. A
+// failed checkpoint write stops the batch, as a resumed batch would send the
+// request again, no more requests are read and the ones being sent finish.
+func (b *Batch) RunChan(ctx context.Context, requests <-chan *Request) (*BatchReport, error) {
+	done, checkpoint, err := b.openCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+	if checkpoint != nil {
+		defer checkpoint.Close()
+	}
+
+	var (
+		mu            sync.Mutex
+		results       []BatchResult
+		wg            sync.WaitGroup
+		jobs          = make(chan batchJob)
+		stop          = make(chan struct{})
+		checkpointErr error
+	)
+
+	for i := 0; i < b.config.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				mu.Lock()
+				result := results[job.index]
+				if checkpointErr != nil {
+					// the batch has been stopped
+					results[job.index].Err = checkpointErr
+					mu.Unlock()
+					continue
+				}
+				mu.Unlock()
+
+				result = b.do(ctx, result)
+
+				mu.Lock()
+				if result.Err == nil && checkpoint != nil && !b.config.DryRun && checkpointErr == nil {
+					key, _ := json.Marshal(result.Key)
+					if _, err := checkpoint.Write(append(key, '\n')); err != nil {
+						checkpointErr = fmt.Errorf("batch: checkpoint: %w", err)
+						close(stop)
+					}
+				}
+				results[job.index] = result
+				mu.Unlock()
+			}
+		}()
+	}
+
+	index := 0
+feed:
+	for {
+		select {
+		case <-ctx.Done():
+			break feed
+		case <-stop:
+			break feed
+		case request, ok := <-requests:
+			if !ok {
+				break feed
+			}
+			key := b.config.Key(index, request)
+			mu.Lock()
+			results = append(results, BatchResult{Index: index, Key: key, Request: request})
+			mu.Unlock()
+			if done[key] {
+				mu.Lock()
+				results[index].Skipped = true
+				mu.Unlock()
+			} else {
+				select {
+				case jobs <- batchJob{index: index, request: request}:
+				case <-ctx.Done():
+					mu.Lock()
+					results[index].Err = ctx.Err()
+					mu.Unlock()
+				case <-stop:
+					mu.Lock()
+					results[index].Err = checkpointErr
+					mu.Unlock()
+				}
+			}
+			index++
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	report := &BatchReport{Results: results}
+	for _, result := range results {
+		switch {
+		case result.Skipped:
+			report.Skipped++
+		case result.Err != nil:
+			report.Failed++
+		default:
+			report.Succeeded++
+		}
+	}
+
+	if checkpointErr != nil {
+		return report, checkpointErr
+	}
+	if checkpoint != nil {
+		if err := checkpoint.Sync(); err != nil {
+			return report, fmt.Errorf("batch: checkpoint: %w", err)
+		}
+	}
+	return report, ctx.Err()
+}
+
+func (b *Batch) do(ctx context.Context, result BatchResult) BatchResult {
+	request := result.Request
+
+	// Client.Do appends the endpoint to the URL of the request
+	// it is given, so a copy is used
+	req := *request
+	if b.config.DryRun {
+		result.Err = validateRequest(ctx, &req, b.config.Modifiers...)
+		return result
+	}
+
+	if limiter, ok := b.limiters[strings.ToUpper(request.MNO)]; ok {
+		if err := limiter.Wait(ctx); err != nil {
+			result.Err = err
+			return result
+		}
+	}
+
+	var body interface{}
+	if b.config.NewBody != nil {
+		body = b.config.NewBody(request)
+	}
+	response, err := b.client.Do(ctx, &req, body, b.config.Modifiers...)
+	result.Response = response
+	switch {
+	case err != nil:
+		result.Err = err
+	case !b.config.Succeeded(response):
+		result.Err = fmt.Errorf("%s: status %d: %w", request.Name, response.StatusCode, errOrDoErr(response.Error))
+	}
+	return result
+}
+
+func errOrDoErr(err error) error {
+	if err == nil {
+		return DoErr
+	}
+	return err
+}
+
+// validateRequest builds the http.Request like Client.Do without sending it
+func validateRequest(ctx context.Context, request *Request, modifiers ...RequestModifier) error {
+	req, err := NewRequestWithContext(ctx, request, modifiers...)
+	if err != nil {
+		return err
+	}
+	if req.URL.Host == "" || (req.URL.Scheme != "http" && req.URL.Scheme != "https") {
+		return fmt.Errorf("%s: invalid url %q", request.Name, request.URL)
+	}
+	return nil
+}
+
+// openCheckpoint reads the keys in the checkpoint file and opens it for
+// appending
+func (b *Batch) openCheckpoint() (map[string]bool, *os.File, error) {
+	done := make(map[string]bool)
+	if b.config.Checkpoint == "" {
+		return done, nil, nil
+	}
+
+	file, err := b.config.OpenCheckpoint(b.config.Checkpoint, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, nil, fmt.Errorf("batch: checkpoint: %w", err)
+	}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var key string
+		if err := json.Unmarshal([]byte(line), &key); err != nil {
+			_ = file.Close()
+			return nil, nil, fmt.Errorf("batch: checkpoint: invalid line %q", line)
+		}
+		done[key] = true
+	}
+	if err := scanner.Err(); err != nil {
+		_ = file.Close()
+		return nil, nil, fmt.Errorf("batch: checkpoint: %w", err)
+	}
+	return done, file, nil
+}
@@ -0,0 +1,156 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package base
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+type countingLimiter struct {
+	waits int32
+}
+
+func (l *countingLimiter) Wait(context.Context) error {
+	atomic.AddInt32(&l.waits, 1)
+	return nil
+}
+
+func TestBatch_Run(t *testing.T) {
+	var (
+		hits    int32
+		failing int32 = 1
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		var line statementLine
+		_ = json.NewDecoder(r.Body).Decode(&line)
+		if line.ID == 3 && atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	requests := make([]*Request, 10)
+	for i := range requests {
+		mno := "VODACOM"
+		if i%2 == 1 {
+			mno = "airtel"
+		}
+		requests[i] = NewRequest("disburse", http.MethodPost, server.URL, statementLine{ID: i},
+			WithRequestID(fmt.Sprintf("salary-%d", i)))
+		requests[i].MNO = mno
+	}
+
+	airtel := new(countingLimiter)
+	config := BatchConfig{
+		Concurrency: 3,
+		Limiters:    map[string]RateLimiter{"AIRTEL": airtel},
+		Checkpoint:  filepath.Join(t.TempDir(), "salaries.checkpoint"),
+	}
+	batch := NewBatch(NewClient(WithDebugMode(false)), config)
+
+	report, err := batch.Run(context.Background(), requests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, result := range report.Results {
+		if result.Index != i || result.Request != requests[i] {
+			t.Errorf("result %d is for request %d", i, result.Index)
+		}
+	}
+	if report.Succeeded != 9 || report.Failed != 1 || report.Failures()[0].Key != "salary-3" {
+		t.Errorf("report = %d succeeded, %d failed, failures %v", report.Succeeded, report.Failed, report.Failures())
+	}
+	if !errors.Is(report.Err(), ErrBatchFailed) {
+		t.Errorf("Err() = %v, want ErrBatchFailed", report.Err())
+	}
+	if waits := atomic.LoadInt32(&airtel.waits); waits != 5 {
+		t.Errorf("airtel limiter waited %d times, want 5", waits)
+	}
+
+	// resuming only sends the failed request
+	atomic.StoreInt32(&failing, 0)
+	atomic.StoreInt32(&hits, 0)
+	report, err = batch.Run(context.Background(), requests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Skipped != 9 || report.Succeeded != 1 || report.Err() != nil || atomic.LoadInt32(&hits) != 1 {
+		t.Errorf("resume = %d skipped, %d succeeded, %d sent", report.Skipped, report.Succeeded, hits)
+	}
+
+	// dry run sends nothing
+	atomic.StoreInt32(&hits, 0)
+	invalid := NewRequest("disburse", http.MethodPost, "not-a-url", nil)
+	report, err = NewBatch(NewClient(), BatchConfig{DryRun: true}).Run(context.Background(), append(requests, invalid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Failed != 1 || report.Results[10].Err == nil || atomic.LoadInt32(&hits) != 0 {
+		t.Errorf("dry run = %d failed, %d sent", report.Failed, hits)
+	}
+}
+
+func TestBatch_CheckpointWriteError(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	requests := make([]*Request, 5)
+	for i := range requests {
+		requests[i] = NewRequest("disburse", http.MethodPost, server.URL, statementLine{ID: i})
+	}
+	config := BatchConfig{
+		Concurrency: 1,
+		Checkpoint:  filepath.Join(t.TempDir(), "salaries.checkpoint"),
+		// a read only checkpoint file can be read but not written
+		OpenCheckpoint: func(name string, _ int, perm os.FileMode) (*os.File, error) {
+			return os.OpenFile(name, os.O_CREATE|os.O_RDONLY, perm)
+		},
+	}
+	report, err := NewBatch(NewClient(WithDebugMode(false)), config).Run(context.Background(), requests)
+	if err == nil || !strings.HasPrefix(err.Error(), "batch: checkpoint:") {
+		t.Fatalf("Run() error = %v, want a checkpoint error", err)
+	}
+	if n := atomic.LoadInt32(&hits); n != 1 || report.Succeeded != 1 {
+		t.Errorf("sent %d requests with %d succeeded after the checkpoint failed, want 1 and 1", n, report.Succeeded)
+	}
+}
@@ -30,6 +30,8 @@ import (
 	"crypto/x509"
 	"fmt"
 	"github.com/techcraftlabs/base/io"
+	"github.com/techcraftlabs/base/metrics"
+	"github.com/techcraftlabs/base/trace"
 	stdio "io"
 	"net/http"
 	"net/http/httputil"
@@ -40,6 +42,10 @@ import (
 
 const (
 	defaultTimeout = 60 * time.Second
+
+	// DefaultDebugBodyLimit is the number of bytes of the request and
+	// response bodies written to the debug logs
+	DefaultDebugBodyLimit = 64 * 1024
 )
 
 type (
@@ -49,6 +55,13 @@ type (
 		Logger    stdio.Writer // for logging purposes
 		DebugMode bool
 		certPool  *x509.CertPool
+		limiter   RateLimiter
+		metrics   metrics.Recorder
+		tracer    trace.Tracer
+		requestID string // header of the request ID
+		hooks     []ExchangeHook
+
+		debugLimit int // bytes of the bodies written to the debug logs
 	}
 
 	ClientOption func(client *Client)
@@ -79,6 +92,10 @@ func NewClient(opts ...ClientOption) *Client {
 		Http:      defClient,
 		Logger:    io.StdErr,
 		DebugMode: true,
+		tracer:    trace.Nop{},
+		requestID: DefaultRequestIDHeader,
+
+		debugLimit: DefaultDebugBodyLimit,
 	}
 
 	for _, opt := range opts {
@@ -113,8 +130,8 @@ func (c *Client) log(name string, request *http.Request) {
 func (c *Client) logOut(name string, request *http.Request, response *http.Response) {
 
 	if request != nil {
-		reqDump, _ := httputil.DumpRequestOut(request, true)
-		_, err := fmt.Fprintf(c.Logger, "\n\n%s REQUEST (OUTGOING)\n%s\n\n", name, reqDump)
+		reqDump, _ := httputil.DumpRequestOut(request, false)
+		_, err := fmt.Fprintf(c.Logger, "\n\n%s REQUEST (OUTGOING)\n%s%s\n\n", name, reqDump, c.debugBody(request.Body))
 		if err != nil {
 			fmt.Printf("error while logging %s request: %v\n",
 				strings.ToLower(name), err)
@@ -122,8 +139,8 @@ func (c *Client) logOut(name string, request *http.Request, response *http.Respo
 	}
 
 	if response != nil {
-		respDump, _ := httputil.DumpResponse(response, true)
-		_, err := fmt.Fprintf(c.Logger, "\n\n%s RESPONSE\n%s\n\n", name, respDump)
+		respDump, _ := httputil.DumpResponse(response, false)
+		_, err := fmt.Fprintf(c.Logger, "\n\n%s RESPONSE\n%s%s\n\n", name, respDump, c.debugBody(response.Body))
 		if err != nil {
 			fmt.Printf("error while logging %s response: %v\n",
 				strings.ToLower(name), err)
@@ -133,6 +150,36 @@ func (c *Client) logOut(name string, request *http.Request, response *http.Respo
 	return
 }
 
+// getTracer returns the tracer of the client, trace.Nop for a Client that
+// was not made by NewClient
+func (c *Client) getTracer() trace.Tracer {
+	if c.tracer == nil {
+		return trace.Nop{}
+	}
+	return c.tracer
+}
+
+// requestIDHeader returns the header of the request ID, DefaultRequestIDHeader
+// for a Client that was not made by NewClient
+func (c *Client) requestIDHeader() string {
+	if c.requestID == "" {
+		return DefaultRequestIDHeader
+	}
+	return c.requestID
+}
+
+// debugBody reads body for the debug logs, it is cut at the debug body limit
+func (c *Client) debugBody(body stdio.Reader) string {
+	if body == nil {
+		return ""
+	}
+	b, _ := stdio.ReadAll(body)
+	if c.debugLimit > 0 && len(b) > c.debugLimit {
+		return fmt.Sprintf("%s\n... (body truncated to %d bytes)", b[:c.debugLimit], c.debugLimit)
+	}
+	return string(b)
+}
+
 // WithDebugMode set debug mode to true or false
 func WithDebugMode(debugMode bool) ClientOption {
 	return func(client *Client) {
@@ -195,3 +242,121 @@ func WithCACert(caCert []byte) ClientOption {
 	}
 
 }
+
+// WithTimeout set the time limit for requests made by the client, it includes
+// connection time, any redirects, and reading the response body.
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(client *Client) {
+		if timeout <= 0 {
+			return
+		}
+		client.Http.Timeout = timeout
+	}
+}
+
+// WithRateLimiter limits the rate at which Client.Do sends requests.
+// Nil value is ignored
+func WithRateLimiter(limiter RateLimiter) ClientOption {
+	return func(client *Client) {
+		if limiter == nil {
+			return
+		}
+		client.limiter = limiter
+	}
+}
+
+// WithTLSConfig replaces the http.Client with one whose transport uses cfg.
+// Use it when WithCACert is not enough e.g. for mutual TLS. Nil value is ignored
+func WithTLSConfig(cfg *tls.Config) ClientOption {
+	return func(client *Client) {
+		if cfg == nil {
+			return
+		}
+
+		c := &http.Client{
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: cfg,
+			},
+			CheckRedirect: client.Http.CheckRedirect,
+			Jar:           client.Http.Jar,
+			Timeout:       client.Http.Timeout,
+		}
+
+		client.Http = c
+	}
+}
+
+// WithTransport replaces the http.Client with one that sends requests through
+// transport, e.g. a recording or fault injecting http.RoundTripper. Nil value
+// is ignored
+func WithTransport(transport http.RoundTripper) ClientOption {
+	return func(client *Client) {
+		if transport == nil {
+			return
+		}
+
+		c := &http.Client{
+			Transport:     transport,
+			CheckRedirect: client.Http.CheckRedirect,
+			Jar:           client.Http.Jar,
+			Timeout:       client.Http.Timeout,
+		}
+
+		client.Http = c
+	}
+}
+
+// WithMetrics records the requests made by the client in recorder.
+// Nil value is ignored
+func WithMetrics(recorder metrics.Recorder) ClientOption {
+	return func(client *Client) {
+		if recorder == nil {
+			return
+		}
+		client.metrics = recorder
+	}
+}
+
+// WithTracer records a client span for every request made by the client and
+// propagates it with the traceparent and tracestate headers. The default
+// tracer records nothing and only propagates the span context of the request
+// context. Nil value is ignored
+func WithTracer(tracer trace.Tracer) ClientOption {
+	return func(client *Client) {
+		if tracer == nil {
+			return
+		}
+		client.tracer = tracer
+	}
+}
+
+// WithRequestIDHeader sets the header used to send the request ID,
+// DefaultRequestIDHeader is used by default. Empty value is ignored
+func WithRequestIDHeader(header string) ClientOption {
+	return func(client *Client) {
+		if header == "" {
+			return
+		}
+		client.requestID = header
+	}
+}
+
+// WithExchangeHook calls hook after every request made by the client,
+// it can be used more than once to add several hooks. Nil value is ignored
+func WithExchangeHook(hook ExchangeHook) ClientOption {
+	return func(client *Client) {
+		if hook == nil {
+			return
+		}
+		client.hooks = append(client.hooks, hook)
+	}
+}
+
+// WithDebugBodyLimit sets the number of bytes of the request and response
+// bodies written to the debug logs, n <= 0 writes the whole bodies
+func WithDebugBodyLimit(n int) ClientOption {
+	return func(client *Client) {
+		client.debugLimit = n
+	}
+}
@@ -0,0 +1,107 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+// Command sealsecrets writes the file read by secrets.NewEncryptedFile from
+// a JSON object of secret names and values. The passphrase is read from the
+// SECRETS_PASSPHRASE environment variable.
+//
+//	sealsecrets -o secrets.enc secrets.json
+//	sealsecrets -list secrets.enc
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/techcraftlabs/base/secrets"
+)
+
+const passphraseEnv = "SECRETS_PASSPHRASE"
+
+func main() {
+	output := flag.String("o", "", "sealed file to write")
+	list := flag.Bool("list", false, "print the names of the secrets of a sealed file")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s -o OUTPUT FILE | -list FILE\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || *output == "" && !*list {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	passphrase := []byte(os.Getenv(passphraseEnv))
+	if len(passphrase) == 0 {
+		fmt.Fprintf(os.Stderr, "%s is not set\n", passphraseEnv)
+		os.Exit(2)
+	}
+
+	var err error
+	if *list {
+		err = listNames(flag.Arg(0), passphrase)
+	} else {
+		err = seal(flag.Arg(0), *output, passphrase)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func seal(input, output string, passphrase []byte) error {
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return err
+	}
+	values := make(map[string]string)
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("%s: %w", input, err)
+	}
+	return secrets.WriteEncryptedFile(output, values, passphrase)
+}
+
+func listNames(path string, passphrase []byte) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	values, err := secrets.Open(data, passphrase)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Println(name)
+	}
+	return nil
+}
@@ -0,0 +1,314 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package config
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/techcraftlabs/base"
+)
+
+var (
+	_ base.RequestInformer = (*Endpoint)(nil)
+)
+
+type (
+	// Services are the clients, routes and endpoints built from a Config
+	Services struct {
+		router   *base.Router
+		services map[string]*Service
+	}
+
+	Service struct {
+		Name      string
+		MNO       string
+		Group     string
+		BaseURL   string
+		Client    *base.Client
+		Route     *base.Route
+		endpoints map[string]*Endpoint
+	}
+
+	// Endpoint is a base.RequestInformer built from an EndpointConfig
+	Endpoint struct {
+		service     *Service
+		name        string
+		path        string
+		method      string
+		contentType string
+	}
+)
+
+// Build creates a base.Client and a base.Route for every service in the Config
+// and registers the routes in a base.Router. opts are applied to every client
+// before the options derived from the Config.
+func (c *Config) Build(opts ...base.ClientOption) (*Services, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	verr := new(ValidationError)
+	services := &Services{
+		router:   base.NewRouter(),
+		services: make(map[string]*Service),
+	}
+
+	for i, sc := range c.Services {
+		at := fmt.Sprintf("services[%d] (%s)", i, sc.Name)
+		tlsConfig, err := sc.TLS.build()
+		if err != nil {
+			verr.add("%s: tls: %v", at, err)
+			continue
+		}
+
+		clientOpts := append([]base.ClientOption{}, opts...)
+		clientOpts = append(clientOpts,
+			base.WithTLSConfig(tlsConfig),
+			base.WithTimeout(time.Duration(sc.Timeout)),
+			base.WithDebugMode(sc.Debug),
+		)
+		if rl := sc.RateLimit; rl != nil {
+			limiter, err := base.NewRateLimiter(rl.Limit, time.Duration(rl.Per), rl.Burst)
+			if err != nil {
+				verr.add("%s: rate_limit: %v", at, err)
+				continue
+			}
+			clientOpts = append(clientOpts, base.WithRateLimiter(limiter))
+		}
+
+		svc := &Service{
+			Name:      sc.Name,
+			MNO:       sc.MNO,
+			Group:     sc.Group,
+			BaseURL:   sc.BaseURL,
+			Client:    base.NewClient(clientOpts...),
+			endpoints: make(map[string]*Endpoint),
+		}
+		svc.Route = sc.route(svc.Client)
+
+		for _, ec := range sc.Endpoints {
+			svc.endpoints[ec.Name] = &Endpoint{
+				service:     svc,
+				name:        ec.Name,
+				path:        ec.Path,
+				method:      strings.ToUpper(ec.Method),
+				contentType: ec.ContentType,
+			}
+		}
+
+		services.services[svc.Name] = svc
+		services.router.Register(svc.MNO, svc.Group, svc.Route)
+	}
+
+	if len(verr.Problems) > 0 {
+		return nil, verr
+	}
+	return services, nil
+}
+
+func (sc ServiceConfig) route(client *base.Client) *base.Route {
+	route := &base.Route{
+		Client:  client,
+		BaseURL: sc.BaseURL,
+		Headers: make(map[string]string),
+	}
+	for key, value := range sc.Headers {
+		route.Headers[key] = value
+	}
+
+	auth := sc.Auth
+	switch strings.ToLower(auth.Scheme) {
+	case AuthBasic:
+		route.BasicAuth = &base.BasicAuth{
+			Username: auth.Username,
+			Password: auth.Password,
+		}
+	case AuthBearer:
+		route.Headers["Authorization"] = "Bearer " + auth.Token
+	case AuthAPIKey:
+		header := auth.Header
+		if header == "" {
+			header = defaultAPIKeyHeader
+		}
+		route.Headers[header] = auth.Key
+	}
+
+	return route
+}
+
+// build returns nil when there is no TLS material to use
+func (t TLSConfig) build() (*tls.Config, error) {
+	caCert, err := inlineOrFile(t.CACert, t.CACertFile)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := inlineOrFile(t.Cert, t.CertFile)
+	if err != nil {
+		return nil, err
+	}
+	key, err := inlineOrFile(t.Key, t.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if caCert == nil && cert == nil && !t.InsecureSkipVerify {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if caCert != nil {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no PEM certificate found in ca cert")
+		}
+		cfg.RootCAs = pool
+	}
+
+	if cert != nil {
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{pair}
+	}
+
+	return cfg, nil
+}
+
+func inlineOrFile(inline, path string) ([]byte, error) {
+	if inline != "" {
+		return []byte(inline), nil
+	}
+	if path == "" {
+		return nil, nil
+	}
+	return os.ReadFile(path)
+}
+
+// Router returns the base.Router that has a route for every service
+func (s *Services) Router() *base.Router {
+	return s.router
+}
+
+// Names returns the sorted names of the services
+func (s *Services) Names() []string {
+	names := make([]string, 0, len(s.services))
+	for name := range s.services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *Services) Service(name string) (*Service, error) {
+	svc, ok := s.services[name]
+	if !ok {
+		return nil, fmt.Errorf("config: unknown service %q", name)
+	}
+	return svc, nil
+}
+
+// Endpoint is a shortcut of Service(service) followed by Service.Endpoint(endpoint)
+func (s *Services) Endpoint(service, endpoint string) (*Endpoint, error) {
+	svc, err := s.Service(service)
+	if err != nil {
+		return nil, err
+	}
+	return svc.Endpoint(endpoint)
+}
+
+func (svc *Service) Endpoint(name string) (*Endpoint, error) {
+	ep, ok := svc.endpoints[name]
+	if !ok {
+		return nil, fmt.Errorf("config: service %q has no endpoint %q", svc.Name, name)
+	}
+	return ep, nil
+}
+
+// Send builds the request of the endpoint and sends it through the Router
+func (s *Services) Send(ctx context.Context, service, endpoint string, payload, body interface{},
+	opts ...base.RequestOption) (*base.Response, error) {
+	ep, err := s.Endpoint(service, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return s.router.Do(ctx, ep.Request(payload, opts...), body)
+}
+
+// Request is base.MakeInternalRequest that also sets the Content-Type
+// of the endpoint when there is one
+func (e *Endpoint) Request(payload interface{}, opts ...base.RequestOption) *base.Request {
+	if e.contentType != "" {
+		opts = append([]base.RequestOption{
+			base.WithMoreHeaders(map[string]string{"Content-Type": e.contentType}),
+		}, opts...)
+	}
+	return base.MakeInternalRequest(e, payload, opts...)
+}
+
+func (e *Endpoint) String() string {
+	return e.name
+}
+
+func (e *Endpoint) RequestBaseURL() string {
+	return e.service.BaseURL
+}
+
+func (e *Endpoint) RequestEndpoint() string {
+	return e.path
+}
+
+func (e *Endpoint) RequestMethod() string {
+	return e.method
+}
+
+func (e *Endpoint) RequestName() string {
+	return e.name
+}
+
+func (e *Endpoint) RecipientMNO() string {
+	return e.service.MNO
+}
+
+func (e *Endpoint) RequestGroup() string {
+	return e.service.Group
+}
+
+// ContentType returns the Content-Type configured for the endpoint, it
+// is empty when the default of base.NewRequest is used
+func (e *Endpoint) ContentType() string {
+	return e.contentType
+}
@@ -0,0 +1,331 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+// Package config builds base.Client, base.Route and base.RequestInformer values
+// from a declarative YAML or JSON file instead of wiring them in code.
+//
+//	services:
+//	  - name: vodacom-tz
+//	    mno: VODACOM
+//	    base_url: https://openapi.m-pesa.com
+//	    timeout: 30s
+//	    auth:
+//	      scheme: basic
+//	      username: ${env:VODACOM_USERNAME}
+//	      password: ${file:/run/secrets/vodacom_password}
+//	    tls:
+//	      ca_cert_file: /etc/ssl/vodacom.pem
+//	    endpoints:
+//	      - name: c2b
+//	        path: /ipg/v2/vodacomTZN/c2bPayment/singleStage/
+//	        method: POST
+//	        content_type: application/json
+//
+// String values can reference secrets with ${env:NAME} which is replaced by the
+// value of the environment variable NAME and ${file:PATH} which is replaced by
+// the content of the file at PATH.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	JSON Format = iota
+	YAML
+)
+
+const (
+	AuthNone   = "none"
+	AuthBasic  = "basic"
+	AuthBearer = "bearer"
+	AuthAPIKey = "api_key"
+)
+
+const defaultAPIKeyHeader = "X-API-Key"
+
+var ErrUnknownFormat = errors.New("config: unknown file format")
+
+type (
+	// Format is the encoding of a configuration file
+	Format int
+
+	// Duration is a time.Duration written as a string like "30s" or "1m30s"
+	Duration time.Duration
+
+	Config struct {
+		Services []ServiceConfig `json:"services" yaml:"services"`
+	}
+
+	ServiceConfig struct {
+		Name      string            `json:"name" yaml:"name"`
+		MNO       string            `json:"mno" yaml:"mno"`
+		Group     string            `json:"group" yaml:"group"`
+		BaseURL   string            `json:"base_url" yaml:"base_url"`
+		Timeout   Duration          `json:"timeout" yaml:"timeout"`
+		Debug     bool              `json:"debug" yaml:"debug"`
+		Headers   map[string]string `json:"headers" yaml:"headers"`
+		Auth      AuthConfig        `json:"auth" yaml:"auth"`
+		TLS       TLSConfig         `json:"tls" yaml:"tls"`
+		RateLimit *RateLimitConfig  `json:"rate_limit" yaml:"rate_limit"`
+		Endpoints []EndpointConfig  `json:"endpoints" yaml:"endpoints"`
+	}
+
+	// AuthConfig describes how requests are authenticated. Scheme is one of
+	// none, basic (Username and Password), bearer (Token) and api_key (Key sent
+	// in Header, X-API-Key by default)
+	AuthConfig struct {
+		Scheme   string `json:"scheme" yaml:"scheme"`
+		Username string `json:"username" yaml:"username"`
+		Password string `json:"password" yaml:"password"`
+		Token    string `json:"token" yaml:"token"`
+		Header   string `json:"header" yaml:"header"`
+		Key      string `json:"key" yaml:"key"`
+	}
+
+	// TLSConfig holds PEM encoded TLS material, either inline or as file paths
+	TLSConfig struct {
+		CACert             string `json:"ca_cert" yaml:"ca_cert"`
+		CACertFile         string `json:"ca_cert_file" yaml:"ca_cert_file"`
+		Cert               string `json:"cert" yaml:"cert"`
+		CertFile           string `json:"cert_file" yaml:"cert_file"`
+		Key                string `json:"key" yaml:"key"`
+		KeyFile            string `json:"key_file" yaml:"key_file"`
+		InsecureSkipVerify bool   `json:"insecure_skip_verify" yaml:"insecure_skip_verify"`
+	}
+
+	// RateLimitConfig allows Limit requests every Per duration with bursts of Burst
+	RateLimitConfig struct {
+		Limit int      `json:"limit" yaml:"limit"`
+		Per   Duration `json:"per" yaml:"per"`
+		Burst int      `json:"burst" yaml:"burst"`
+	}
+
+	EndpointConfig struct {
+		Name        string `json:"name" yaml:"name"`
+		Path        string `json:"path" yaml:"path"`
+		Method      string `json:"method" yaml:"method"`
+		ContentType string `json:"content_type" yaml:"content_type"`
+	}
+
+	// ValidationError lists every problem found in a Config
+	ValidationError struct {
+		Problems []string
+	}
+)
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("config: invalid configuration:\n\t%s", strings.Join(e.Problems, "\n\t"))
+}
+
+func (e *ValidationError) add(format string, args ...interface{}) {
+	e.Problems = append(e.Problems, fmt.Sprintf(format, args...))
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\": %w", err)
+	}
+	return d.parse(s)
+}
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	return d.parse(node.Value)
+}
+
+func (d *Duration) parse(s string) error {
+	if s == "" {
+		*d = 0
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Load reads the configuration file at path, the format is determined by
+// the file extension (.json, .yaml or .yml)
+func Load(path string) (*Config, error) {
+	var format Format
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		format = JSON
+	case ".yaml", ".yml":
+		format = YAML
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: could not open file: %w", err)
+	}
+	defer file.Close()
+
+	return Decode(file, format)
+}
+
+// Decode reads a Config from reader, resolves its secret references and
+// validates it
+func Decode(reader io.Reader, format Format) (*Config, error) {
+	config := new(Config)
+	switch format {
+	case JSON:
+		decoder := json.NewDecoder(reader)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(config); err != nil {
+			return nil, fmt.Errorf("config: could not decode json: %w", err)
+		}
+	case YAML:
+		decoder := yaml.NewDecoder(reader)
+		decoder.KnownFields(true)
+		if err := decoder.Decode(config); err != nil {
+			return nil, fmt.Errorf("config: could not decode yaml: %w", err)
+		}
+	default:
+		return nil, ErrUnknownFormat
+	}
+
+	if err := config.resolve(); err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Validate checks the Config and returns a *ValidationError that lists
+// all the problems found
+func (c *Config) Validate() error {
+	verr := new(ValidationError)
+	names := make(map[string]bool)
+	routes := make(map[string]string)
+
+	if len(c.Services) == 0 {
+		verr.add("no services defined")
+	}
+
+	for i, svc := range c.Services {
+		at := fmt.Sprintf("services[%d]", i)
+		if svc.Name != "" {
+			at = fmt.Sprintf("%s (%s)", at, svc.Name)
+		}
+
+		switch {
+		case svc.Name == "":
+			verr.add("%s: name is required", at)
+		case names[svc.Name]:
+			verr.add("%s: duplicate service name", at)
+		}
+		names[svc.Name] = true
+
+		route := strings.ToUpper(svc.MNO) + "/" + strings.ToUpper(svc.Group)
+		if other, ok := routes[route]; ok {
+			verr.add("%s: mno %q and group %q are already served by %s", at, svc.MNO, svc.Group, other)
+		}
+		routes[route] = at
+
+		if svc.BaseURL == "" {
+			verr.add("%s: base_url is required", at)
+		} else if !strings.HasPrefix(svc.BaseURL, "http://") && !strings.HasPrefix(svc.BaseURL, "https://") {
+			verr.add("%s: base_url %q must start with http:// or https://", at, svc.BaseURL)
+		}
+
+		if svc.Timeout < 0 {
+			verr.add("%s: timeout must not be negative", at)
+		}
+
+		switch strings.ToLower(svc.Auth.Scheme) {
+		case "", AuthNone:
+		case AuthBasic:
+			if svc.Auth.Username == "" {
+				verr.add("%s: auth.username is required for basic auth", at)
+			}
+		case AuthBearer:
+			if svc.Auth.Token == "" {
+				verr.add("%s: auth.token is required for bearer auth", at)
+			}
+		case AuthAPIKey:
+			if svc.Auth.Key == "" {
+				verr.add("%s: auth.key is required for api_key auth", at)
+			}
+		default:
+			verr.add("%s: unknown auth.scheme %q", at, svc.Auth.Scheme)
+		}
+
+		t := svc.TLS
+		if t.CACert != "" && t.CACertFile != "" {
+			verr.add("%s: tls.ca_cert and tls.ca_cert_file are mutually exclusive", at)
+		}
+		hasCert, hasKey := t.Cert != "" || t.CertFile != "", t.Key != "" || t.KeyFile != ""
+		if hasCert != hasKey {
+			verr.add("%s: tls client certificate and key must be set together", at)
+		}
+
+		if rl := svc.RateLimit; rl != nil && (rl.Limit <= 0 || rl.Per <= 0) {
+			verr.add("%s: rate_limit.limit and rate_limit.per must be positive", at)
+		}
+
+		endpoints := make(map[string]bool)
+		for j, ep := range svc.Endpoints {
+			eat := fmt.Sprintf("%s.endpoints[%d]", at, j)
+			switch {
+			case ep.Name == "":
+				verr.add("%s: name is required", eat)
+			case endpoints[ep.Name]:
+				verr.add("%s: duplicate endpoint name %q", eat, ep.Name)
+			}
+			endpoints[ep.Name] = true
+			if ep.Path == "" {
+				verr.add("%s: path is required", eat)
+			}
+		}
+	}
+
+	if len(verr.Problems) > 0 {
+		return verr
+	}
+	return nil
+}
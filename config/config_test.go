@@ -0,0 +1,137 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package config
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const services = `
+services:
+  - name: vodacom-tz
+    mno: VODACOM
+    base_url: https://vodacom.example.com
+    timeout: 30s
+    auth:
+      scheme: basic
+      username: ${env:CONFIG_TEST_USERNAME}
+      password: ${file:%s}
+    rate_limit:
+      limit: 10
+      per: 1s
+    endpoints:
+      - name: push
+        path: /ussd/push
+        method: post
+        content_type: application/xml
+  - name: airtel-tz
+    mno: AIRTEL
+    base_url: https://airtel.example.com
+    auth:
+      scheme: api_key
+      key: secret-key
+    endpoints:
+      - name: balance
+        path: /balance
+`
+
+func TestDecode(t *testing.T) {
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(passwordFile, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Setenv("CONFIG_TEST_USERNAME", "voda"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv("CONFIG_TEST_USERNAME")
+
+	cfg, err := Decode(strings.NewReader(strings.Replace(services, "%s", passwordFile, 1)), YAML)
+	if err != nil {
+		t.Fatalf("Decode() unexpected error: %v", err)
+	}
+
+	auth := cfg.Services[0].Auth
+	if auth.Username != "voda" || auth.Password != "s3cret" {
+		t.Errorf("secrets not resolved: username=%q password=%q", auth.Username, auth.Password)
+	}
+
+	built, err := cfg.Build()
+	if err != nil {
+		t.Fatalf("Build() unexpected error: %v", err)
+	}
+
+	ep, err := built.Endpoint("vodacom-tz", "push")
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := ep.Request(nil)
+	if request.URL != "https://vodacom.example.com/ussd/push" || request.Method != http.MethodPost {
+		t.Errorf("Request() = %s %s", request.Method, request.URL)
+	}
+	if request.Headers["Content-Type"] != "application/xml" || request.MNO != "VODACOM" {
+		t.Errorf("Request() headers = %v mno = %s", request.Headers, request.MNO)
+	}
+
+	route, err := built.Router().Route("airtel", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if route.Headers["X-API-Key"] != "secret-key" {
+		t.Errorf("api key header not set: %v", route.Headers)
+	}
+}
+
+func TestDecode_ValidationError(t *testing.T) {
+	invalid := `{"services":[
+		{"name":"a","base_url":"ftp://a","auth":{"scheme":"basic"}},
+		{"name":"a","base_url":"https://b","auth":{"token":"${env:CONFIG_TEST_UNSET}"},"endpoints":[{"name":"x"}]}
+	]}`
+
+	_, err := Decode(strings.NewReader(invalid), JSON)
+	verr := new(ValidationError)
+	if !errors.As(err, &verr) {
+		t.Fatalf("Decode() error = %v, want *ValidationError", err)
+	}
+	if len(verr.Problems) != 1 || !strings.Contains(verr.Problems[0], "CONFIG_TEST_UNSET") {
+		t.Errorf("unexpected secret problems: %v", verr.Problems)
+	}
+
+	_ = os.Setenv("CONFIG_TEST_UNSET", "token")
+	defer os.Unsetenv("CONFIG_TEST_UNSET")
+	_, err = Decode(strings.NewReader(invalid), JSON)
+	if !errors.As(err, &verr) {
+		t.Fatalf("Decode() error = %v, want *ValidationError", err)
+	}
+	// base_url scheme, basic username, duplicate name, duplicate route, endpoint path
+	if len(verr.Problems) != 5 {
+		t.Errorf("got %d problems, want 5:\n%v", len(verr.Problems), err)
+	}
+}
@@ -0,0 +1,107 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var secretRef = regexp.MustCompile(`\$\{(env|file):([^}]+)}`)
+
+// resolve replaces the ${env:NAME} and ${file:PATH} references in the
+// string values of the Config
+func (c *Config) resolve() error {
+	verr := new(ValidationError)
+	for i := range c.Services {
+		svc := &c.Services[i]
+		at := fmt.Sprintf("services[%d]", i)
+		fields := []struct {
+			name  string
+			value *string
+		}{
+			{"base_url", &svc.BaseURL},
+			{"auth.username", &svc.Auth.Username},
+			{"auth.password", &svc.Auth.Password},
+			{"auth.token", &svc.Auth.Token},
+			{"auth.key", &svc.Auth.Key},
+			{"tls.ca_cert", &svc.TLS.CACert},
+			{"tls.ca_cert_file", &svc.TLS.CACertFile},
+			{"tls.cert", &svc.TLS.Cert},
+			{"tls.cert_file", &svc.TLS.CertFile},
+			{"tls.key", &svc.TLS.Key},
+			{"tls.key_file", &svc.TLS.KeyFile},
+		}
+		for _, field := range fields {
+			value, err := expand(*field.value)
+			if err != nil {
+				verr.add("%s: %s: %v", at, field.name, err)
+				continue
+			}
+			*field.value = value
+		}
+
+		for key, header := range svc.Headers {
+			value, err := expand(header)
+			if err != nil {
+				verr.add("%s: headers.%s: %v", at, key, err)
+				continue
+			}
+			svc.Headers[key] = value
+		}
+	}
+
+	if len(verr.Problems) > 0 {
+		return verr
+	}
+	return nil
+}
+
+func expand(value string) (string, error) {
+	var err error
+	expanded := secretRef.ReplaceAllStringFunc(value, func(ref string) string {
+		match := secretRef.FindStringSubmatch(ref)
+		kind, name := match[1], strings.TrimSpace(match[2])
+		switch kind {
+		case "env":
+			v, ok := os.LookupEnv(name)
+			if !ok && err == nil {
+				err = fmt.Errorf("environment variable %s is not set", name)
+			}
+			return v
+		default:
+			b, rErr := os.ReadFile(name)
+			if rErr != nil && err == nil {
+				err = fmt.Errorf("could not read secret file: %w", rErr)
+			}
+			return strings.TrimRight(string(b), "\r\n")
+		}
+	})
+
+	return expanded, err
+}
@@ -0,0 +1,301 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package base
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrCorrelationTimeout is the Correlation.Err of operations that did
+	// not get a callback in time
+	ErrCorrelationTimeout = errors.New("no callback received before the timeout")
+
+	// ErrCorrelationCanceled is the Correlation.Err of operations removed
+	// with Correlator.Cancel
+	ErrCorrelationCanceled = errors.New("pending operation canceled")
+
+	// ErrDuplicateReference is returned when registering a reference that
+	// is already pending
+	ErrDuplicateReference = errors.New("reference is already pending")
+
+	// ErrNoReference is returned by extractors that could not find the reference
+	ErrNoReference = errors.New("reference not found")
+)
+
+type (
+	// ReferenceExtractor returns the reference that links a callback to the
+	// operation that caused it. payload is the value decoded by Receiver.Receive
+	ReferenceExtractor func(receipt *Receipt, payload interface{}) (string, error)
+
+	// PayloadExtractor returns the reference of an outgoing Request.Payload
+	PayloadExtractor func(payload interface{}) (string, error)
+
+	// Correlation is the outcome of a pending operation. Err is nil when the
+	// callback arrived, ErrCorrelationTimeout or ErrCorrelationCanceled otherwise
+	Correlation struct {
+		Reference string
+		Receipt   *Receipt
+		Payload   interface{}
+		Err       error
+	}
+
+	// Pending is a future of an operation waiting for its callback
+	Pending struct {
+		reference string
+		done      chan struct{}
+		result    *Correlation
+		timer     *time.Timer
+	}
+
+	// Correlator links outgoing requests sent with Client.Do to the callbacks
+	// received later with Receiver.Receive. Operations are registered with a
+	// reference taken from the request payload and callbacks are matched using
+	// the ReferenceExtractor. Operations that do not get a callback within the
+	// timeout are resolved with ErrCorrelationTimeout.
+	Correlator struct {
+		mu        sync.Mutex
+		extractor ReferenceExtractor
+		timeout   time.Duration
+		pending   map[string]*Pending
+	}
+)
+
+func NewCorrelator(extractor ReferenceExtractor, timeout time.Duration) *Correlator {
+	return &Correlator{
+		mu:        sync.Mutex{},
+		extractor: extractor,
+		timeout:   timeout,
+		pending:   make(map[string]*Pending),
+	}
+}
+
+// Register adds a pending operation identified by reference
+func (c *Correlator) Register(reference string) (*Pending, error) {
+	if reference == "" {
+		return nil, ErrNoReference
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.pending[reference]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrDuplicateReference, reference)
+	}
+
+	p := &Pending{
+		reference: reference,
+		done:      make(chan struct{}),
+	}
+	if c.timeout > 0 {
+		p.timer = time.AfterFunc(c.timeout, func() {
+			c.resolve(&Correlation{Reference: reference, Err: ErrCorrelationTimeout})
+		})
+	}
+	c.pending[reference] = p
+
+	return p, nil
+}
+
+// Track is Register with the reference extracted from request.Payload. Call it
+// before Client.Do so that a fast callback is not missed
+func (c *Correlator) Track(request *Request, extract PayloadExtractor) (*Pending, error) {
+	reference, err := extract(request.Payload)
+	if err != nil {
+		return nil, err
+	}
+	return c.Register(reference)
+}
+
+// Match resolves the pending operation of the callback. It is meant to be called
+// with the results of Receiver.Receive. It returns false when the callback is not
+// linked to any pending operation e.g. the operation has already timed out.
+func (c *Correlator) Match(receipt *Receipt, payload interface{}) (bool, error) {
+	reference, err := c.extractor(receipt, payload)
+	if err != nil {
+		return false, err
+	}
+
+	return c.resolve(&Correlation{
+		Reference: reference,
+		Receipt:   receipt,
+		Payload:   payload,
+	}), nil
+}
+
+// Cancel resolves the pending operation with ErrCorrelationCanceled
+func (c *Correlator) Cancel(reference string) bool {
+	return c.resolve(&Correlation{Reference: reference, Err: ErrCorrelationCanceled})
+}
+
+// Len returns the number of pending operations
+func (c *Correlator) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.pending)
+}
+
+func (c *Correlator) resolve(result *Correlation) bool {
+	c.mu.Lock()
+	p, ok := c.pending[result.Reference]
+	if ok {
+		delete(c.pending, result.Reference)
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	p.result = result
+	close(p.done)
+	return true
+}
+
+func (p *Pending) Reference() string {
+	return p.reference
+}
+
+// Done is closed when the operation is resolved
+func (p *Pending) Done() <-chan struct{} {
+	return p.done
+}
+
+// Result returns the Correlation once Done is closed and nil before that
+func (p *Pending) Result() *Correlation {
+	select {
+	case <-p.done:
+		return p.result
+	default:
+		return nil
+	}
+}
+
+// Wait blocks until the operation is resolved or ctx is done. The returned
+// error is the Correlation.Err or the ctx error.
+func (p *Pending) Wait(ctx context.Context) (*Correlation, error) {
+	select {
+	case <-p.done:
+		return p.result, p.result.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// FromHeader extracts the reference from the header of the callback request
+func FromHeader(name string) ReferenceExtractor {
+	return func(receipt *Receipt, _ interface{}) (string, error) {
+		if receipt == nil || receipt.Request == nil {
+			return "", ErrNoReference
+		}
+		if v := receipt.Request.Header.Get(name); v != "" {
+			return v, nil
+		}
+		return "", fmt.Errorf("%w: header %s", ErrNoReference, name)
+	}
+}
+
+// FromQuery extracts the reference from the query parameters of the callback request
+func FromQuery(name string) ReferenceExtractor {
+	return func(receipt *Receipt, _ interface{}) (string, error) {
+		if receipt == nil || receipt.Request == nil {
+			return "", ErrNoReference
+		}
+		if v := receipt.Request.URL.Query().Get(name); v != "" {
+			return v, nil
+		}
+		return "", fmt.Errorf("%w: query parameter %s", ErrNoReference, name)
+	}
+}
+
+// FromField extracts the reference from the decoded callback payload, see Field
+func FromField(path string) ReferenceExtractor {
+	field := Field(path)
+	return func(_ *Receipt, payload interface{}) (string, error) {
+		return field(payload)
+	}
+}
+
+// FirstOf tries the extractors in order and returns the first reference found
+func FirstOf(extractors ...ReferenceExtractor) ReferenceExtractor {
+	return func(receipt *Receipt, payload interface{}) (string, error) {
+		err := ErrNoReference
+		for _, extract := range extractors {
+			var ref string
+			ref, err = extract(receipt, payload)
+			if err == nil && ref != "" {
+				return ref, nil
+			}
+		}
+		return "", err
+	}
+}
+
+// Field returns a PayloadExtractor that reads the value at the dot separated
+// path of the JSON representation of the payload e.g. "transaction.id"
+func Field(path string) PayloadExtractor {
+	keys := strings.Split(path, ".")
+	return func(payload interface{}) (string, error) {
+		buf, err := json.Marshal(payload)
+		if err != nil {
+			return "", err
+		}
+
+		var node interface{}
+		decoder := json.NewDecoder(bytes.NewReader(buf))
+		decoder.UseNumber()
+		if err := decoder.Decode(&node); err != nil {
+			return "", err
+		}
+
+		for _, key := range keys {
+			object, ok := node.(map[string]interface{})
+			if !ok {
+				return "", fmt.Errorf("%w: field %s", ErrNoReference, path)
+			}
+			if node, ok = object[key]; !ok {
+				return "", fmt.Errorf("%w: field %s", ErrNoReference, path)
+			}
+		}
+
+		switch v := node.(type) {
+		case string:
+			return v, nil
+		case json.Number:
+			return v.String(), nil
+		}
+		return "", fmt.Errorf("%w: field %s is not a string or a number", ErrNoReference, path)
+	}
+}
@@ -0,0 +1,85 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package base
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCorrelator(t *testing.T) {
+	type payment struct {
+		Transaction struct {
+			ID     string `json:"id"`
+			Amount int    `json:"amount"`
+		} `json:"transaction"`
+	}
+
+	correlator := NewCorrelator(FirstOf(FromHeader("X-Reference"), FromField("transaction.id")), time.Minute)
+
+	p := payment{}
+	p.Transaction.ID = "TX-1"
+	pending, err := correlator.Track(NewRequest("pay", http.MethodPost, "", p), Field("transaction.id"))
+	if err != nil {
+		t.Fatalf("Track() unexpected error: %v", err)
+	}
+	if _, err := correlator.Register("TX-1"); !errors.Is(err, ErrDuplicateReference) {
+		t.Errorf("Register() error = %v, want %v", err, ErrDuplicateReference)
+	}
+	if pending.Result() != nil {
+		t.Errorf("Result() before the callback should be nil")
+	}
+
+	callback := map[string]interface{}{"transaction": map[string]interface{}{"id": "TX-1"}}
+	receipt := &Receipt{Request: httptest.NewRequest(http.MethodPost, "/callback", nil)}
+	matched, err := correlator.Match(receipt, callback)
+	if err != nil || !matched {
+		t.Fatalf("Match() = %v, %v want true, nil", matched, err)
+	}
+
+	result, err := pending.Wait(context.TODO())
+	if err != nil || result.Receipt != receipt {
+		t.Errorf("Wait() = %+v, %v", result, err)
+	}
+	if correlator.Len() != 0 {
+		t.Errorf("Len() = %d, want 0", correlator.Len())
+	}
+
+	// operations without a callback time out
+	timeout := NewCorrelator(FromHeader("X-Reference"), 10*time.Millisecond)
+	pending, _ = timeout.Register("42")
+	if _, err := pending.Wait(context.TODO()); !errors.Is(err, ErrCorrelationTimeout) {
+		t.Errorf("Wait() error = %v, want %v", err, ErrCorrelationTimeout)
+	}
+	receipt.Request.Header.Set("X-Reference", "42")
+	if matched, _ := timeout.Match(receipt, nil); matched {
+		t.Errorf("Match() after timeout should be false")
+	}
+}
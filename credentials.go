@@ -0,0 +1,68 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package base
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/techcraftlabs/base/secrets"
+)
+
+// BasicAuthFromSecrets returns a RequestModifier that sets the basic auth
+// credentials to the secrets username and password of provider. They are
+// read for every request, use a *secrets.Cache to pick up rotated
+// credentials without reading them every time.
+func BasicAuthFromSecrets(provider secrets.Provider, username, password string) RequestModifier {
+	return func(request *http.Request) error {
+		ctx := request.Context()
+		user, err := provider.Secret(ctx, username)
+		if err != nil {
+			return fmt.Errorf("basic auth username: %w", err)
+		}
+		pass, err := provider.Secret(ctx, password)
+		if err != nil {
+			return fmt.Errorf("basic auth password: %w", err)
+		}
+		request.SetBasicAuth(string(user), string(pass))
+		return nil
+	}
+}
+
+// HeaderFromSecret returns a RequestModifier that sets header to prefix
+// followed by the secret name of provider, e.g. an API key with
+// HeaderFromSecret(provider, "X-API-Key", "", "api-key") or a token with
+// HeaderFromSecret(provider, "Authorization", "Bearer ", "token")
+func HeaderFromSecret(provider secrets.Provider, header, prefix, name string) RequestModifier {
+	return func(request *http.Request) error {
+		value, err := provider.Secret(request.Context(), name)
+		if err != nil {
+			return fmt.Errorf("header %s: %w", header, err)
+		}
+		request.Header.Set(header, prefix+string(value))
+		return nil
+	}
+}
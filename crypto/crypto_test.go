@@ -0,0 +1,181 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestParseKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkixDER, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	ecPKIX, _ := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(rsaKey)
+	ecDER, _ := x509.MarshalECPrivateKey(ecKey)
+	cert := selfSigned(t, rsaKey)
+	pemOf := func(blockType string, der []byte) []byte {
+		return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	}
+
+	publicKeys := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{"pem pkix", pemOf("PUBLIC KEY", pkixDER), nil},
+		{"pem pkcs1", pemOf("RSA PUBLIC KEY", x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)), nil},
+		{"pem certificate", pemOf("CERTIFICATE", cert), nil},
+		{"der pkix", pkixDER, nil},
+		{"der certificate", cert, nil},
+		{"base64 der", []byte(base64.StdEncoding.EncodeToString(pkixDER)), nil},
+		{"ecdsa", pemOf("PUBLIC KEY", ecPKIX), ErrKeyType},
+		{"garbage", []byte("not a key"), ErrInvalidKey},
+		{"empty", nil, ErrInvalidKey},
+	}
+	for _, tt := range publicKeys {
+		t.Run("public "+tt.name, func(t *testing.T) {
+			key, err := ParseRSAPublicKey(tt.data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseRSAPublicKey() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && key.N.Cmp(rsaKey.N) != 0 {
+				t.Errorf("ParseRSAPublicKey() returned another key")
+			}
+		})
+	}
+
+	privateKeys := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{"pem pkcs8", pemOf("PRIVATE KEY", pkcs8), nil},
+		{"pem pkcs1", pemOf("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey)), nil},
+		{"pem ec", pemOf("EC PRIVATE KEY", ecDER), nil},
+		{"der ec", ecDER, nil},
+		{"base64 pkcs8", []byte(base64.StdEncoding.EncodeToString(pkcs8)), nil},
+		{"encrypted", pemOf("ENCRYPTED PRIVATE KEY", pkcs8), ErrEncryptedKey},
+		{"legacy encrypted", pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey),
+			Headers: map[string]string{"Proc-Type": "4,ENCRYPTED", "DEK-Info": "AES-128-CBC,00112233445566778899AABBCCDDEEFF"}}), ErrEncryptedKey},
+		{"public key", pemOf("PUBLIC KEY", pkixDER), ErrInvalidKey},
+	}
+	for _, tt := range privateKeys {
+		t.Run("private "+tt.name, func(t *testing.T) {
+			if _, err := ParsePrivateKey(tt.data); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ParsePrivateKey() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEncryptSign(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("1234")
+
+	for _, padding := range []Padding{PKCS1v15, OAEPSHA256, OAEPSHA1} {
+		ciphertext, err := EncryptRSA(&rsaKey.PublicKey, padding, msg)
+		if err != nil {
+			t.Fatalf("EncryptRSA(%v) error = %v", padding, err)
+		}
+		if plaintext, err := DecryptRSA(rsaKey, padding, ciphertext); err != nil || string(plaintext) != "1234" {
+			t.Errorf("DecryptRSA(%v) = %q, %v", padding, plaintext, err)
+		}
+		ciphertext[0] ^= 0xff
+		if _, err := DecryptRSA(rsaKey, padding, ciphertext); !errors.Is(err, ErrDecrypt) {
+			t.Errorf("DecryptRSA(%v) of tampered ciphertext error = %v, want ErrDecrypt", padding, err)
+		}
+	}
+
+	schemes := []struct {
+		scheme Scheme
+		key    crypto.Signer
+	}{
+		{SignPKCS1v15SHA256, rsaKey},
+		{SignPSSSHA256, rsaKey},
+		{SignECDSASHA256, ecKey},
+	}
+	for _, tt := range schemes {
+		t.Run(tt.scheme.String(), func(t *testing.T) {
+			pub := tt.key.Public()
+			sig, err := Sign(tt.key, tt.scheme, msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := Verify(pub, tt.scheme, msg, sig); err != nil {
+				t.Errorf("Verify() error = %v", err)
+			}
+			if err := Verify(pub, tt.scheme, []byte("4321"), sig); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("Verify() of another message error = %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+
+	if _, err := Sign(ecKey, SignPSSSHA256, msg); !errors.Is(err, ErrKeyType) {
+		t.Errorf("Sign() with an ECDSA key for PS256 error = %v, want ErrKeyType", err)
+	}
+}
+
+func selfSigned(t *testing.T, key *rsa.PrivateKey) []byte {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "openapi.example.com"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
@@ -0,0 +1,343 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// DataKeySize is the size of the AES-256 data keys of NewDataKey
+const DataKeySize = 32
+
+// EncryptTag is the struct tag of the string fields encrypted by
+// EncryptFields, any value but "-" marks the field, e.g. `encrypt:"true"`
+const EncryptTag = "encrypt"
+
+var ErrInvalidDataKey = errors.New("crypto: invalid data key")
+
+var _ FieldCipher = (*Envelope)(nil)
+
+type (
+	// FieldCipher encrypts and decrypts the values of single fields
+	FieldCipher interface {
+		EncryptField(plaintext string) (string, error)
+		DecryptField(ciphertext string) (string, error)
+	}
+
+	// Envelope encrypts fields with AES-GCM under a data key that is sent
+	// to the provider wrapped with its RSA public key
+	Envelope struct {
+		key     []byte
+		aead    cipher.AEAD
+		wrapped []byte
+	}
+)
+
+// NewDataKey returns a random AES-256 key
+func NewDataKey() ([]byte, error) {
+	key := make([]byte, DataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// SealAESGCM encrypts plaintext with the AES key, 16, 24 or 32 bytes long.
+// The random nonce is prepended to the ciphertext.
+func SealAESGCM(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return seal(aead, plaintext, additionalData)
+}
+
+// OpenAESGCM decrypts what SealAESGCM returned
+func OpenAESGCM(key, sealed, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return open(aead, sealed, additionalData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDataKey, err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrEncrypt, err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize()+aead.Overhead() {
+		return nil, fmt.Errorf("%w: ciphertext is too short", ErrDecrypt)
+	}
+	n := aead.NonceSize()
+	plaintext, err := aead.Open(nil, sealed[:n], sealed[n:], additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+	return plaintext, nil
+}
+
+// NewEnvelope generates a data key and wraps it with the provider's public key
+func NewEnvelope(pub *rsa.PublicKey, padding Padding) (*Envelope, error) {
+	key, err := NewDataKey()
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := EncryptRSA(pub, padding, key)
+	if err != nil {
+		return nil, err
+	}
+	env, err := NewEnvelopeWithKey(key)
+	if err != nil {
+		return nil, err
+	}
+	env.wrapped = wrapped
+	return env, nil
+}
+
+// OpenEnvelope unwraps a data key with the private key, it is the decryption
+// path of callbacks whose fields are encrypted with a wrapped data key. The
+// wrapped key is base64 encoded, as returned by Envelope.WrappedKey.
+func OpenEnvelope(priv *rsa.PrivateKey, padding Padding, wrappedKey string) (*Envelope, error) {
+	wrapped, err := base64.StdEncoding.DecodeString(wrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: wrapped key: %v", ErrDecrypt, err)
+	}
+	key, err := DecryptRSA(priv, padding, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	env, err := NewEnvelopeWithKey(key)
+	if err != nil {
+		return nil, err
+	}
+	env.wrapped = wrapped
+	return env, nil
+}
+
+// NewEnvelopeWithKey returns an Envelope with a known data key and no
+// wrapped key, e.g. a session key agreed with the provider
+func NewEnvelopeWithKey(key []byte) (*Envelope, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{key: key, aead: aead}, nil
+}
+
+// WrappedKey returns the base64 encoded data key encrypted with the public
+// key of NewEnvelope, empty for NewEnvelopeWithKey
+func (e *Envelope) WrappedKey() string {
+	if len(e.wrapped) == 0 {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(e.wrapped)
+}
+
+// Seal encrypts plaintext with the data key, see SealAESGCM
+func (e *Envelope) Seal(plaintext, additionalData []byte) ([]byte, error) {
+	return seal(e.aead, plaintext, additionalData)
+}
+
+// Open decrypts what Seal returned
+func (e *Envelope) Open(sealed, additionalData []byte) ([]byte, error) {
+	return open(e.aead, sealed, additionalData)
+}
+
+// EncryptField returns the base64 encoded sealed plaintext
+func (e *Envelope) EncryptField(plaintext string) (string, error) {
+	sealed, err := e.Seal([]byte(plaintext), nil)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptField decrypts what EncryptField returned
+func (e *Envelope) DecryptField(ciphertext string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+	plaintext, err := e.Open(sealed, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// EncryptFields encrypts in place the string fields of the struct v points to
+// that are tagged with EncryptTag. Nested structs, pointers to them and their
+// slices are walked too, empty values are left as they are.
+func EncryptFields(v interface{}, c FieldCipher) error {
+	return walkFields(v, c.EncryptField)
+}
+
+// DecryptFields decrypts in place the fields encrypted by EncryptFields,
+// e.g. those of a decoded callback
+func DecryptFields(v interface{}, c FieldCipher) error {
+	return walkFields(v, c.DecryptField)
+}
+
+// EncryptedCopy returns a pointer to a copy of the struct v, or v points to,
+// with its tagged fields encrypted, v is not modified
+func EncryptedCopy(v interface{}, c FieldCipher) (interface{}, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: %T is not a struct", ErrEncrypt, v)
+	}
+	cp := reflect.New(rv.Type())
+	cp.Elem().Set(deepCopy(rv))
+	if err := EncryptFields(cp.Interface(), c); err != nil {
+		return nil, err
+	}
+	return cp.Interface(), nil
+}
+
+func walkFields(v interface{}, fn func(string) (string, error)) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("crypto: %T is not a pointer to a struct", v)
+	}
+	return walk(rv.Elem(), fn)
+}
+
+func walk(v reflect.Value, fn func(string) (string, error)) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return walk(v.Elem(), fn)
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := walk(v.Index(i), fn); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field, value := t.Field(i), v.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			tag, tagged := field.Tag.Lookup(EncryptTag)
+			if !tagged || tag == "-" {
+				if err := walk(value, fn); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if value.Kind() == reflect.Ptr {
+				if value.IsNil() {
+					continue
+				}
+				value = value.Elem()
+			}
+			if value.Kind() != reflect.String {
+				return fmt.Errorf("crypto: field %s.%s: only strings can be encrypted", t.Name(), field.Name)
+			}
+			if value.String() == "" {
+				continue
+			}
+			s, err := fn(value.String())
+			if err != nil {
+				return fmt.Errorf("field %s.%s: %w", t.Name(), field.Name, err)
+			}
+			value.SetString(s)
+		}
+	}
+	return nil
+}
+
+// deepCopy copies structs, pointers, slices and arrays so that walk on the
+// copy does not modify the original, other values are shared
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.New(v.Type().Elem())
+		cp.Elem().Set(deepCopy(v.Elem()))
+		return cp
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			cp.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return cp
+
+	case reflect.Array:
+		cp := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			cp.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return cp
+
+	case reflect.Struct:
+		cp := reflect.New(v.Type()).Elem()
+		cp.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath != "" {
+				continue
+			}
+			cp.Field(i).Set(deepCopy(v.Field(i)))
+		}
+		return cp
+	}
+	return v
+}
@@ -0,0 +1,111 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+)
+
+type (
+	cardHolder struct {
+		Name    string
+		Account string `encrypt:"true"`
+	}
+
+	transfer struct {
+		Reference string
+		PIN       string  `encrypt:"true"`
+		Amount    *string `encrypt:"true"`
+		Note      string  `encrypt:"-"`
+		From      cardHolder
+		To        []*cardHolder
+	}
+)
+
+func TestEnvelope(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := NewEnvelope(&priv.PublicKey, OAEPSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	amount := "1500.00"
+	original := &transfer{
+		Reference: "ref-1",
+		PIN:       "1234",
+		Amount:    &amount,
+		Note:      "salary",
+		From:      cardHolder{Name: "A", Account: "0011"},
+		To:        []*cardHolder{{Name: "B", Account: "0022"}, nil},
+	}
+
+	encrypted, err := EncryptedCopy(original, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if original.PIN != "1234" || *original.Amount != amount || original.To[0].Account != "0022" {
+		t.Fatalf("EncryptedCopy() modified the original: %+v", original)
+	}
+	sealed := encrypted.(*transfer)
+	if sealed.PIN == "1234" || *sealed.Amount == amount || sealed.From.Account == "0011" || sealed.To[0].Account == "0022" {
+		t.Fatalf("EncryptedCopy() left tagged fields in plaintext: %+v", sealed)
+	}
+	if sealed.Reference != "ref-1" || sealed.Note != "salary" || sealed.From.Name != "A" {
+		t.Fatalf("EncryptedCopy() changed untagged fields: %+v", sealed)
+	}
+
+	// the callback side unwraps the data key with the private key
+	opened, err := OpenEnvelope(priv, OAEPSHA256, env.WrappedKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := DecryptFields(sealed, opened); err != nil {
+		t.Fatal(err)
+	}
+	if sealed.PIN != "1234" || *sealed.Amount != amount || sealed.From.Account != "0011" || sealed.To[0].Account != "0022" {
+		t.Errorf("DecryptFields() = %+v", sealed)
+	}
+
+	// another data key must not decrypt the fields
+	other, _ := NewDataKey()
+	otherEnv, _ := NewEnvelopeWithKey(other)
+	field, _ := env.EncryptField("1234")
+	if _, err := otherEnv.DecryptField(field); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("DecryptField() with another key error = %v, want %v", err, ErrDecrypt)
+	}
+
+	if err := EncryptFields(&struct {
+		Amount int `encrypt:"true"`
+	}{1}, env); err == nil {
+		t.Error("EncryptFields() on an int field should fail")
+	}
+}
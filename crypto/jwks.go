@@ -0,0 +1,178 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+type (
+	// JWK is a JSON Web Key, RSA ("n", "e"), EC on P-256 ("crv", "x", "y")
+	// and symmetric ("k") keys are supported
+	JWK struct {
+		KeyType   string    `json:"kty"`
+		KeyID     string    `json:"kid,omitempty"`
+		Use       string    `json:"use,omitempty"`
+		Algorithm Algorithm `json:"alg,omitempty"`
+		N         string    `json:"n,omitempty"`
+		E         string    `json:"e,omitempty"`
+		Curve     string    `json:"crv,omitempty"`
+		X         string    `json:"x,omitempty"`
+		Y         string    `json:"y,omitempty"`
+		K         string    `json:"k,omitempty"`
+
+		key interface{}
+	}
+
+	// JWKS is a JSON Web Key Set
+	JWKS struct {
+		Keys []*JWK `json:"keys"`
+	}
+)
+
+// ParseJWKS parses a JSON Web Key Set, keys of unsupported types fail
+func ParseJWKS(data []byte) (*JWKS, error) {
+	set := new(JWKS)
+	if err := json.Unmarshal(data, set); err != nil {
+		return nil, fmt.Errorf("%w: jwks: %v", ErrInvalidKey, err)
+	}
+	for i, k := range set.Keys {
+		if err := k.parse(); err != nil {
+			return nil, fmt.Errorf("jwks key %d (kid %q): %w", i, k.KeyID, err)
+		}
+	}
+	return set, nil
+}
+
+// LoadJWKS reads and parses the JSON Web Key Set file at path
+func LoadJWKS(path string) (*JWKS, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseJWKS(data)
+}
+
+// Key returns the public key of k: a *rsa.PublicKey, a *ecdsa.PublicKey or
+// the []byte secret of a symmetric key
+func (k *JWK) Key() interface{} {
+	return k.key
+}
+
+func (k *JWK) parse() error {
+	switch k.KeyType {
+	case "RSA":
+		n, err := b64.DecodeString(k.N)
+		if err != nil || len(n) == 0 {
+			return fmt.Errorf("%w: RSA modulus", ErrInvalidKey)
+		}
+		e, err := b64.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return fmt.Errorf("%w: RSA exponent", ErrInvalidKey)
+		}
+		k.key = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+
+	case "EC":
+		if k.Curve != "P-256" {
+			return fmt.Errorf("%w: curve %q", ErrKeyType, k.Curve)
+		}
+		x, errX := b64.DecodeString(k.X)
+		y, errY := b64.DecodeString(k.Y)
+		if errX != nil || errY != nil {
+			return fmt.Errorf("%w: EC coordinates", ErrInvalidKey)
+		}
+		pub := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return fmt.Errorf("%w: point is not on P-256", ErrInvalidKey)
+		}
+		k.key = pub
+
+	case "oct":
+		secret, err := b64.DecodeString(k.K)
+		if err != nil || len(secret) == 0 {
+			return fmt.Errorf("%w: symmetric key", ErrInvalidKey)
+		}
+		k.key = secret
+
+	default:
+		return fmt.Errorf("%w: kty %q", ErrKeyType, k.KeyType)
+	}
+	return nil
+}
+
+// fits reports whether k can verify signatures made with alg
+func (k *JWK) fits(alg Algorithm) bool {
+	if k.Use != "" && k.Use != "sig" {
+		return false
+	}
+	if k.Algorithm != "" && k.Algorithm != alg {
+		return false
+	}
+	switch alg {
+	case HS256:
+		return k.KeyType == "oct"
+	case RS256, PS256:
+		return k.KeyType == "RSA"
+	case ES256:
+		return k.KeyType == "EC"
+	}
+	return false
+}
+
+// VerificationKey returns the key with the given ID that can verify alg.
+// Without kid the set must have a single such key.
+func (s *JWKS) VerificationKey(kid string, alg Algorithm) (interface{}, error) {
+	var found *JWK
+	for _, k := range s.Keys {
+		if !k.fits(alg) || (kid != "" && k.KeyID != kid) {
+			continue
+		}
+		if kid != "" {
+			return k.key, nil
+		}
+		if found != nil {
+			return nil, fmt.Errorf("%w: several %s keys and no kid", ErrKeyNotFound, alg)
+		}
+		found = k
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: kid %q for %s", ErrKeyNotFound, kid, alg)
+	}
+	return found.key, nil
+}
@@ -0,0 +1,241 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	HS256 Algorithm = "HS256"
+	RS256 Algorithm = "RS256"
+	PS256 Algorithm = "PS256"
+	ES256 Algorithm = "ES256"
+)
+
+var (
+	ErrInvalidToken         = errors.New("crypto: invalid token")
+	ErrUnsupportedAlgorithm = errors.New("crypto: unsupported algorithm")
+	ErrKeyNotFound          = errors.New("crypto: key not found")
+)
+
+var b64 = base64.RawURLEncoding
+
+type (
+	// Algorithm is a JWS "alg" header value
+	Algorithm string
+
+	// JWSHeader is the protected header of a JWS
+	JWSHeader struct {
+		Algorithm Algorithm `json:"alg"`
+		Type      string    `json:"typ,omitempty"`
+		KeyID     string    `json:"kid,omitempty"`
+	}
+
+	// KeySource returns the key that verifies a JWS with the given key ID and
+	// algorithm, kid is empty when the header has none. *JWKS is a KeySource.
+	KeySource interface {
+		VerificationKey(kid string, alg Algorithm) (interface{}, error)
+	}
+
+	// StaticKey is a KeySource with a single key: []byte for HS256, a
+	// *rsa.PublicKey for RS256 and PS256 and a *ecdsa.PublicKey for ES256
+	StaticKey struct {
+		Key interface{}
+	}
+)
+
+func (k StaticKey) VerificationKey(string, Algorithm) (interface{}, error) {
+	return k.Key, nil
+}
+
+// SignJWS returns the compact serialization of payload signed with key: []byte
+// for HS256, a *rsa.PrivateKey for RS256 and PS256 and a *ecdsa.PrivateKey on
+// P-256 for ES256
+func SignJWS(header JWSHeader, payload []byte, key interface{}) (string, error) {
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	input := b64.EncodeToString(h) + "." + b64.EncodeToString(payload)
+
+	sig, err := signJWS(header.Algorithm, key, []byte(input))
+	if err != nil {
+		return "", err
+	}
+	return input + "." + b64.EncodeToString(sig), nil
+}
+
+// VerifyJWS verifies the signature of a compact JWS with the key returned by
+// keys and returns its header and payload. Only the algorithms in allowed are
+// accepted, all the supported ones when it is empty, "none" never is.
+func VerifyJWS(token string, keys KeySource, allowed ...Algorithm) (*JWSHeader, []byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil, fmt.Errorf("%w: not a compact JWS", ErrInvalidToken)
+	}
+
+	headerJSON, err := b64.DecodeString(parts[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+	header := new(JWSHeader)
+	if err := json.Unmarshal(headerJSON, header); err != nil {
+		return nil, nil, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+	payload, err := b64.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: payload: %v", ErrInvalidToken, err)
+	}
+	sig, err := b64.DecodeString(parts[2])
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: signature: %v", ErrInvalidToken, err)
+	}
+
+	if !isAllowed(header.Algorithm, allowed) {
+		return nil, nil, fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, header.Algorithm)
+	}
+	key, err := keys.VerificationKey(header.KeyID, header.Algorithm)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := verifyJWS(header.Algorithm, key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, nil, err
+	}
+	return header, payload, nil
+}
+
+func isAllowed(alg Algorithm, allowed []Algorithm) bool {
+	switch alg {
+	case HS256, RS256, PS256, ES256:
+	default:
+		return false
+	}
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
+
+func signJWS(alg Algorithm, key interface{}, input []byte) ([]byte, error) {
+	switch alg {
+	case HS256:
+		secret, ok := key.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s needs a []byte key, got %T", ErrKeyType, alg, key)
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(input)
+		return mac.Sum(nil), nil
+
+	case RS256, PS256:
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s needs an RSA private key, got %T", ErrKeyType, alg, key)
+		}
+		scheme := SignPKCS1v15SHA256
+		if alg == PS256 {
+			scheme = SignPSSSHA256
+		}
+		return Sign(rsaKey, scheme, input)
+
+	case ES256:
+		ecKey, ok := key.(*ecdsa.PrivateKey)
+		if !ok || ecKey.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("%w: %s needs a P-256 ECDSA private key, got %T", ErrKeyType, alg, key)
+		}
+		digest := sha256.Sum256(input)
+		r, s, err := ecdsa.Sign(rand.Reader, ecKey, digest[:])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrSign, alg, err)
+		}
+		// JWS uses the fixed size R || S encoding, not ASN.1
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+		return sig, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, alg)
+}
+
+func verifyJWS(alg Algorithm, key interface{}, input, sig []byte) error {
+	switch alg {
+	case HS256:
+		secret, ok := key.([]byte)
+		if !ok {
+			return fmt.Errorf("%w: %s needs a []byte key, got %T", ErrKeyType, alg, key)
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(input)
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return fmt.Errorf("%w: %s", ErrInvalidSignature, alg)
+		}
+		return nil
+
+	case RS256, PS256:
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: %s needs an RSA public key, got %T", ErrKeyType, alg, key)
+		}
+		scheme := SignPKCS1v15SHA256
+		if alg == PS256 {
+			scheme = SignPSSSHA256
+		}
+		return Verify(rsaKey, scheme, input, sig)
+
+	case ES256:
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || ecKey.Curve != elliptic.P256() {
+			return fmt.Errorf("%w: %s needs a P-256 ECDSA public key, got %T", ErrKeyType, alg, key)
+		}
+		if len(sig) != 64 {
+			return fmt.Errorf("%w: %s", ErrInvalidSignature, alg)
+		}
+		digest := sha256.Sum256(input)
+		r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(ecKey, digest[:], r, s) {
+			return fmt.Errorf("%w: %s", ErrInvalidSignature, alg)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, alg)
+}
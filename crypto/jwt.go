@@ -0,0 +1,252 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package crypto
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrTokenExpired     = errors.New("crypto: token is expired")
+	ErrTokenNotYetValid = errors.New("crypto: token is not valid yet")
+	ErrInvalidAudience  = errors.New("crypto: invalid audience")
+	ErrInvalidIssuer    = errors.New("crypto: invalid issuer")
+)
+
+var registeredClaims = []string{"iss", "sub", "aud", "exp", "nbf", "iat", "jti"}
+
+type (
+	// Audience is the "aud" claim, a single audience is encoded as a string
+	Audience []string
+
+	// Claims of a JWT, the registered ones have their fields and the rest
+	// are kept in Extra. Times are encoded as seconds since the epoch.
+	Claims struct {
+		Issuer    string
+		Subject   string
+		Audience  Audience
+		ExpiresAt time.Time
+		NotBefore time.Time
+		IssuedAt  time.Time
+		ID        string
+		Extra     map[string]interface{}
+	}
+
+	// ValidationOptions of ParseJWT. Empty Issuer and Audience are not
+	// checked, Leeway is the allowed clock skew for exp, nbf and iat.
+	ValidationOptions struct {
+		Issuer     string
+		Audience   string
+		Leeway     time.Duration
+		Algorithms []Algorithm
+		Now        func() time.Time
+
+		// AllowNoExpiry accepts tokens without an exp claim, by default
+		// they are rejected
+		AllowNoExpiry bool
+	}
+)
+
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*a = Audience{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// Contains reports whether aud is one of the audiences
+func (a Audience) Contains(aud string) bool {
+	for _, s := range a {
+		if s == aud {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Claims) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{}, len(c.Extra)+len(registeredClaims))
+	for k, v := range c.Extra {
+		m[k] = v
+	}
+	setString := func(key, value string) {
+		if value != "" {
+			m[key] = value
+		}
+	}
+	setTime := func(key string, value time.Time) {
+		if !value.IsZero() {
+			m[key] = value.Unix()
+		}
+	}
+	setString("iss", c.Issuer)
+	setString("sub", c.Subject)
+	setString("jti", c.ID)
+	setTime("exp", c.ExpiresAt)
+	setTime("nbf", c.NotBefore)
+	setTime("iat", c.IssuedAt)
+	if len(c.Audience) > 0 {
+		m["aud"] = c.Audience
+	}
+	return json.Marshal(m)
+}
+
+func (c *Claims) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	var registered struct {
+		Issuer    string       `json:"iss"`
+		Subject   string       `json:"sub"`
+		Audience  Audience     `json:"aud"`
+		ExpiresAt *json.Number `json:"exp"`
+		NotBefore *json.Number `json:"nbf"`
+		IssuedAt  *json.Number `json:"iat"`
+		ID        string       `json:"jti"`
+	}
+	if err := json.Unmarshal(data, &registered); err != nil {
+		return err
+	}
+
+	*c = Claims{
+		Issuer:   registered.Issuer,
+		Subject:  registered.Subject,
+		Audience: registered.Audience,
+		ID:       registered.ID,
+	}
+	var err error
+	if c.ExpiresAt, err = numericDate(registered.ExpiresAt); err != nil {
+		return fmt.Errorf("exp: %w", err)
+	}
+	if c.NotBefore, err = numericDate(registered.NotBefore); err != nil {
+		return fmt.Errorf("nbf: %w", err)
+	}
+	if c.IssuedAt, err = numericDate(registered.IssuedAt); err != nil {
+		return fmt.Errorf("iat: %w", err)
+	}
+
+	for _, k := range registeredClaims {
+		delete(raw, k)
+	}
+	if len(raw) == 0 {
+		return nil
+	}
+	c.Extra = make(map[string]interface{}, len(raw))
+	for k, v := range raw {
+		var value interface{}
+		dec := json.NewDecoder(bytes.NewReader(v))
+		dec.UseNumber()
+		if err := dec.Decode(&value); err != nil {
+			return fmt.Errorf("%s: %w", k, err)
+		}
+		c.Extra[k] = value
+	}
+	return nil
+}
+
+func numericDate(n *json.Number) (time.Time, error) {
+	if n == nil {
+		return time.Time{}, nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(int64(f), 0), nil
+}
+
+// Validate checks the time based claims, the issuer and the audience
+func (c *Claims) Validate(opts ValidationOptions) error {
+	now := time.Now()
+	if opts.Now != nil {
+		now = opts.Now()
+	}
+	leeway := opts.Leeway
+
+	switch {
+	case c.ExpiresAt.IsZero() && !opts.AllowNoExpiry:
+		return fmt.Errorf("%w: exp claim is missing", ErrInvalidToken)
+	case !c.ExpiresAt.IsZero() && !now.Before(c.ExpiresAt.Add(leeway)):
+		return fmt.Errorf("%w: at %s", ErrTokenExpired, c.ExpiresAt.UTC().Format(time.RFC3339))
+	case !c.NotBefore.IsZero() && now.Add(leeway).Before(c.NotBefore):
+		return fmt.Errorf("%w: before %s", ErrTokenNotYetValid, c.NotBefore.UTC().Format(time.RFC3339))
+	case !c.IssuedAt.IsZero() && now.Add(leeway).Before(c.IssuedAt):
+		return fmt.Errorf("%w: issued in the future", ErrTokenNotYetValid)
+	}
+
+	if opts.Issuer != "" && c.Issuer != opts.Issuer {
+		return fmt.Errorf("%w: %q", ErrInvalidIssuer, c.Issuer)
+	}
+	if opts.Audience != "" && !c.Audience.Contains(opts.Audience) {
+		return fmt.Errorf("%w: %q is not in %v", ErrInvalidAudience, opts.Audience, []string(c.Audience))
+	}
+	return nil
+}
+
+// IssueJWT signs claims with key, see SignJWS for the keys of every algorithm
+func IssueJWT(alg Algorithm, kid string, claims Claims, key interface{}) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	return SignJWS(JWSHeader{Algorithm: alg, Type: "JWT", KeyID: kid}, payload, key)
+}
+
+// ParseJWT verifies the signature of token with the key returned by keys
+// and validates its claims
+func ParseJWT(token string, keys KeySource, opts ValidationOptions) (*Claims, error) {
+	_, payload, err := VerifyJWS(token, keys, opts.Algorithms...)
+	if err != nil {
+		return nil, err
+	}
+	claims := new(Claims)
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, fmt.Errorf("%w: claims: %v", ErrInvalidToken, err)
+	}
+	if err := claims.Validate(opts); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
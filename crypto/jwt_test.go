@@ -0,0 +1,175 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJWT_RoundTrip(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := []byte("0123456789abcdef0123456789abcdef")
+
+	jwks, err := ParseJWKS([]byte(fmt.Sprintf(`{"keys":[
+		{"kty":"RSA","kid":"rsa","n":%q,"e":%q},
+		{"kty":"EC","kid":"ec","crv":"P-256","x":%q,"y":%q},
+		{"kty":"oct","kid":"hmac","k":%q}]}`,
+		b64.EncodeToString(rsaKey.N.Bytes()), b64.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+		b64.EncodeToString(ecKey.X.Bytes()), b64.EncodeToString(ecKey.Y.Bytes()),
+		b64.EncodeToString(secret))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := Claims{
+		Issuer:    "gateway",
+		Subject:   "merchant-1",
+		Audience:  Audience{"https://api.example.com"},
+		ExpiresAt: time.Now().Add(time.Minute).Truncate(time.Second),
+		Extra:     map[string]interface{}{"scope": "c2b"},
+	}
+	opts := ValidationOptions{Issuer: "gateway", Audience: "https://api.example.com"}
+
+	tests := []struct {
+		alg     Algorithm
+		kid     string
+		signKey interface{}
+	}{
+		{HS256, "hmac", secret},
+		{RS256, "rsa", rsaKey},
+		{PS256, "rsa", rsaKey},
+		{ES256, "ec", ecKey},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.alg), func(t *testing.T) {
+			token, err := IssueJWT(tt.alg, tt.kid, claims, tt.signKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ParseJWT(token, jwks, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Subject != claims.Subject || !got.ExpiresAt.Equal(claims.ExpiresAt) || got.Extra["scope"] != "c2b" {
+				t.Errorf("ParseJWT() = %+v, want %+v", got, claims)
+			}
+
+			// a modified payload must not verify
+			parts := strings.Split(token, ".")
+			forged, _ := json.Marshal(map[string]interface{}{"iss": "gateway", "sub": "merchant-2"})
+			parts[1] = b64.EncodeToString(forged)
+			if _, err := ParseJWT(strings.Join(parts, "."), jwks, opts); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("ParseJWT(forged) error = %v, want %v", err, ErrInvalidSignature)
+			}
+
+			// the algorithm is restricted by the options
+			restricted := opts
+			restricted.Algorithms = []Algorithm{"none"}
+			if _, err := ParseJWT(token, jwks, restricted); !errors.Is(err, ErrUnsupportedAlgorithm) {
+				t.Errorf("ParseJWT(restricted) error = %v, want %v", err, ErrUnsupportedAlgorithm)
+			}
+		})
+	}
+
+	// an HS256 token signed with the RSA public key must not be accepted
+	confused, err := SignJWS(JWSHeader{Algorithm: HS256, KeyID: "rsa"}, []byte(`{}`), rsaKey.N.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseJWT(confused, jwks, ValidationOptions{}); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("ParseJWT(confused) error = %v, want %v", err, ErrKeyNotFound)
+	}
+}
+
+func TestClaims_Validate(t *testing.T) {
+	now := time.Unix(1635000000, 0)
+	at := func(d time.Duration) time.Time { return now.Add(d) }
+
+	tests := []struct {
+		name    string
+		claims  Claims
+		opts    ValidationOptions
+		wantErr error
+	}{
+		{"valid", Claims{ExpiresAt: at(time.Minute)}, ValidationOptions{}, nil},
+		{"expired", Claims{ExpiresAt: at(-time.Minute)}, ValidationOptions{}, ErrTokenExpired},
+		{"expired within leeway", Claims{ExpiresAt: at(-time.Minute)}, ValidationOptions{Leeway: 2 * time.Minute}, nil},
+		{"not yet valid", Claims{NotBefore: at(time.Minute)}, ValidationOptions{AllowNoExpiry: true}, ErrTokenNotYetValid},
+		{"issued in the future", Claims{IssuedAt: at(time.Hour)}, ValidationOptions{Leeway: time.Minute, AllowNoExpiry: true}, ErrTokenNotYetValid},
+		{"missing expiry", Claims{}, ValidationOptions{}, ErrInvalidToken},
+		{"missing expiry allowed", Claims{}, ValidationOptions{AllowNoExpiry: true}, nil},
+		{"issuer", Claims{Issuer: "a"}, ValidationOptions{Issuer: "b", AllowNoExpiry: true}, ErrInvalidIssuer},
+		{"audience", Claims{Audience: Audience{"a", "b"}}, ValidationOptions{Audience: "b", AllowNoExpiry: true}, nil},
+		{"wrong audience", Claims{Audience: Audience{"a"}}, ValidationOptions{Audience: "b", AllowNoExpiry: true}, ErrInvalidAudience},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Now = func() time.Time { return now }
+			err := tt.claims.Validate(tt.opts)
+			if tt.wantErr == nil && err != nil || !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClaims_JSON(t *testing.T) {
+	var c Claims
+	if err := json.Unmarshal([]byte(`{"aud":"one","exp":1635000000,"amount":1000}`), &c); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Audience) != 1 || c.Audience[0] != "one" || c.ExpiresAt.Unix() != 1635000000 {
+		t.Errorf("Unmarshal() = %+v", c)
+	}
+	if c.Extra["amount"] != json.Number("1000") {
+		t.Errorf("Extra[amount] = %#v, want json.Number", c.Extra["amount"])
+	}
+
+	b, err := json.Marshal(Claims{Audience: Audience{"a", "b"}, Subject: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"aud":["a","b"],"sub":"s"}`; string(b) != want {
+		t.Errorf("Marshal() = %s, want %s", b, want)
+	}
+}
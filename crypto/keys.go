@@ -0,0 +1,249 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var (
+	// ErrInvalidKey is returned when the key data can not be parsed
+	ErrInvalidKey = errors.New("crypto: invalid key")
+
+	// ErrKeyType is returned when a key is not of the expected type
+	ErrKeyType = errors.New("crypto: unexpected key type")
+
+	// ErrEncryptedKey is returned for password protected PEM keys
+	ErrEncryptedKey = errors.New("crypto: encrypted private keys are not supported")
+)
+
+// ParsePublicKey parses an RSA or ECDSA public key. data can be PEM (PUBLIC KEY,
+// RSA PUBLIC KEY or CERTIFICATE blocks), DER (PKIX, PKCS#1 or an X.509 certificate)
+// or base64 encoded DER. The returned key is a *rsa.PublicKey or a *ecdsa.PublicKey.
+func ParsePublicKey(data []byte) (crypto.PublicKey, error) {
+	der, blockType, err := decodeKeyData(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var key interface{}
+	switch blockType {
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(der)
+	case "CERTIFICATE":
+		key, err = publicKeyFromCertificate(der)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(der)
+	default:
+		key, err = parseFirst(der,
+			func(der []byte) (interface{}, error) { return x509.ParsePKIXPublicKey(der) },
+			func(der []byte) (interface{}, error) { return x509.ParsePKCS1PublicKey(der) },
+			publicKeyFromCertificate,
+		)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+
+	switch key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: %T is not an RSA or ECDSA public key", ErrKeyType, key)
+}
+
+// ParsePrivateKey parses an RSA or ECDSA private key. data can be PEM (PRIVATE KEY,
+// RSA PRIVATE KEY or EC PRIVATE KEY blocks), DER (PKCS#8, PKCS#1 or SEC 1) or
+// base64 encoded DER. The returned key is a *rsa.PrivateKey or a *ecdsa.PrivateKey.
+func ParsePrivateKey(data []byte) (crypto.Signer, error) {
+	der, blockType, err := decodeKeyData(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var key interface{}
+	switch blockType {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(der)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(der)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(der)
+	default:
+		key, err = parseFirst(der,
+			func(der []byte) (interface{}, error) { return x509.ParsePKCS8PrivateKey(der) },
+			func(der []byte) (interface{}, error) { return x509.ParsePKCS1PrivateKey(der) },
+			func(der []byte) (interface{}, error) { return x509.ParseECPrivateKey(der) },
+		)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k, nil
+	case *ecdsa.PrivateKey:
+		return k, nil
+	}
+	return nil, fmt.Errorf("%w: %T is not an RSA or ECDSA private key", ErrKeyType, key)
+}
+
+func ParseRSAPublicKey(data []byte) (*rsa.PublicKey, error) {
+	key, err := ParsePublicKey(data)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T is not an RSA public key", ErrKeyType, key)
+	}
+	return rsaKey, nil
+}
+
+func ParseRSAPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	key, err := ParsePrivateKey(data)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T is not an RSA private key", ErrKeyType, key)
+	}
+	return rsaKey, nil
+}
+
+func ParseECDSAPublicKey(data []byte) (*ecdsa.PublicKey, error) {
+	key, err := ParsePublicKey(data)
+	if err != nil {
+		return nil, err
+	}
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T is not an ECDSA public key", ErrKeyType, key)
+	}
+	return ecKey, nil
+}
+
+func ParseECDSAPrivateKey(data []byte) (*ecdsa.PrivateKey, error) {
+	key, err := ParsePrivateKey(data)
+	if err != nil {
+		return nil, err
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T is not an ECDSA private key", ErrKeyType, key)
+	}
+	return ecKey, nil
+}
+
+// LoadPublicKey reads and parses the public key file at path, see ParsePublicKey
+func LoadPublicKey(path string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("crypto: could not read public key: %w", err)
+	}
+	return ParsePublicKey(data)
+}
+
+// LoadPrivateKey reads and parses the private key file at path, see ParsePrivateKey
+func LoadPrivateKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("crypto: could not read private key: %w", err)
+	}
+	return ParsePrivateKey(data)
+}
+
+// decodeKeyData returns the DER bytes of data and the PEM block type when
+// data is PEM
+func decodeKeyData(data []byte) ([]byte, string, error) {
+	trimmed := strings.TrimSpace(string(data))
+	if trimmed == "" {
+		return nil, "", fmt.Errorf("%w: no key data", ErrInvalidKey)
+	}
+
+	if strings.HasPrefix(trimmed, "-----BEGIN") {
+		block, _ := pem.Decode([]byte(trimmed))
+		if block == nil {
+			return nil, "", fmt.Errorf("%w: malformed PEM", ErrInvalidKey)
+		}
+		// legacy (RFC 1423) encrypted PEM is only detected to be rejected
+		if procType := block.Headers["Proc-Type"]; strings.HasSuffix(strings.TrimSpace(procType), ",ENCRYPTED") {
+			return nil, "", fmt.Errorf("%w: legacy PEM encryption unsupported", ErrEncryptedKey)
+		}
+		if block.Type == "ENCRYPTED PRIVATE KEY" {
+			return nil, "", ErrEncryptedKey
+		}
+		return block.Bytes, block.Type, nil
+	}
+
+	// DER starts with an ASN.1 SEQUENCE tag
+	if data[0] == 0x30 {
+		return data, "", nil
+	}
+
+	compact := strings.Join(strings.Fields(trimmed), "")
+	for _, encoding := range []*base64.Encoding{
+		base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding,
+	} {
+		if der, err := encoding.DecodeString(compact); err == nil {
+			return der, "", nil
+		}
+	}
+	return nil, "", fmt.Errorf("%w: neither PEM, DER nor base64 encoded DER", ErrInvalidKey)
+}
+
+func parseFirst(der []byte, parsers ...func([]byte) (interface{}, error)) (interface{}, error) {
+	var firstErr error
+	for _, parse := range parsers {
+		key, err := parse(der)
+		if err == nil {
+			return key, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+func publicKeyFromCertificate(der []byte) (interface{}, error) {
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return cert.PublicKey, nil
+}
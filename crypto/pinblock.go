@@ -0,0 +1,411 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ISO 9564-1 PIN block formats. Formats 0 and 3 are bound to the PAN, format 1
+// is not, format 4 is bound to the PAN and enciphered with AES. Integrations that
+// bind PINs to the phone number use MSISDNToPAN.
+const (
+	ISO0 PINBlockFormat = 0
+	ISO1 PINBlockFormat = 1
+	ISO3 PINBlockFormat = 3
+	ISO4 PINBlockFormat = 4
+)
+
+var (
+	ErrInvalidPIN        = errors.New("crypto: pin must have 4 to 12 digits")
+	ErrInvalidPAN        = errors.New("crypto: invalid pan")
+	ErrInvalidPINBlock   = errors.New("crypto: invalid pin block")
+	ErrUnsupportedFormat = errors.New("crypto: unsupported pin block format")
+	ErrInvalidWorkingKey = errors.New("crypto: invalid working key")
+
+	errClearISO4 = fmt.Errorf("%w: format 4 only exists enciphered", ErrUnsupportedFormat)
+)
+
+type PINBlockFormat int
+
+func (f PINBlockFormat) String() string {
+	return fmt.Sprintf("ISO-%d", int(f))
+}
+
+// EncodePINBlock returns the clear 8 byte PIN block of format 0, 1 or 3. pan is
+// ignored by format 1.
+func EncodePINBlock(format PINBlockFormat, pin, pan string) ([]byte, error) {
+	return encodePINBlock(format, pin, pan, rand.Reader)
+}
+
+// encodePINBlock is EncodePINBlock with the source of the random fill digits
+func encodePINBlock(format PINBlockFormat, pin, pan string, random io.Reader) ([]byte, error) {
+	if err := validatePIN(pin); err != nil {
+		return nil, err
+	}
+
+	var fill func() (byte, error)
+	switch format {
+	case ISO0:
+		fill = func() (byte, error) { return 0xF, nil }
+	case ISO1:
+		fill = func() (byte, error) { return randomNibble(random, 0x0, 0xF) }
+	case ISO3:
+		fill = func() (byte, error) { return randomNibble(random, 0xA, 0xF) }
+	case ISO4:
+		return nil, errClearISO4
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedFormat, format)
+	}
+
+	nibbles, err := pinField(byte(format), pin, 16, fill)
+	if err != nil {
+		return nil, err
+	}
+	block := packNibbles(nibbles)
+
+	if format == ISO1 {
+		return block, nil
+	}
+	panField, err := panField(pan)
+	if err != nil {
+		return nil, err
+	}
+	return xor(block, panField), nil
+}
+
+// DecodePINBlock returns the PIN of a clear format 0, 1 or 3 PIN block
+func DecodePINBlock(format PINBlockFormat, block []byte, pan string) (string, error) {
+	if len(block) != 8 {
+		return "", fmt.Errorf("%w: %d bytes, want 8", ErrInvalidPINBlock, len(block))
+	}
+
+	var validFill func(byte) bool
+	switch format {
+	case ISO0:
+		validFill = func(n byte) bool { return n == 0xF }
+	case ISO1:
+		validFill = func(byte) bool { return true }
+	case ISO3:
+		validFill = func(n byte) bool { return n >= 0xA }
+	case ISO4:
+		return "", errClearISO4
+	default:
+		return "", fmt.Errorf("%w: %v", ErrUnsupportedFormat, format)
+	}
+
+	if format != ISO1 {
+		panField, err := panField(pan)
+		if err != nil {
+			return "", err
+		}
+		block = xor(block, panField)
+	}
+	return parsePINField(byte(format), unpackNibbles(block), validFill)
+}
+
+// EncryptPINBlock builds the PIN block and enciphers it with the working key,
+// 3DES (16 or 24 byte key) for formats 0, 1 and 3 and AES (16, 24 or 32 byte
+// key) for format 4
+func EncryptPINBlock(format PINBlockFormat, pin, pan string, key []byte) ([]byte, error) {
+	return encryptPINBlock(format, pin, pan, key, rand.Reader)
+}
+
+// encryptPINBlock is EncryptPINBlock with the source of the random fill
+// digits and of the format 4 random field
+func encryptPINBlock(format PINBlockFormat, pin, pan string, key []byte, random io.Reader) ([]byte, error) {
+	if format == ISO4 {
+		return encryptISO4(pin, pan, key, random)
+	}
+
+	block, err := encodePINBlock(format, pin, pan, random)
+	if err != nil {
+		return nil, err
+	}
+	c, err := tripleDES(key)
+	if err != nil {
+		return nil, err
+	}
+	c.Encrypt(block, block)
+	return block, nil
+}
+
+// DecryptPINBlock deciphers the PIN block made with EncryptPINBlock and
+// returns the PIN
+func DecryptPINBlock(format PINBlockFormat, block []byte, pan string, key []byte) (string, error) {
+	if format == ISO4 {
+		return decryptISO4(block, pan, key)
+	}
+
+	if len(block) != des.BlockSize {
+		return "", fmt.Errorf("%w: %d bytes, want 8", ErrInvalidPINBlock, len(block))
+	}
+	c, err := tripleDES(key)
+	if err != nil {
+		return "", err
+	}
+	clear := make([]byte, des.BlockSize)
+	c.Decrypt(clear, block)
+	return DecodePINBlock(format, clear, pan)
+}
+
+// encryptISO4 enciphers the plain text PIN field P with the PAN field A:
+// block = E(K, E(K, P) XOR A)
+func encryptISO4(pin, pan string, key []byte, random io.Reader) ([]byte, error) {
+	if err := validatePIN(pin); err != nil {
+		return nil, err
+	}
+	c, err := aesCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	panField, err := iso4PANField(pan)
+	if err != nil {
+		return nil, err
+	}
+
+	nibbles, err := pinField(4, pin, 16, func() (byte, error) { return 0xA, nil })
+	if err != nil {
+		return nil, err
+	}
+	field := make([]byte, 8)
+	if _, err := io.ReadFull(random, field); err != nil {
+		return nil, err
+	}
+	block := append(packNibbles(nibbles), field...)
+
+	c.Encrypt(block, block)
+	block = xor(block, panField)
+	c.Encrypt(block, block)
+	return block, nil
+}
+
+func decryptISO4(block []byte, pan string, key []byte) (string, error) {
+	if len(block) != aes.BlockSize {
+		return "", fmt.Errorf("%w: %d bytes, want 16", ErrInvalidPINBlock, len(block))
+	}
+	c, err := aesCipher(key)
+	if err != nil {
+		return "", err
+	}
+	panField, err := iso4PANField(pan)
+	if err != nil {
+		return "", err
+	}
+
+	clear := make([]byte, aes.BlockSize)
+	c.Decrypt(clear, block)
+	clear = xor(clear, panField)
+	c.Decrypt(clear, clear)
+	return parsePINField(4, unpackNibbles(clear[:8]), func(n byte) bool { return n == 0xA })
+}
+
+func validatePIN(pin string) error {
+	if len(pin) < 4 || len(pin) > 12 || !isDigits(pin) {
+		return ErrInvalidPIN
+	}
+	return nil
+}
+
+// pinField is control nibble, PIN length, PIN digits and fill nibbles
+func pinField(control byte, pin string, size int, fill func() (byte, error)) ([]byte, error) {
+	nibbles := make([]byte, 0, size)
+	nibbles = append(nibbles, control, byte(len(pin)))
+	for _, d := range pin {
+		nibbles = append(nibbles, byte(d-'0'))
+	}
+	for len(nibbles) < size {
+		n, err := fill()
+		if err != nil {
+			return nil, err
+		}
+		nibbles = append(nibbles, n)
+	}
+	return nibbles, nil
+}
+
+func parsePINField(control byte, nibbles []byte, validFill func(byte) bool) (string, error) {
+	if nibbles[0] != control {
+		return "", fmt.Errorf("%w: control field %X, want %X", ErrInvalidPINBlock, nibbles[0], control)
+	}
+	n := int(nibbles[1])
+	if n < 4 || n > 12 {
+		return "", fmt.Errorf("%w: pin length %d", ErrInvalidPINBlock, n)
+	}
+
+	var pin strings.Builder
+	for _, d := range nibbles[2 : 2+n] {
+		if d > 9 {
+			return "", fmt.Errorf("%w: pin digit %X", ErrInvalidPINBlock, d)
+		}
+		pin.WriteByte('0' + d)
+	}
+	for _, f := range nibbles[2+n:] {
+		if !validFill(f) {
+			return "", fmt.Errorf("%w: fill digit %X", ErrInvalidPINBlock, f)
+		}
+	}
+	return pin.String(), nil
+}
+
+// MSISDNToPAN turns an MSISDN into an account number usable as the PAN of a
+// PIN block by appending a Luhn check digit, so that every digit of the MSISDN
+// is bound to the PIN block (the check digit of a PAN is not)
+func MSISDNToPAN(msisdn string) string {
+	msisdn = normalizePAN(msisdn)
+	sum := 0
+	for i := len(msisdn) - 1; i >= 0; i-- {
+		d := int(msisdn[i] - '0')
+		// doubled digits are the ones at even positions from the right
+		// once the check digit is appended
+		if (len(msisdn)-1-i)%2 == 0 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return fmt.Sprintf("%s%d", msisdn, (10-sum%10)%10)
+}
+
+// panField is 0000 followed by the 12 rightmost digits of the PAN excluding
+// the check digit, shorter PANs are left padded with zeros
+func panField(pan string) ([]byte, error) {
+	pan = normalizePAN(pan)
+	if len(pan) < 2 || len(pan) > 19 || !isDigits(pan) {
+		return nil, fmt.Errorf("%w: %d digits", ErrInvalidPAN, len(pan))
+	}
+	if len(pan) < 13 {
+		pan = strings.Repeat("0", 13-len(pan)) + pan
+	}
+	digits := pan[len(pan)-13 : len(pan)-1]
+
+	nibbles := make([]byte, 16)
+	for i, d := range digits {
+		nibbles[4+i] = byte(d - '0')
+	}
+	return packNibbles(nibbles), nil
+}
+
+// iso4PANField is the PAN length minus 12, the PAN left justified (left
+// padded with zeros to 12 digits when shorter) and zeros
+func iso4PANField(pan string) ([]byte, error) {
+	pan = normalizePAN(pan)
+	if len(pan) == 0 || len(pan) > 19 || !isDigits(pan) {
+		return nil, fmt.Errorf("%w: %d digits", ErrInvalidPAN, len(pan))
+	}
+
+	m := 0
+	if len(pan) > 12 {
+		m = len(pan) - 12
+	} else {
+		pan = strings.Repeat("0", 12-len(pan)) + pan
+	}
+
+	nibbles := make([]byte, 32)
+	nibbles[0] = byte(m)
+	for i, d := range pan {
+		nibbles[1+i] = byte(d - '0')
+	}
+	return packNibbles(nibbles), nil
+}
+
+func normalizePAN(pan string) string {
+	return strings.TrimPrefix(strings.Join(strings.Fields(pan), ""), "+")
+}
+
+func tripleDES(key []byte) (cipher.Block, error) {
+	switch len(key) {
+	case 16:
+		key = append(append([]byte{}, key...), key[:8]...)
+	case 24:
+	default:
+		return nil, fmt.Errorf("%w: 3DES key of %d bytes, want 16 or 24", ErrInvalidWorkingKey, len(key))
+	}
+	return des.NewTripleDESCipher(key)
+}
+
+func aesCipher(key []byte) (cipher.Block, error) {
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidWorkingKey, err)
+	}
+	return c, nil
+}
+
+func randomNibble(random io.Reader, min, max byte) (byte, error) {
+	var b [1]byte
+	for {
+		if _, err := io.ReadFull(random, b[:]); err != nil {
+			return 0, err
+		}
+		// rejection sampling keeps the nibbles uniform
+		n := b[0] & 0x0F
+		if n >= min && n <= max {
+			return n, nil
+		}
+	}
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func packNibbles(nibbles []byte) []byte {
+	b := make([]byte, len(nibbles)/2)
+	for i := range b {
+		b[i] = nibbles[2*i]<<4 | nibbles[2*i+1]
+	}
+	return b
+}
+
+func unpackNibbles(b []byte) []byte {
+	nibbles := make([]byte, 0, len(b)*2)
+	for _, c := range b {
+		nibbles = append(nibbles, c>>4, c&0x0F)
+	}
+	return nibbles
+}
+
+func xor(a, b []byte) []byte {
+	out := make([]byte, len(a))
+	for i := range a {
+		out[i] = a[i] ^ b[i]
+	}
+	return out
+}
@@ -0,0 +1,204 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestEncodePINBlock(t *testing.T) {
+	// ISO 9564-1 format 0 example
+	block, err := EncodePINBlock(ISO0, "1234", "43219876543210987")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.ToUpper(hex.EncodeToString(block)); got != "0412AC89ABCDEF67" {
+		t.Errorf("EncodePINBlock(ISO0) = %s, want 0412AC89ABCDEF67", got)
+	}
+
+	tests := []struct {
+		name    string
+		format  PINBlockFormat
+		pin     string
+		pan     string
+		wantErr error
+	}{
+		{"format 0", ISO0, "1234", "43219876543210987", nil},
+		{"format 1", ISO1, "123456789012", "", nil},
+		{"format 3", ISO3, "98765", "43219876543210987", nil},
+		{"msisdn", ISO0, "0000", MSISDNToPAN("+255754000000"), nil},
+		{"short pin", ISO0, "123", "43219876543210987", ErrInvalidPIN},
+		{"not digits", ISO3, "12a4", "43219876543210987", ErrInvalidPIN},
+		{"invalid pan", ISO0, "1234", "4321-9876", ErrInvalidPAN},
+		{"clear format 4", ISO4, "1234", "43219876543210987", ErrUnsupportedFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block, err := EncodePINBlock(tt.format, tt.pin, tt.pan)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("EncodePINBlock() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if pin, err := DecodePINBlock(tt.format, block, tt.pan); err != nil || pin != tt.pin {
+				t.Errorf("DecodePINBlock() = %q, %v, want %q", pin, err, tt.pin)
+			}
+		})
+	}
+}
+
+func TestEncryptPINBlock(t *testing.T) {
+	tdesKey, _ := hex.DecodeString("0123456789ABCDEFFEDCBA9876543210")
+	aesKey, _ := hex.DecodeString("00112233445566778899AABBCCDDEEFF")
+	const pan = "43219876543210987"
+
+	tests := []struct {
+		format PINBlockFormat
+		key    []byte
+		size   int
+	}{
+		{ISO0, tdesKey, 8},
+		{ISO1, tdesKey, 8},
+		{ISO3, tdesKey, 8},
+		{ISO4, aesKey, 16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format.String(), func(t *testing.T) {
+			block, err := EncryptPINBlock(tt.format, "1234", pan, tt.key)
+			if err != nil || len(block) != tt.size {
+				t.Fatalf("EncryptPINBlock() = %X, %v", block, err)
+			}
+			if pin, err := DecryptPINBlock(tt.format, block, pan, tt.key); err != nil || pin != "1234" {
+				t.Errorf("DecryptPINBlock() = %q, %v", pin, err)
+			}
+
+			block[len(block)-1] ^= 0x01
+			if _, err := DecryptPINBlock(tt.format, block, pan, tt.key); tt.format != ISO1 && !errors.Is(err, ErrInvalidPINBlock) {
+				t.Errorf("DecryptPINBlock() of tampered block error = %v, want ErrInvalidPINBlock", err)
+			}
+		})
+	}
+
+	if _, err := EncryptPINBlock(ISO0, "1234", pan, aesKey[:8]); !errors.Is(err, ErrInvalidWorkingKey) {
+		t.Errorf("EncryptPINBlock() with an 8 byte key error = %v, want ErrInvalidWorkingKey", err)
+	}
+}
+
+func TestMSISDNToPAN(t *testing.T) {
+	if got := MSISDNToPAN("7992739871"); got != "79927398713" {
+		t.Errorf("MSISDNToPAN() = %s, want 79927398713", got)
+	}
+}
+
+// fixedRandom returns a reader of the hex encoded bytes, used as the random
+// fill digits and the format 4 random field
+func fixedRandom(t *testing.T, random string) io.Reader {
+	t.Helper()
+	b, err := hex.DecodeString(random)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(b)
+}
+
+// TestPINBlock_Regression pins the output of the encoder. These are not
+// published ISO 9564 vectors: the clear blocks were built by hand from the
+// format layouts and enciphered with openssl enc -des-ede3 and -aes-128-ecb,
+// so they catch regressions but not a misreading of the standard.
+func TestPINBlock_Regression(t *testing.T) {
+	tdesKey, _ := hex.DecodeString("0123456789ABCDEFFEDCBA9876543210")
+	aesKey, _ := hex.DecodeString("00112233445566778899AABBCCDDEEFF")
+	const pan = "43219876543210987"
+
+	tests := []struct {
+		name       string
+		format     PINBlockFormat
+		random     string
+		wantClear  string
+		wantCipher string
+	}{
+		{
+			name:       "format 0",
+			format:     ISO0,
+			wantClear:  "0412AC89ABCDEF67",
+			wantCipher: "C967C8198151A458",
+		},
+		{
+			name:       "format 1",
+			format:     ISO1,
+			random:     "0102030405060708090A",
+			wantClear:  "141234123456789A",
+			wantCipher: "7E2127A8921F5E5F",
+		},
+		{
+			// 05 and 13 are rejected, format 3 fill digits are A to F
+			name:       "format 3",
+			format:     ISO3,
+			random:     "05130A0B0C0D0E0F0A0B0C0D",
+			wantClear:  "3412ACDD99DDBB55",
+			wantCipher: "86C7448A915DE9FE",
+		},
+		{
+			// clear field 441234AAAAAAAAAA0102030405060708, PAN field
+			// 54321987654321098700000000000000
+			name:       "format 4",
+			format:     ISO4,
+			random:     "0102030405060708",
+			wantCipher: "F2B0E877B98D02344EB2920B6D551EFD",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := tdesKey
+			if tt.format == ISO4 {
+				key = aesKey
+			}
+
+			if tt.wantClear != "" {
+				block, err := encodePINBlock(tt.format, "1234", pan, fixedRandom(t, tt.random))
+				if got := strings.ToUpper(hex.EncodeToString(block)); err != nil || got != tt.wantClear {
+					t.Errorf("EncodePINBlock() = %s, %v, want %s", got, err, tt.wantClear)
+				}
+			}
+
+			block, err := encryptPINBlock(tt.format, "1234", pan, key, fixedRandom(t, tt.random))
+			if got := strings.ToUpper(hex.EncodeToString(block)); err != nil || got != tt.wantCipher {
+				t.Errorf("EncryptPINBlock() = %s, %v, want %s", got, err, tt.wantCipher)
+			}
+
+			want, _ := hex.DecodeString(tt.wantCipher)
+			if pin, err := DecryptPINBlock(tt.format, want, pan, key); err != nil || pin != "1234" {
+				t.Errorf("DecryptPINBlock() = %q, %v, want 1234", pin, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+)
+
+const (
+	PKCS1v15 Padding = iota
+	OAEPSHA256
+	OAEPSHA1
+)
+
+const (
+	SignPKCS1v15SHA256 Scheme = iota
+	SignPSSSHA256
+	SignECDSASHA256
+)
+
+var (
+	ErrEncrypt = errors.New("crypto: encryption failed")
+	ErrDecrypt = errors.New("crypto: decryption failed")
+	ErrSign    = errors.New("crypto: signing failed")
+
+	// ErrInvalidSignature is returned when a signature does not match
+	ErrInvalidSignature = errors.New("crypto: invalid signature")
+
+	ErrUnsupportedPadding = errors.New("crypto: unsupported padding")
+	ErrUnsupportedScheme  = errors.New("crypto: unsupported signature scheme")
+)
+
+type (
+	// Padding is the RSA encryption padding
+	Padding int
+
+	// Scheme is a signature algorithm
+	Scheme int
+)
+
+func (p Padding) String() string {
+	switch p {
+	case PKCS1v15:
+		return "PKCS1v15"
+	case OAEPSHA256:
+		return "OAEP-SHA256"
+	case OAEPSHA1:
+		return "OAEP-SHA1"
+	}
+	return fmt.Sprintf("Padding(%d)", int(p))
+}
+
+func (s Scheme) String() string {
+	switch s {
+	case SignPKCS1v15SHA256:
+		return "RS256"
+	case SignPSSSHA256:
+		return "PS256"
+	case SignECDSASHA256:
+		return "ES256"
+	}
+	return fmt.Sprintf("Scheme(%d)", int(s))
+}
+
+func (p Padding) oaepHash() (hash.Hash, error) {
+	switch p {
+	case OAEPSHA256:
+		return sha256.New(), nil
+	case OAEPSHA1:
+		return sha1.New(), nil
+	}
+	return nil, fmt.Errorf("%w: %v", ErrUnsupportedPadding, p)
+}
+
+// EncryptRSA encrypts msg with the public key
+func EncryptRSA(key *rsa.PublicKey, padding Padding, msg []byte) ([]byte, error) {
+	var (
+		out []byte
+		err error
+	)
+	if padding == PKCS1v15 {
+		out, err = rsa.EncryptPKCS1v15(rand.Reader, key, msg)
+	} else {
+		h, hErr := padding.oaepHash()
+		if hErr != nil {
+			return nil, hErr
+		}
+		out, err = rsa.EncryptOAEP(h, rand.Reader, key, msg, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrEncrypt, padding, err)
+	}
+	return out, nil
+}
+
+// DecryptRSA decrypts ciphertext with the private key. The error does not
+// tell why the decryption failed, see rsa.DecryptPKCS1v15.
+func DecryptRSA(key *rsa.PrivateKey, padding Padding, ciphertext []byte) ([]byte, error) {
+	var (
+		out []byte
+		err error
+	)
+	if padding == PKCS1v15 {
+		out, err = rsa.DecryptPKCS1v15(rand.Reader, key, ciphertext)
+	} else {
+		h, hErr := padding.oaepHash()
+		if hErr != nil {
+			return nil, hErr
+		}
+		out, err = rsa.DecryptOAEP(h, rand.Reader, key, ciphertext, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrDecrypt, padding)
+	}
+	return out, nil
+}
+
+// Sign signs the SHA-256 digest of msg. key must be a *rsa.PrivateKey for
+// SignPKCS1v15SHA256 and SignPSSSHA256 and a *ecdsa.PrivateKey for
+// SignECDSASHA256 (ASN.1 DER signature)
+func Sign(key crypto.Signer, scheme Scheme, msg []byte) ([]byte, error) {
+	digest := sha256.Sum256(msg)
+
+	var (
+		sig []byte
+		err error
+	)
+	switch scheme {
+	case SignPKCS1v15SHA256, SignPSSSHA256:
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%w: %v needs an RSA private key, got %T", ErrKeyType, scheme, key)
+		}
+		if scheme == SignPKCS1v15SHA256 {
+			sig, err = rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+		} else {
+			sig, err = rsa.SignPSS(rand.Reader, rsaKey, crypto.SHA256, digest[:],
+				&rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+	case SignECDSASHA256:
+		ecKey, ok := key.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%w: %v needs an ECDSA private key, got %T", ErrKeyType, scheme, key)
+		}
+		sig, err = ecdsa.SignASN1(rand.Reader, ecKey, digest[:])
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedScheme, scheme)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v: %v", ErrSign, scheme, err)
+	}
+	return sig, nil
+}
+
+// Verify checks the signature of msg made with Sign, it returns
+// ErrInvalidSignature when it does not match
+func Verify(key crypto.PublicKey, scheme Scheme, msg, sig []byte) error {
+	digest := sha256.Sum256(msg)
+
+	switch scheme {
+	case SignPKCS1v15SHA256, SignPSSSHA256:
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: %v needs an RSA public key, got %T", ErrKeyType, scheme, key)
+		}
+		var err error
+		if scheme == SignPKCS1v15SHA256 {
+			err = rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, digest[:], sig)
+		} else {
+			// the salt length is detected so that signatures made with
+			// other salt lengths are accepted
+			err = rsa.VerifyPSS(rsaKey, crypto.SHA256, digest[:], sig,
+				&rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto})
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSignature, scheme)
+		}
+		return nil
+
+	case SignECDSASHA256:
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: %v needs an ECDSA public key, got %T", ErrKeyType, scheme, key)
+		}
+		if !ecdsa.VerifyASN1(ecKey, digest[:], sig) {
+			return fmt.Errorf("%w: %v", ErrInvalidSignature, scheme)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %v", ErrUnsupportedScheme, scheme)
+}
@@ -0,0 +1,122 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package base
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+)
+
+const (
+	// ExchangeOutgoing is a request sent by Client.Do and its response
+	ExchangeOutgoing ExchangeKind = iota
+
+	// ExchangeReceived is a request read by Receiver.Receive
+	ExchangeReceived
+
+	// ExchangeReplied is a response written by Replier.Reply, Request is nil
+	ExchangeReplied
+)
+
+type (
+	ExchangeKind int
+
+	// Exchange describes an HTTP exchange that went through a Client, a Receiver
+	// or a Replier. The bodies are the raw bytes that were sent or received,
+	// hooks must not modify them.
+	Exchange struct {
+		Kind         ExchangeKind
+		Name         string
+		RequestID    string
+		Request      *http.Request
+		RequestBody  []byte
+		Response     *http.Response
+		ResponseBody []byte
+		Started      time.Time
+		Duration     time.Duration
+		Err          error
+	}
+
+	// ExchangeHook is called after every exchange, it is called synchronously
+	// so it should return quickly
+	ExchangeHook func(exchange *Exchange)
+
+	// replyRecorder captures what Replier.Reply writes for the exchange hooks
+	replyRecorder struct {
+		http.ResponseWriter
+		status int
+		body   bytes.Buffer
+	}
+)
+
+func (k ExchangeKind) String() string {
+	switch k {
+	case ExchangeOutgoing:
+		return "outgoing"
+	case ExchangeReceived:
+		return "received"
+	case ExchangeReplied:
+		return "replied"
+	}
+	return "unknown"
+}
+
+func callHooks(hooks []ExchangeHook, exchange *Exchange) {
+	for _, hook := range hooks {
+		hook(exchange)
+	}
+}
+
+func (r *replyRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *replyRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *replyRecorder) response() *http.Response {
+	status := r.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return &http.Response{
+		Status:     http.StatusText(status),
+		StatusCode: status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     r.Header().Clone(),
+	}
+}
@@ -0,0 +1,366 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+// Package fault provides an http.RoundTripper that injects faults into the
+// exchanges of a base.Client so retry, timeout and idempotency handling can
+// be tested. Faults fire with a probability or on a schedule of request
+// numbers, and the random source is seeded so a failing run can be repeated
+// exactly by reusing its seed.
+//
+//	client := base.NewClient(fault.WithFaults(42,
+//		fault.Fault{Kind: fault.ServerError, Probability: 0.1, Storm: 3},
+//		fault.Fault{Kind: fault.ConnReset, Schedule: []int{2}, AfterSend: true},
+//	))
+package fault
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/techcraftlabs/base"
+)
+
+const (
+	// ConnReset fails the request with a connection reset error
+	ConnReset Kind = iota
+	// SlowHeaders delays the response headers by Fault.Delay
+	SlowHeaders
+	// TruncatedBody cuts the response body in half
+	TruncatedBody
+	// WrongContentType replaces the response Content-Type with Fault.ContentType
+	WrongContentType
+	// ServerError answers with Fault.Status without contacting the server
+	ServerError
+	// Duplicate sends the request to the server twice and returns the second response
+	Duplicate
+)
+
+const (
+	defaultDelay       = 5 * time.Second
+	defaultStatus      = http.StatusServiceUnavailable
+	defaultContentType = "text/html; charset=utf-8"
+)
+
+// ErrConnReset matches, with errors.Is, the errors of injected ConnReset faults.
+// They are *net.OpError values that also match syscall.ECONNRESET, like a real
+// connection reset
+var ErrConnReset = errors.New("fault: injected connection reset")
+
+var _ http.RoundTripper = (*Transport)(nil)
+
+type (
+	Kind int
+
+	// Fault describes when and how a fault is injected. It fires on the
+	// request numbers (starting from 1) listed in Schedule, or otherwise with
+	// the given Probability.
+	Fault struct {
+		Kind        Kind
+		Probability float64
+		Schedule    []int
+
+		// Delay of SlowHeaders, default is 5s
+		Delay time.Duration
+
+		// Status of ServerError, default is 503. Storm is the number of consecutive
+		// requests that get the error once it fires, default is 1
+		Status int
+		Storm  int
+
+		// ContentType of WrongContentType, default is text/html
+		ContentType string
+
+		// AfterSend makes ConnReset happen after the server received the request
+		AfterSend bool
+	}
+
+	// Injection records a fault that has been injected
+	Injection struct {
+		Request int
+		Kind    Kind
+		Method  string
+		URL     string
+	}
+
+	// Transport injects faults in the exchanges made through Base
+	Transport struct {
+		mu         sync.Mutex
+		base       http.RoundTripper
+		rng        *rand.Rand
+		faults     []Fault
+		requests   int
+		storm      int
+		stormFault Fault
+		injected   []Injection
+	}
+)
+
+func (k Kind) String() string {
+	names := []string{"conn-reset", "slow-headers", "truncated-body", "wrong-content-type", "server-error", "duplicate"}
+	if int(k) < len(names) {
+		return names[k]
+	}
+	return fmt.Sprintf("kind(%d)", int(k))
+}
+
+// New creates a Transport that sends requests through base, or
+// http.DefaultTransport when base is nil
+func New(base http.RoundTripper, seed int64, faults ...Fault) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{
+		base:   base,
+		rng:    rand.New(rand.NewSource(seed)),
+		faults: faults,
+	}
+}
+
+// WithFaults is a base.ClientOption that wraps the transport of the client
+// with a fault injecting Transport
+func WithFaults(seed int64, faults ...Fault) base.ClientOption {
+	return func(client *base.Client) {
+		t := New(client.Http.Transport, seed, faults...)
+		base.WithTransport(t)(client)
+	}
+}
+
+// Injected returns the faults injected so far
+func (t *Transport) Injected() []Injection {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Injection(nil), t.injected...)
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	fault, ok := t.next(req)
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+
+	switch fault.Kind {
+	case ConnReset:
+		if fault.AfterSend {
+			res, err := t.base.RoundTrip(req)
+			if err != nil {
+				return nil, err
+			}
+			_, _ = io.Copy(io.Discard, res.Body)
+			_ = res.Body.Close()
+		} else {
+			closeBody(req)
+		}
+		return nil, &net.OpError{Op: "read", Net: "tcp", Err: connReset{}}
+
+	case SlowHeaders:
+		delay := fault.Delay
+		if delay <= 0 {
+			delay = defaultDelay
+		}
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			closeBody(req)
+			return nil, req.Context().Err()
+		}
+		return t.base.RoundTrip(req)
+
+	case TruncatedBody:
+		res, err := t.base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(res.Body)
+		_ = res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		res.Body = io.NopCloser(io.MultiReader(
+			bytes.NewReader(body[:len(body)/2]),
+			errReader{err: io.ErrUnexpectedEOF},
+		))
+		return res, nil
+
+	case WrongContentType:
+		res, err := t.base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		contentType := fault.ContentType
+		if contentType == "" {
+			contentType = defaultContentType
+		}
+		res.Header.Set("Content-Type", contentType)
+		return res, nil
+
+	case ServerError:
+		status := fault.Status
+		if status == 0 {
+			status = defaultStatus
+		}
+		closeBody(req)
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+			StatusCode:    status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": []string{"text/plain; charset=utf-8"}},
+			Body:          io.NopCloser(strings.NewReader(http.StatusText(status))),
+			ContentLength: int64(len(http.StatusText(status))),
+			Request:       req,
+		}, nil
+
+	case Duplicate:
+		body, err := readBody(req)
+		if err != nil {
+			return nil, err
+		}
+		res, err := t.base.RoundTrip(withBody(req, body))
+		if err == nil {
+			_, _ = io.Copy(io.Discard, res.Body)
+			_ = res.Body.Close()
+		}
+		return t.base.RoundTrip(withBody(req, body))
+	}
+
+	return t.base.RoundTrip(req)
+}
+
+// next decides whether the request gets a fault. One random number is drawn
+// for every configured fault on every request, whatever the outcome, so the
+// same seed always produces the same sequence of faults.
+func (t *Transport) next(req *http.Request) (Fault, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.requests++
+	n := t.requests
+
+	var (
+		chosen Fault
+		found  bool
+	)
+	for _, f := range t.faults {
+		draw := t.rng.Float64()
+		if found {
+			continue
+		}
+		if scheduled(f.Schedule, n) || (len(f.Schedule) == 0 && draw < f.Probability) {
+			chosen, found = f, true
+		}
+	}
+
+	// a running storm takes precedence over the other faults
+	switch {
+	case t.storm > 0:
+		chosen, found = t.stormFault, true
+		t.storm--
+	case found && chosen.Kind == ServerError && chosen.Storm > 1:
+		t.storm, t.stormFault = chosen.Storm-1, chosen
+	}
+
+	if found {
+		t.injected = append(t.injected, Injection{
+			Request: n,
+			Kind:    chosen.Kind,
+			Method:  req.Method,
+			URL:     req.URL.String(),
+		})
+	}
+	return chosen, found
+}
+
+func scheduled(schedule []int, n int) bool {
+	for _, s := range schedule {
+		if s == n {
+			return true
+		}
+	}
+	return false
+}
+
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	defer req.Body.Close()
+	return io.ReadAll(req.Body)
+}
+
+// withBody returns a clone of req that sends body, req is not modified as a
+// RoundTripper must not modify the request
+func withBody(req *http.Request, body []byte) *http.Request {
+	clone := req.Clone(req.Context())
+	clone.Body = io.NopCloser(bytes.NewReader(body))
+	return clone
+}
+
+// closeBody closes the body of a request that is not sent, as a RoundTripper
+// must even when it fails
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+}
+
+// connReset is the cause of an injected ConnReset fault
+type connReset struct{}
+
+func (connReset) Error() string {
+	return syscall.ECONNRESET.Error()
+}
+
+func (connReset) Unwrap() error {
+	return syscall.ECONNRESET
+}
+
+func (connReset) Is(target error) bool {
+	return target == ErrConnReset
+}
+
+type errReader struct {
+	err error
+}
+
+func (e errReader) Read([]byte) (int, error) {
+	return 0, e.err
+}
+
+// IsConnReset reports whether err is an injected or real connection reset
+func IsConnReset(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET)
+}
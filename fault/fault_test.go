@@ -0,0 +1,264 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package fault
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// body records whether the transport closed the request body
+type body struct {
+	io.Reader
+	closed bool
+}
+
+func (b *body) Close() error {
+	b.closed = true
+	return nil
+}
+
+func server(t *testing.T, hits *int32) *httptest.Server {
+	t.Helper()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"status":"ok","reference":"0123456789"}`)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestTransport_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		fault    Fault
+		wantHits int32
+		wantErr  error
+		check    func(t *testing.T, res *http.Response)
+	}{
+		{
+			name:     "conn reset",
+			fault:    Fault{Kind: ConnReset},
+			wantHits: 0,
+			wantErr:  ErrConnReset,
+		},
+		{
+			name:     "conn reset after send",
+			fault:    Fault{Kind: ConnReset, AfterSend: true},
+			wantHits: 1,
+			wantErr:  ErrConnReset,
+		},
+		{
+			name:     "server error",
+			fault:    Fault{Kind: ServerError, Status: http.StatusBadGateway},
+			wantHits: 0,
+			check: func(t *testing.T, res *http.Response) {
+				if res.StatusCode != http.StatusBadGateway {
+					t.Errorf("status = %d, want %d", res.StatusCode, http.StatusBadGateway)
+				}
+			},
+		},
+		{
+			name:     "truncated body",
+			fault:    Fault{Kind: TruncatedBody},
+			wantHits: 1,
+			check: func(t *testing.T, res *http.Response) {
+				b, err := io.ReadAll(res.Body)
+				if !errors.Is(err, io.ErrUnexpectedEOF) {
+					t.Errorf("read error = %v, want %v", err, io.ErrUnexpectedEOF)
+				}
+				if string(b) != `{"status":"ok","refe` {
+					t.Errorf("body = %s", b)
+				}
+			},
+		},
+		{
+			name:     "wrong content type",
+			fault:    Fault{Kind: WrongContentType},
+			wantHits: 1,
+			check: func(t *testing.T, res *http.Response) {
+				if got := res.Header.Get("Content-Type"); got != defaultContentType {
+					t.Errorf("Content-Type = %s, want %s", got, defaultContentType)
+				}
+			},
+		},
+		{
+			name:     "duplicate",
+			fault:    Fault{Kind: Duplicate},
+			wantHits: 2,
+			check: func(t *testing.T, res *http.Response) {
+				if res.StatusCode != http.StatusOK {
+					t.Errorf("status = %d, want 200", res.StatusCode)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits int32
+			s := server(t, &hits)
+
+			tt.fault.Probability = 1
+			transport := New(nil, 42, tt.fault)
+			b := &body{Reader: strings.NewReader(`{"msisdn":"255754000000"}`)}
+			req, err := http.NewRequest(http.MethodPost, s.URL, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := transport.RoundTrip(req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RoundTrip() error = %v, want %v", err, tt.wantErr)
+			}
+			if res != nil {
+				defer res.Body.Close()
+			}
+			if got := atomic.LoadInt32(&hits); got != tt.wantHits {
+				t.Errorf("server got %d requests, want %d", got, tt.wantHits)
+			}
+			if !b.closed {
+				t.Errorf("request body not closed")
+			}
+			if req.Body != b {
+				t.Errorf("RoundTrip() modified the request body")
+			}
+			if tt.check != nil {
+				tt.check(t, res)
+			}
+		})
+	}
+}
+
+func TestTransport_SlowHeaders(t *testing.T) {
+	var hits int32
+	s := server(t, &hits)
+	transport := New(nil, 42, Fault{Kind: SlowHeaders, Probability: 1, Delay: time.Minute})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	b := &body{Reader: strings.NewReader("{}")}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transport.RoundTrip(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("RoundTrip() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if hits != 0 || !b.closed {
+		t.Errorf("server got %d requests and body closed = %v, want 0 and true", hits, b.closed)
+	}
+}
+
+func roundTrips(t *testing.T, transport *Transport, url string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res, err := transport.RoundTrip(req); err == nil {
+			_, _ = io.Copy(io.Discard, res.Body)
+			_ = res.Body.Close()
+		}
+	}
+}
+
+func TestTransport_Seed(t *testing.T) {
+	var hits int32
+	s := server(t, &hits)
+
+	run := func(seed int64) []Injection {
+		transport := New(nil, seed,
+			Fault{Kind: ServerError, Probability: 0.3},
+			Fault{Kind: WrongContentType, Probability: 0.2},
+		)
+		roundTrips(t, transport, s.URL, 20)
+		return transport.Injected()
+	}
+
+	first, second := run(7), run(7)
+	if len(first) == 0 || !reflect.DeepEqual(first, second) {
+		t.Fatalf("the same seed injected different faults:\n%v\n%v", first, second)
+	}
+}
+
+func TestTransport_Schedule(t *testing.T) {
+	var hits int32
+	s := server(t, &hits)
+	transport := New(nil, 42,
+		Fault{Kind: ServerError, Schedule: []int{2}, Storm: 3},
+		Fault{Kind: ConnReset, Schedule: []int{3, 6}},
+	)
+	roundTrips(t, transport, s.URL, 6)
+
+	// the storm of request 2 takes precedence over the conn reset of request 3
+	want := map[int]Kind{2: ServerError, 3: ServerError, 4: ServerError, 6: ConnReset}
+	got := make(map[int]Kind)
+	for _, injection := range transport.Injected() {
+		got[injection.Request] = injection.Kind
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("injected %v, want %v", transport.Injected(), want)
+	}
+	if hits != 2 {
+		t.Errorf("server got %d requests, want 2", hits)
+	}
+}
+
+func TestTransport_ConnResetErrors(t *testing.T) {
+	var hits int32
+	s := server(t, &hits)
+	transport := New(nil, 42, Fault{Kind: ConnReset, Probability: 1})
+
+	var errs []error
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest(http.MethodGet, s.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = transport.RoundTrip(req)
+		var opErr *net.OpError
+		if !errors.Is(err, ErrConnReset) || !IsConnReset(err) || !errors.As(err, &opErr) {
+			t.Fatalf("RoundTrip() error = %#v, want an injected connection reset", err)
+		}
+		errs = append(errs, err)
+	}
+	if errs[0] == errs[1] {
+		t.Errorf("injections share the same error value")
+	}
+}
@@ -0,0 +1,276 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+// Package har records the exchanges of base.Client, base.Receiver and
+// base.Replier in HTTP Archive (HAR 1.2) format so that they can be opened
+// in standard HAR viewers such as the browser developer tools.
+//
+//	recorder, err := har.NewFile("/var/log/app/session.har", har.WithMaxEntries(500))
+//	client := base.NewClient(base.WithExchangeHook(recorder.Record))
+//	receiver := base.NewReceiver(os.Stderr, false, base.ExchangeHookOption(recorder.Record))
+//	defer recorder.Close()
+//
+// A request read by a Receiver and the response written by a Replier with the
+// same request ID (see base.WithResponseRequestID) are merged into one entry.
+// Headers and body fields with sensitive values are redacted.
+package har
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+	"unicode/utf8"
+)
+
+const Version = "1.2"
+
+type (
+	// HAR is the root object of a HAR file
+	HAR struct {
+		Log Log `json:"log"`
+	}
+
+	Log struct {
+		Version string  `json:"version"`
+		Creator Creator `json:"creator"`
+		Entries []Entry `json:"entries"`
+	}
+
+	Creator struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+
+	Entry struct {
+		StartedDateTime time.Time `json:"startedDateTime"`
+		Time            float64   `json:"time"`
+		Request         Request   `json:"request"`
+		Response        Response  `json:"response"`
+		Cache           struct{}  `json:"cache"`
+		Timings         Timings   `json:"timings"`
+		Comment         string    `json:"comment,omitempty"`
+
+		// custom fields, HAR allows fields that start with an underscore
+		Name      string `json:"_name,omitempty"`
+		Kind      string `json:"_kind,omitempty"`
+		RequestID string `json:"_requestId,omitempty"`
+		Error     string `json:"_error,omitempty"`
+	}
+
+	Request struct {
+		Method      string      `json:"method"`
+		URL         string      `json:"url"`
+		HTTPVersion string      `json:"httpVersion"`
+		Cookies     []Cookie    `json:"cookies"`
+		Headers     []NameValue `json:"headers"`
+		QueryString []NameValue `json:"queryString"`
+		PostData    *PostData   `json:"postData,omitempty"`
+		HeadersSize int         `json:"headersSize"`
+		BodySize    int         `json:"bodySize"`
+	}
+
+	Response struct {
+		Status      int         `json:"status"`
+		StatusText  string      `json:"statusText"`
+		HTTPVersion string      `json:"httpVersion"`
+		Cookies     []Cookie    `json:"cookies"`
+		Headers     []NameValue `json:"headers"`
+		Content     Content     `json:"content"`
+		RedirectURL string      `json:"redirectURL"`
+		HeadersSize int         `json:"headersSize"`
+		BodySize    int         `json:"bodySize"`
+	}
+
+	Cookie struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+
+	NameValue struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+
+	PostData struct {
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text"`
+	}
+
+	Content struct {
+		Size     int    `json:"size"`
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text,omitempty"`
+		Encoding string `json:"encoding,omitempty"`
+	}
+
+	// Timings are in milliseconds, -1 means that the timing does not apply
+	Timings struct {
+		Blocked float64 `json:"blocked"`
+		DNS     float64 `json:"dns"`
+		Connect float64 `json:"connect"`
+		Send    float64 `json:"send"`
+		Wait    float64 `json:"wait"`
+		Receive float64 `json:"receive"`
+		SSL     float64 `json:"ssl"`
+	}
+)
+
+func milliseconds(d time.Duration) float64 {
+	if d < 0 {
+		return 0
+	}
+	return float64(d) / float64(time.Millisecond)
+}
+
+func newTimings(send, wait, receive time.Duration) Timings {
+	return Timings{
+		Blocked: -1,
+		DNS:     -1,
+		Connect: -1,
+		Send:    milliseconds(send),
+		Wait:    milliseconds(wait),
+		Receive: milliseconds(receive),
+		SSL:     -1,
+	}
+}
+
+func (r *redactor) request(req *http.Request, body []byte) Request {
+	request := Request{
+		Cookies:     []Cookie{},
+		Headers:     []NameValue{},
+		QueryString: []NameValue{},
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+	if req == nil {
+		return request
+	}
+
+	u := *req.URL
+	if !u.IsAbs() {
+		u.Scheme, u.Host = "http", req.Host
+		if req.TLS != nil {
+			u.Scheme = "https"
+		}
+	}
+	query := u.Query()
+	r.values(query)
+	u.RawQuery = query.Encode()
+
+	request.Method = req.Method
+	request.URL = u.String()
+	request.HTTPVersion = httpVersion(req.Proto)
+	request.Headers = r.headerPairs(req.Header)
+	request.QueryString = nameValues(query)
+	for _, cookie := range req.Cookies() {
+		request.Cookies = append(request.Cookies, Cookie{Name: cookie.Name, Value: Redacted})
+	}
+
+	if len(body) > 0 {
+		contentType := req.Header.Get("Content-Type")
+		text, _ := encode(r.body(contentType, body))
+		request.PostData = &PostData{MimeType: contentType, Text: text}
+	}
+	return request
+}
+
+func (r *redactor) response(res *http.Response, body []byte) Response {
+	response := Response{
+		Cookies:     []Cookie{},
+		Headers:     []NameValue{},
+		HeadersSize: -1,
+		BodySize:    len(body),
+		Content:     Content{Size: len(body), MimeType: "x-unknown"},
+	}
+	if res == nil {
+		return response
+	}
+
+	response.Status = res.StatusCode
+	response.StatusText = http.StatusText(res.StatusCode)
+	response.HTTPVersion = httpVersion(res.Proto)
+	response.Headers = r.headerPairs(res.Header)
+	response.RedirectURL = res.Header.Get("Location")
+	for _, cookie := range res.Cookies() {
+		response.Cookies = append(response.Cookies, Cookie{Name: cookie.Name, Value: Redacted})
+	}
+
+	if contentType := res.Header.Get("Content-Type"); contentType != "" {
+		response.Content.MimeType = contentType
+	}
+	if len(body) > 0 {
+		response.Content.Text, response.Content.Encoding = encode(r.body(response.Content.MimeType, body))
+	}
+	return response
+}
+
+func (r *redactor) headerPairs(header http.Header) []NameValue {
+	pairs := make([]NameValue, 0, len(header))
+	for _, name := range sortedKeys(header) {
+		for _, value := range header[name] {
+			if r.isRedactedHeader(name) {
+				value = Redacted
+			}
+			pairs = append(pairs, NameValue{Name: name, Value: value})
+		}
+	}
+	return pairs
+}
+
+func nameValues(values url.Values) []NameValue {
+	pairs := make([]NameValue, 0, len(values))
+	for _, name := range sortedKeys(values) {
+		for _, value := range values[name] {
+			pairs = append(pairs, NameValue{Name: name, Value: value})
+		}
+	}
+	return pairs
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// encode returns the body as text, or base64 encoded when it is not valid UTF-8
+func encode(body []byte) (text, encoding string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+func httpVersion(proto string) string {
+	if proto == "" {
+		return "HTTP/1.1"
+	}
+	return proto
+}
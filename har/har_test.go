@@ -0,0 +1,179 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package har
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/techcraftlabs/base"
+)
+
+type payment struct {
+	MSISDN string `json:"msisdn"`
+	Pin    string `json:"pin"`
+}
+
+func exchange(t *testing.T, recorder *Recorder, n int) {
+	t.Helper()
+	receiver := base.NewReceiver(io.Discard, false, base.ExchangeHookOption(recorder.Record))
+	replier := base.NewReplier(io.Discard, false, base.ExchangeHookOption(recorder.Record))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receipt, err := receiver.Receive(r.Context(), "payment", r, new(payment))
+		if err != nil {
+			t.Errorf("Receive() unexpected error: %v", err)
+		}
+		replier.Reply(w, base.NewResponse(http.StatusOK, map[string]string{"status": "ok", "token": "t0k3n"},
+			base.WithResponseRequestID(receipt.RequestID)))
+	}))
+	defer server.Close()
+
+	client := base.NewClient(base.WithDebugMode(false), base.WithExchangeHook(recorder.Record))
+	for i := 0; i < n; i++ {
+		request := base.NewRequest("payment", http.MethodPost, server.URL+"/pay?pin=1234",
+			payment{MSISDN: "255754000000", Pin: "1234"}, base.WithBasicAuth("user", "pass"))
+		if _, err := client.Do(context.Background(), request, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	var buf bytes.Buffer
+	recorder := NewWriter(&buf)
+	exchange(t, recorder, 1)
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var har HAR
+	if err := json.Unmarshal(buf.Bytes(), &har); err != nil {
+		t.Fatalf("output is not a HAR document: %v", err)
+	}
+	if har.Log.Version != Version || len(har.Log.Entries) != 2 {
+		t.Fatalf("got version %s with %d entries, want %s with 2", har.Log.Version, len(har.Log.Entries), Version)
+	}
+
+	for _, entry := range har.Log.Entries {
+		if entry.Response.Status != http.StatusOK {
+			t.Errorf("%s entry status = %d, want 200", entry.Kind, entry.Response.Status)
+		}
+		if entry.Request.PostData == nil || !strings.Contains(entry.Request.PostData.Text, `"pin":"[REDACTED]"`) ||
+			!strings.Contains(entry.Request.PostData.Text, "255754000000") {
+			t.Errorf("%s entry request body not redacted: %+v", entry.Kind, entry.Request.PostData)
+		}
+		if strings.Contains(entry.Request.URL, "1234") || strings.Contains(entry.Response.Content.Text, "t0k3n") {
+			t.Errorf("%s entry not redacted: %s %s", entry.Kind, entry.Request.URL, entry.Response.Content.Text)
+		}
+		for _, header := range entry.Request.Headers {
+			if header.Name == "Authorization" && header.Value != Redacted {
+				t.Errorf("%s entry Authorization header = %s", entry.Kind, header.Value)
+			}
+		}
+	}
+	if har.Log.Entries[0].RequestID != har.Log.Entries[1].RequestID {
+		t.Errorf("received and outgoing entries have different request ids")
+	}
+}
+
+func TestRecorder_Rotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.har")
+	recorder, err := NewFile(path, WithMaxEntries(2), WithMaxFiles(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every exchange adds a received and an outgoing entry
+	exchange(t, recorder, 3)
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]bool{path: false, path + ".1": true, path + ".2": false} {
+		if _, err := os.Stat(name); (err == nil) != want {
+			t.Errorf("%s exists = %v, want %v", filepath.Base(name), err == nil, want)
+		}
+	}
+}
+
+func TestRecorder_RetriedCallback(t *testing.T) {
+	recorder := NewWriter(io.Discard)
+	started := time.Now()
+	request := httptest.NewRequest(http.MethodPost, "/callback", nil)
+	for i := 0; i < 2; i++ {
+		recorder.Record(&base.Exchange{Kind: base.ExchangeReceived, Name: "callback", RequestID: "cb-1",
+			Request: request, Started: started.Add(time.Duration(i) * time.Millisecond)})
+	}
+	recorder.Record(&base.Exchange{Kind: base.ExchangeReplied, RequestID: "cb-1",
+		Started: started.Add(2 * time.Millisecond), Response: &http.Response{StatusCode: http.StatusOK}})
+
+	entries := recorder.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if entries[0].Response.Status != 0 || entries[0].Comment == "" {
+		t.Errorf("first callback = %+v, want it without a reply", entries[0])
+	}
+	if entries[1].Response.Status != http.StatusOK || entries[1].RequestID != "cb-1" {
+		t.Errorf("second callback = %+v, want it replied with 200", entries[1])
+	}
+}
+
+func TestRecorder_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.har")
+	recorder, err := NewFile(path, WithMaxEntries(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recorder.Close()
+
+	// the file is a valid document after every entry
+	for i := 1; i <= 3; i++ {
+		exchange(t, recorder, 1)
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var har HAR
+		if err := json.Unmarshal(b, &har); err != nil {
+			t.Fatalf("after %d exchanges the file is not a HAR document: %v\n%s", i, err, b)
+		}
+		if len(har.Log.Entries) != 2*i || har.Log.Creator.Name == "" {
+			t.Fatalf("after %d exchanges got %d entries, want %d", i, len(har.Log.Entries), 2*i)
+		}
+		if got, want := har.Log.Entries[2*i-1].RequestID, recorder.Entries()[2*i-1].RequestID; got != want {
+			t.Errorf("last entry request id = %s, want %s", got, want)
+		}
+	}
+}
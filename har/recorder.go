@@ -0,0 +1,475 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package har
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/techcraftlabs/base"
+)
+
+const (
+	defaultMaxEntries   = 1000
+	defaultMaxFiles     = 5
+	defaultReplyTimeout = time.Minute
+)
+
+type (
+	Option func(r *Recorder)
+
+	// Recorder converts base.Exchange values to HAR entries. Entries are kept
+	// in segments of at most MaxEntries, when a segment is full it is written
+	// out and a new one is started.
+	Recorder struct {
+		mu           sync.Mutex
+		out          output
+		redactor     *redactor
+		creator      Creator
+		maxEntries   int
+		maxFiles     int
+		replyTimeout time.Duration
+		onError      func(err error)
+		entries      []Entry
+		pending      map[string]*Entry
+		closed       bool
+	}
+
+	output interface {
+		// save is called with the current segment after every entry
+		save(har *HAR) error
+		// rotate is called with a full segment, before it is discarded
+		rotate(har *HAR) error
+		// flush is called on Recorder.Flush and Recorder.Close
+		flush(har *HAR) error
+		// close is called once on Recorder.Close, after flush
+		close() error
+	}
+
+	// writerOutput writes a HAR document to the writer for every full
+	// segment and on Flush
+	writerOutput struct {
+		writer io.Writer
+	}
+
+	// fileOutput appends every entry to the file, before the closing
+	// brackets of the document, so that it always holds a valid HAR document
+	// without being rewritten. Full segments are rotated to path.1, path.2
+	// and so on.
+	fileOutput struct {
+		path     string
+		maxFiles int
+		file     *os.File
+		written  int   // entries of the segment written to file
+		tail     int64 // offset of the closing brackets
+		trailer  []byte
+	}
+)
+
+// WithMaxEntries sets the number of entries per segment
+func WithMaxEntries(n int) Option {
+	return func(r *Recorder) {
+		if n > 0 {
+			r.maxEntries = n
+		}
+	}
+}
+
+// WithMaxFiles sets the number of rotated files kept by NewFile, the current
+// file is not counted
+func WithMaxFiles(n int) Option {
+	return func(r *Recorder) {
+		if n >= 0 {
+			r.maxFiles = n
+		}
+	}
+}
+
+// WithRedactedHeaders adds headers whose values are replaced by Redacted,
+// Authorization, Proxy-Authorization, Cookie, Set-Cookie and X-API-Key
+// are always redacted
+func WithRedactedHeaders(names ...string) Option {
+	return func(r *Recorder) {
+		r.redactor.addHeaders(names...)
+	}
+}
+
+// WithRedactedFields adds JSON fields, XML elements, form fields and query
+// parameters whose values are replaced by Redacted. password, pin, secret,
+// token, access_token and refresh_token are always redacted
+func WithRedactedFields(names ...string) Option {
+	return func(r *Recorder) {
+		r.redactor.addFields(names...)
+	}
+}
+
+func WithCreator(name, version string) Option {
+	return func(r *Recorder) {
+		r.creator = Creator{Name: name, Version: version}
+	}
+}
+
+// WithReplyTimeout sets how long a received request waits for its reply
+// before it is recorded without a response
+func WithReplyTimeout(timeout time.Duration) Option {
+	return func(r *Recorder) {
+		if timeout > 0 {
+			r.replyTimeout = timeout
+		}
+	}
+}
+
+// WithErrorHandler sets the func called when the output can not be written,
+// errors are ignored by default
+func WithErrorHandler(fn func(err error)) Option {
+	return func(r *Recorder) {
+		r.onError = fn
+	}
+}
+
+// NewWriter returns a Recorder that writes a HAR document to writer every
+// time a segment is full and on Flush and Close, so writer may receive
+// several documents
+func NewWriter(writer io.Writer, opts ...Option) *Recorder {
+	return newRecorder(&writerOutput{writer: writer}, opts...)
+}
+
+// NewFile returns a Recorder that keeps the file at path up to date with the
+// current segment. Full segments are rotated to path.1 (the most recent) up to
+// path.N where N is set with WithMaxFiles.
+func NewFile(path string, opts ...Option) (*Recorder, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("har: %w", err)
+	}
+	out := &fileOutput{path: path}
+	r := newRecorder(out, opts...)
+	out.maxFiles = r.maxFiles
+	return r, nil
+}
+
+func newRecorder(out output, opts ...Option) *Recorder {
+	r := &Recorder{
+		out:          out,
+		redactor:     newRedactor(),
+		creator:      Creator{Name: "github.com/techcraftlabs/base", Version: Version},
+		maxEntries:   defaultMaxEntries,
+		maxFiles:     defaultMaxFiles,
+		replyTimeout: defaultReplyTimeout,
+		pending:      make(map[string]*Entry),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Record adds the exchange to the archive, it is a base.ExchangeHook
+func (r *Recorder) Record(exchange *base.Exchange) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+
+	r.expire(time.Now())
+
+	switch exchange.Kind {
+	case base.ExchangeReceived:
+		entry := r.entry(exchange)
+		entry.Request = r.redactor.request(exchange.Request, exchange.RequestBody)
+		entry.Response = r.redactor.response(nil, nil)
+		entry.Timings = newTimings(exchange.Duration, 0, 0)
+		if exchange.RequestID == "" || exchange.Err != nil {
+			r.add(entry)
+			return
+		}
+		if displaced, ok := r.pending[exchange.RequestID]; ok {
+			// a retried callback with the same request ID, keep the first one
+			displaced.Comment = "no reply recorded, replaced by a request with the same id"
+			r.add(displaced)
+		}
+		r.pending[exchange.RequestID] = entry
+
+	case base.ExchangeReplied:
+		entry, ok := r.pending[exchange.RequestID]
+		if ok {
+			delete(r.pending, exchange.RequestID)
+			received := time.Duration(entry.Timings.Send * float64(time.Millisecond))
+			wait := exchange.Started.Sub(entry.StartedDateTime) - received
+			entry.Timings = newTimings(received, wait, exchange.Duration)
+			entry.Kind = "received"
+			if exchange.Err != nil {
+				entry.Error = exchange.Err.Error()
+			}
+		} else {
+			entry = r.entry(exchange)
+			entry.Request = r.redactor.request(nil, nil)
+			entry.Timings = newTimings(0, 0, exchange.Duration)
+			entry.Comment = "reply without a recorded request"
+		}
+		entry.Response = r.redactor.response(exchange.Response, exchange.ResponseBody)
+		entry.Time = milliseconds(exchange.Started.Add(exchange.Duration).Sub(entry.StartedDateTime))
+		r.add(entry)
+
+	default:
+		entry := r.entry(exchange)
+		entry.Request = r.redactor.request(exchange.Request, exchange.RequestBody)
+		entry.Response = r.redactor.response(exchange.Response, exchange.ResponseBody)
+		entry.Timings = newTimings(0, exchange.Duration, 0)
+		r.add(entry)
+	}
+}
+
+func (r *Recorder) entry(exchange *base.Exchange) *Entry {
+	entry := &Entry{
+		StartedDateTime: exchange.Started,
+		Time:            milliseconds(exchange.Duration),
+		Name:            exchange.Name,
+		Kind:            exchange.Kind.String(),
+		RequestID:       exchange.RequestID,
+	}
+	if exchange.Err != nil {
+		entry.Error = exchange.Err.Error()
+	}
+	return entry
+}
+
+// expire records the received requests that waited too long for a reply
+func (r *Recorder) expire(now time.Time) {
+	for id, entry := range r.pending {
+		if now.Sub(entry.StartedDateTime) >= r.replyTimeout {
+			delete(r.pending, id)
+			entry.Comment = "no reply recorded"
+			r.add(entry)
+		}
+	}
+}
+
+func (r *Recorder) add(entry *Entry) {
+	r.entries = append(r.entries, *entry)
+	r.handle(r.out.save(r.har()))
+	if len(r.entries) >= r.maxEntries {
+		r.handle(r.out.rotate(r.har()))
+		r.entries = nil
+	}
+}
+
+func (r *Recorder) har() *HAR {
+	entries := r.entries
+	if entries == nil {
+		entries = []Entry{}
+	}
+	return &HAR{Log: Log{Version: Version, Creator: r.creator, Entries: entries}}
+}
+
+func (r *Recorder) handle(err error) {
+	if err != nil && r.onError != nil {
+		r.onError(err)
+	}
+}
+
+// Entries returns a copy of the entries of the current segment
+func (r *Recorder) Entries() []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Entry(nil), r.entries...)
+}
+
+// Flush writes the current segment to the output. For NewWriter the
+// segment is then discarded.
+func (r *Recorder) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.flush()
+}
+
+func (r *Recorder) flush() error {
+	if len(r.entries) == 0 {
+		return nil
+	}
+	err := r.out.flush(r.har())
+	if _, ok := r.out.(*writerOutput); ok {
+		r.entries = nil
+	}
+	return err
+}
+
+// Close records the received requests still waiting for a reply and
+// flushes the current segment, exchanges recorded after Close are ignored
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	r.expire(time.Now().Add(r.replyTimeout))
+	err := r.flush()
+	if cErr := r.out.close(); err == nil {
+		err = cErr
+	}
+	return err
+}
+
+func (w *writerOutput) save(*HAR) error {
+	return nil
+}
+
+func (w *writerOutput) rotate(har *HAR) error {
+	return w.flush(har)
+}
+
+func (w *writerOutput) flush(har *HAR) error {
+	encoder := json.NewEncoder(w.writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(har); err != nil {
+		return fmt.Errorf("har: %w", err)
+	}
+	return nil
+}
+
+func (w *writerOutput) close() error {
+	return nil
+}
+
+// save writes the entries of the segment that are not in the file yet,
+// the first one creates the file with the envelope of the document
+func (f *fileOutput) save(har *HAR) error {
+	if f.file == nil {
+		if err := f.create(har); err != nil {
+			return err
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, entry := range har.Log.Entries[f.written:] {
+		b, err := json.MarshalIndent(entry, "    ", "  ")
+		if err != nil {
+			return fmt.Errorf("har: %w", err)
+		}
+		if f.written > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString("\n    ")
+		buf.Write(b)
+		f.written++
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+
+	// the closing brackets are overwritten and written again after the
+	// new entries, the file only grows
+	n := int64(buf.Len())
+	buf.WriteString("\n  ")
+	buf.Write(f.trailer)
+	if _, err := f.file.WriteAt(buf.Bytes(), f.tail); err != nil {
+		return fmt.Errorf("har: %w", err)
+	}
+	f.tail += n
+	return nil
+}
+
+// create truncates the file and writes a document without entries
+func (f *fileOutput) create(har *HAR) error {
+	envelope := &HAR{Log: Log{Version: har.Log.Version, Creator: har.Log.Creator, Entries: []Entry{}}}
+	b, err := json.MarshalIndent(envelope, "", "  ")
+	if err != nil {
+		return fmt.Errorf("har: %w", err)
+	}
+	i := bytes.LastIndex(b, []byte("[]"))
+	head := b[:i+1]
+	f.trailer = append([]byte(nil), b[i+1:]...)
+
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("har: %w", err)
+	}
+	if _, err := file.Write(append(head, f.trailer...)); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("har: %w", err)
+	}
+	f.file = file
+	f.written = 0
+	f.tail = int64(len(head))
+	return nil
+}
+
+// rotate closes the file and shifts path.N-1 to path.N, ..., path to path.1,
+// the segment has already been saved to path
+func (f *fileOutput) rotate(*HAR) error {
+	if err := f.close(); err != nil {
+		return err
+	}
+	if f.maxFiles == 0 {
+		return os.Remove(f.path)
+	}
+
+	_ = os.Remove(fmt.Sprintf("%s.%d", f.path, f.maxFiles))
+	for i := f.maxFiles - 1; i >= 1; i-- {
+		from := fmt.Sprintf("%s.%d", f.path, i)
+		if _, err := os.Stat(from); err != nil {
+			continue
+		}
+		if err := os.Rename(from, fmt.Sprintf("%s.%d", f.path, i+1)); err != nil {
+			return fmt.Errorf("har: %w", err)
+		}
+	}
+	if err := os.Rename(f.path, f.path+".1"); err != nil {
+		return fmt.Errorf("har: %w", err)
+	}
+	return nil
+}
+
+// flush writes the entries that are not in the file yet and syncs it
+func (f *fileOutput) flush(har *HAR) error {
+	if err := f.save(har); err != nil {
+		return err
+	}
+	if err := f.file.Sync(); err != nil {
+		return fmt.Errorf("har: %w", err)
+	}
+	return nil
+}
+
+func (f *fileOutput) close() error {
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	f.written = 0
+	if err != nil {
+		return fmt.Errorf("har: %w", err)
+	}
+	return nil
+}
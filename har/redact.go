@@ -0,0 +1,149 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package har
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces the values of sensitive headers and fields
+const Redacted = "[REDACTED]"
+
+var (
+	defaultRedactedHeaders = []string{
+		"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-API-Key",
+	}
+	defaultRedactedFields = []string{
+		"password", "pin", "secret", "token", "access_token", "refresh_token",
+	}
+)
+
+// redactor removes sensitive values from headers, query strings and bodies.
+// Header and field names are matched case insensitively.
+type redactor struct {
+	headers map[string]bool
+	fields  map[string]bool
+	xml     []*regexp.Regexp
+}
+
+func newRedactor() *redactor {
+	r := &redactor{
+		headers: make(map[string]bool),
+		fields:  make(map[string]bool),
+	}
+	r.addHeaders(defaultRedactedHeaders...)
+	r.addFields(defaultRedactedFields...)
+	return r
+}
+
+func (r *redactor) addHeaders(names ...string) {
+	for _, name := range names {
+		r.headers[strings.ToLower(name)] = true
+	}
+}
+
+func (r *redactor) addFields(names ...string) {
+	for _, name := range names {
+		name = strings.ToLower(name)
+		if r.fields[name] {
+			continue
+		}
+		r.fields[name] = true
+		// <name>value</name> with an optional namespace prefix
+		r.xml = append(r.xml, regexp.MustCompile(fmt.Sprintf(
+			`(?is)(<(?:[\w-]+:)?%[1]s(?:\s[^>]*)?>)[^<]*(</(?:[\w-]+:)?%[1]s>)`, regexp.QuoteMeta(name))))
+	}
+}
+
+func (r *redactor) isRedactedHeader(name string) bool {
+	return r.headers[strings.ToLower(name)]
+}
+
+func (r *redactor) values(values url.Values) {
+	for name := range values {
+		if r.fields[strings.ToLower(name)] {
+			for i := range values[name] {
+				values[name][i] = Redacted
+			}
+		}
+	}
+}
+
+// body redacts the fields of JSON, XML and form bodies, other bodies are
+// returned unchanged
+func (r *redactor) body(contentType string, body []byte) []byte {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case strings.Contains(mediaType, "json"):
+		var v interface{}
+		if err := json.Unmarshal(body, &v); err != nil {
+			return body
+		}
+		redacted, err := json.Marshal(r.json(v))
+		if err != nil {
+			return body
+		}
+		return redacted
+
+	case strings.Contains(mediaType, "xml"):
+		for _, re := range r.xml {
+			body = re.ReplaceAll(body, []byte("${1}"+Redacted+"${2}"))
+		}
+		return body
+
+	case mediaType == "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return body
+		}
+		r.values(values)
+		return []byte(values.Encode())
+	}
+	return body
+}
+
+func (r *redactor) json(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if r.fields[strings.ToLower(key)] {
+				value[key] = Redacted
+				continue
+			}
+			value[key] = r.json(field)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = r.json(item)
+		}
+	}
+	return v
+}
@@ -31,6 +31,7 @@ import (
 	"encoding/xml"
 	"errors"
 	"fmt"
+	"github.com/techcraftlabs/base/crypto"
 	"net/url"
 )
 
@@ -41,8 +42,16 @@ var (
 )
 
 // MarshalPayload returns the JSON/XML encoding of Body.
+// The fields of an EncryptedPayload are encrypted before encoding.
 func MarshalPayload(payloadType PayloadType, payload interface{}) (buffer *bytes.Buffer, err error) {
 
+	if ep, ok := payload.(*encryptedPayload); ok {
+		payload, err = crypto.EncryptedCopy(ep.payload, ep.cipher)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	switch payloadType {
 	case JsonPayload:
 		buf, err := json.Marshal(payload)
@@ -78,3 +87,18 @@ func MarshalPayload(payloadType PayloadType, payload interface{}) (buffer *bytes
 	}
 
 }
+
+type encryptedPayload struct {
+	payload interface{}
+	cipher  crypto.FieldCipher
+}
+
+// EncryptedPayload wraps a struct payload so that MarshalPayload encrypts its
+// fields tagged with crypto.EncryptTag, e.g. with a *crypto.Envelope. The
+// fields of payload itself are left in plaintext.
+func EncryptedPayload(payload interface{}, cipher crypto.FieldCipher) interface{} {
+	return &encryptedPayload{
+		payload: payload,
+		cipher:  cipher,
+	}
+}
@@ -0,0 +1,76 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package base
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/techcraftlabs/base/crypto"
+)
+
+func TestMarshalPayload_Encrypted(t *testing.T) {
+	type payment struct {
+		MSISDN string `json:"msisdn" xml:"msisdn" encrypt:"true"`
+		Amount string `json:"amount" xml:"amount"`
+	}
+	key, _ := crypto.NewDataKey()
+	env, err := crypto.NewEnvelopeWithKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pt := range []PayloadType{JsonPayload, XmlPayload} {
+		t.Run(pt.String(), func(t *testing.T) {
+			p := &payment{MSISDN: "255754000000", Amount: "1000"}
+			buf, err := MarshalPayload(pt, EncryptedPayload(p, env))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.MSISDN != "255754000000" {
+				t.Errorf("MarshalPayload() modified the payload: %+v", p)
+			}
+			if strings.Contains(buf.String(), p.MSISDN) {
+				t.Errorf("MarshalPayload() = %s, msisdn is not encrypted", buf)
+			}
+			if pt != JsonPayload {
+				return
+			}
+
+			got := new(payment)
+			if err := json.Unmarshal(buf.Bytes(), got); err != nil {
+				t.Fatal(err)
+			}
+			if got.MSISDN == p.MSISDN || got.Amount != p.Amount {
+				t.Errorf("MarshalPayload() = %s", buf)
+			}
+			if err := crypto.DecryptFields(got, env); err != nil || got.MSISDN != p.MSISDN {
+				t.Errorf("DecryptFields() = %+v, %v", got, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,282 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var (
+	_ Recorder = (*Memory)(nil)
+
+	// DefaultBuckets are the upper bounds in seconds of the request duration histogram
+	DefaultBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+)
+
+type (
+	// Memory is a Recorder that keeps the measurements in memory and
+	// exposes them in the Prometheus text format with Handler
+	Memory struct {
+		mu        sync.Mutex
+		buckets   []float64
+		requests  map[requestKey]uint64
+		durations map[seriesKey]*histogram
+		inFlight  map[seriesKey]int64
+		retries   map[seriesKey]uint64
+		callbacks map[callbackKey]uint64
+		replies   map[string]uint64
+	}
+
+	requestKey struct {
+		name, method, class, mno string
+	}
+
+	seriesKey struct {
+		name, mno string
+	}
+
+	callbackKey struct {
+		name, outcome string
+	}
+
+	histogram struct {
+		counts []uint64
+		count  uint64
+		sum    float64
+	}
+)
+
+// NewMemory creates a Memory recorder, DefaultBuckets are used when no buckets are given
+func NewMemory(buckets ...float64) *Memory {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return &Memory{
+		buckets:   buckets,
+		requests:  make(map[requestKey]uint64),
+		durations: make(map[seriesKey]*histogram),
+		inFlight:  make(map[seriesKey]int64),
+		retries:   make(map[seriesKey]uint64),
+		callbacks: make(map[callbackKey]uint64),
+		replies:   make(map[string]uint64),
+	}
+}
+
+func (m *Memory) RequestStarted(name, mno string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight[seriesKey{name, mno}]++
+}
+
+func (m *Memory) RequestFinished(name, method, mno string, status int, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	series := seriesKey{name, mno}
+	m.inFlight[series]--
+	m.requests[requestKey{name, method, StatusClass(status), mno}]++
+
+	h, ok := m.durations[series]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(m.buckets))}
+		m.durations[series] = h
+	}
+	seconds := duration.Seconds()
+	for i, upper := range m.buckets {
+		if seconds <= upper {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+func (m *Memory) RetryObserved(name, mno string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retries[seriesKey{name, mno}]++
+}
+
+func (m *Memory) CallbackReceived(name, outcome string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.callbacks[callbackKey{name, outcome}]++
+}
+
+func (m *Memory) ReplySent(status int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.replies[StatusClass(status)]++
+}
+
+// Requests returns the number of finished requests with the given labels
+func (m *Memory) Requests(name, method, statusClass, mno string) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.requests[requestKey{name, method, statusClass, mno}]
+}
+
+// InFlight returns the number of requests that have started and not finished
+func (m *Memory) InFlight(name, mno string) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.inFlight[seriesKey{name, mno}]
+}
+
+func (m *Memory) Retries(name, mno string) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.retries[seriesKey{name, mno}]
+}
+
+func (m *Memory) Callbacks(name, outcome string) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.callbacks[callbackKey{name, outcome}]
+}
+
+// Handler serves the measurements in the Prometheus text exposition format
+func (m *Memory) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		_, _ = m.WriteTo(w)
+	})
+}
+
+// WriteTo writes the measurements in the Prometheus text exposition format
+func (m *Memory) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	buf := new(bytes.Buffer)
+
+	family(buf, "base_client_requests_total", "counter", "Requests sent by base.Client.")
+	lines := make([]string, 0, len(m.requests))
+	for k, v := range m.requests {
+		lines = append(lines, sample("base_client_requests_total", v,
+			"name", k.name, "method", k.method, "status_class", k.class, "mno", k.mno))
+	}
+	write(buf, lines)
+
+	family(buf, "base_client_requests_in_flight", "gauge", "Requests being sent by base.Client.")
+	lines = lines[:0]
+	for k, v := range m.inFlight {
+		lines = append(lines, sample("base_client_requests_in_flight", v, "name", k.name, "mno", k.mno))
+	}
+	write(buf, lines)
+
+	family(buf, "base_client_request_duration_seconds", "histogram", "Duration of base.Client.Do.")
+	keys := make([]seriesKey, 0, len(m.durations))
+	for k := range m.durations {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].name+"\x00"+keys[i].mno < keys[j].name+"\x00"+keys[j].mno
+	})
+	for _, k := range keys {
+		h := m.durations[k]
+		for i, upper := range m.buckets {
+			buf.WriteString(sample("base_client_request_duration_seconds_bucket", h.counts[i],
+				"name", k.name, "mno", k.mno, "le", strconv.FormatFloat(upper, 'g', -1, 64)))
+		}
+		buf.WriteString(sample("base_client_request_duration_seconds_bucket", h.count,
+			"name", k.name, "mno", k.mno, "le", "+Inf"))
+		buf.WriteString(sample("base_client_request_duration_seconds_sum", h.sum, "name", k.name, "mno", k.mno))
+		buf.WriteString(sample("base_client_request_duration_seconds_count", h.count, "name", k.name, "mno", k.mno))
+	}
+
+	family(buf, "base_client_retries_total", "counter", "Requests sent again after a failure.")
+	lines = lines[:0]
+	for k, v := range m.retries {
+		lines = append(lines, sample("base_client_retries_total", v, "name", k.name, "mno", k.mno))
+	}
+	write(buf, lines)
+
+	family(buf, "base_receiver_callbacks_total", "counter", "Callbacks received by base.Receiver.")
+	lines = lines[:0]
+	for k, v := range m.callbacks {
+		lines = append(lines, sample("base_receiver_callbacks_total", v, "name", k.name, "outcome", k.outcome))
+	}
+	write(buf, lines)
+
+	family(buf, "base_replier_replies_total", "counter", "Replies sent by base.Replier.")
+	lines = lines[:0]
+	for class, v := range m.replies {
+		lines = append(lines, sample("base_replier_replies_total", v, "status_class", class))
+	}
+	write(buf, lines)
+	m.mu.Unlock()
+
+	return buf.WriteTo(w)
+}
+
+func family(buf *bytes.Buffer, name, kind, help string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func write(buf *bytes.Buffer, lines []string) {
+	sort.Strings(lines)
+	for _, line := range lines {
+		buf.WriteString(line)
+	}
+}
+
+func sample(name string, value interface{}, labels ...string) string {
+	builder := strings.Builder{}
+	builder.WriteString(name)
+	builder.WriteByte('{')
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			builder.WriteByte(',')
+		}
+		fmt.Fprintf(&builder, "%s=\"%s\"", labels[i], escape(labels[i+1]))
+	}
+	builder.WriteByte('}')
+	switch v := value.(type) {
+	case float64:
+		fmt.Fprintf(&builder, " %s\n", strconv.FormatFloat(v, 'g', -1, 64))
+	default:
+		fmt.Fprintf(&builder, " %d\n", v)
+	}
+	return builder.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(s string) string {
+	return labelEscaper.Replace(s)
+}
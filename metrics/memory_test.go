@@ -0,0 +1,130 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMemory_WriteTo(t *testing.T) {
+	m := NewMemory(1, 0.1)
+	m.RequestStarted("pay", "vodacom")
+	m.RequestStarted("pay", "vodacom")
+	m.RequestFinished("pay", http.MethodPost, "vodacom", http.StatusOK, 50*time.Millisecond)
+	m.RequestStarted("pay", "vodacom")
+	m.RequestFinished("pay", http.MethodPost, "vodacom", http.StatusOK, 100*time.Millisecond)
+	m.RequestStarted("pay", "vodacom")
+	m.RequestFinished("pay", http.MethodPost, "vodacom", 0, 2*time.Second)
+	m.RequestStarted("status", "")
+	m.RequestFinished("status", http.MethodGet, "", http.StatusBadGateway, 500*time.Millisecond)
+	m.RetryObserved("pay", "vodacom")
+	m.CallbackReceived("pay \"callback\"", OutcomeOK)
+	m.CallbackReceived("pay \"callback\"", OutcomeDecodeError)
+	m.ReplySent(http.StatusOK)
+	m.ReplySent(http.StatusUnauthorized)
+
+	want := `# HELP base_client_requests_total Requests sent by base.Client.
+# TYPE base_client_requests_total counter
+base_client_requests_total{name="pay",method="POST",status_class="2xx",mno="vodacom"} 2
+base_client_requests_total{name="pay",method="POST",status_class="error",mno="vodacom"} 1
+base_client_requests_total{name="status",method="GET",status_class="5xx",mno=""} 1
+# HELP base_client_requests_in_flight Requests being sent by base.Client.
+# TYPE base_client_requests_in_flight gauge
+base_client_requests_in_flight{name="pay",mno="vodacom"} 1
+base_client_requests_in_flight{name="status",mno=""} 0
+# HELP base_client_request_duration_seconds Duration of base.Client.Do.
+# TYPE base_client_request_duration_seconds histogram
+base_client_request_duration_seconds_bucket{name="pay",mno="vodacom",le="0.1"} 2
+base_client_request_duration_seconds_bucket{name="pay",mno="vodacom",le="1"} 2
+base_client_request_duration_seconds_bucket{name="pay",mno="vodacom",le="+Inf"} 3
+base_client_request_duration_seconds_sum{name="pay",mno="vodacom"} 2.15
+base_client_request_duration_seconds_count{name="pay",mno="vodacom"} 3
+base_client_request_duration_seconds_bucket{name="status",mno="",le="0.1"} 0
+base_client_request_duration_seconds_bucket{name="status",mno="",le="1"} 1
+base_client_request_duration_seconds_bucket{name="status",mno="",le="+Inf"} 1
+base_client_request_duration_seconds_sum{name="status",mno=""} 0.5
+base_client_request_duration_seconds_count{name="status",mno=""} 1
+# HELP base_client_retries_total Requests sent again after a failure.
+# TYPE base_client_retries_total counter
+base_client_retries_total{name="pay",mno="vodacom"} 1
+# HELP base_receiver_callbacks_total Callbacks received by base.Receiver.
+# TYPE base_receiver_callbacks_total counter
+base_receiver_callbacks_total{name="pay \"callback\"",outcome="decode_error"} 1
+base_receiver_callbacks_total{name="pay \"callback\"",outcome="ok"} 1
+# HELP base_replier_replies_total Replies sent by base.Replier.
+# TYPE base_replier_replies_total counter
+base_replier_replies_total{status_class="2xx"} 1
+base_replier_replies_total{status_class="4xx"} 1
+`
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("WriteTo() got\n%s\nwant\n%s", got, want)
+	}
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Header().Get("Content-Type") != contentType || rec.Body.String() != want {
+		t.Errorf("Handler() served %s:\n%s", rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+}
+
+func TestMemory_Empty(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := NewMemory().WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP base_client_requests_total Requests sent by base.Client.
+# TYPE base_client_requests_total counter
+# HELP base_client_requests_in_flight Requests being sent by base.Client.
+# TYPE base_client_requests_in_flight gauge
+# HELP base_client_request_duration_seconds Duration of base.Client.Do.
+# TYPE base_client_request_duration_seconds histogram
+# HELP base_client_retries_total Requests sent again after a failure.
+# TYPE base_client_retries_total counter
+# HELP base_receiver_callbacks_total Callbacks received by base.Receiver.
+# TYPE base_receiver_callbacks_total counter
+# HELP base_replier_replies_total Replies sent by base.Replier.
+# TYPE base_replier_replies_total counter
+`
+	if buf.String() != want {
+		t.Errorf("WriteTo() got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestStatusClass(t *testing.T) {
+	for status, want := range map[int]string{0: "error", -1: "error", 200: "2xx", 302: "3xx", 404: "4xx", 503: "5xx"} {
+		if got := StatusClass(status); got != want {
+			t.Errorf("StatusClass(%d) = %s, want %s", status, got, want)
+		}
+	}
+}
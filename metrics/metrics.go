@@ -0,0 +1,81 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+// Package metrics defines the Recorder used to instrument base.Client,
+// base.Receiver and base.Replier, and a dependency free in-memory Recorder
+// that can be scraped by Prometheus.
+package metrics
+
+import (
+	"strconv"
+	"time"
+)
+
+const (
+	OutcomeOK           = "ok"
+	OutcomeReadError    = "read_error"
+	OutcomeDecodeError  = "decode_error"
+	OutcomeUnauthorized = "unauthorized"
+)
+
+var (
+	_ Recorder = Nop{}
+)
+
+type (
+	// Recorder receives the measurements of the instrumented components.
+	// Implementations must be safe for concurrent use.
+	//
+	// RequestStarted and RequestFinished bracket every base.Client.Do, status
+	// is 0 when no response was received. RetryObserved is called when a request
+	// is going to be sent again e.g. by an outbox worker. CallbackReceived is called
+	// by base.Receiver.Receive with one of the Outcome constants and ReplySent
+	// by base.Replier.Reply.
+	Recorder interface {
+		RequestStarted(name, mno string)
+		RequestFinished(name, method, mno string, status int, duration time.Duration)
+		RetryObserved(name, mno string)
+		CallbackReceived(name, outcome string)
+		ReplySent(status int)
+	}
+
+	// Nop is a Recorder that discards everything
+	Nop struct{}
+)
+
+func (Nop) RequestStarted(string, string)                              {}
+func (Nop) RequestFinished(string, string, string, int, time.Duration) {}
+func (Nop) RetryObserved(string, string)                               {}
+func (Nop) CallbackReceived(string, string)                            {}
+func (Nop) ReplySent(int)                                              {}
+
+// StatusClass returns "2xx", "4xx" ... for status and "error"
+// when status is 0 i.e. no response was received
+func StatusClass(status int) string {
+	if status <= 0 {
+		return "error"
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
@@ -0,0 +1,94 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package base
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/techcraftlabs/base/metrics"
+)
+
+func TestMetrics_Instrumentation(t *testing.T) {
+	recorder := metrics.NewMemory()
+	receiver := NewReceiver(io.Discard, false, MetricsOption(recorder))
+	replier := NewReplier(io.Discard, false, MetricsOption(recorder))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload := make(map[string]string)
+		if _, err := receiver.Receive(r.Context(), "callback", r, &payload); err != nil {
+			replier.Reply(w, NewResponse(http.StatusBadRequest, nil))
+			return
+		}
+		replier.Reply(w, NewResponse(http.StatusOK, nil))
+	}))
+	defer server.Close()
+
+	client := NewClient(WithDebugMode(false), WithMetrics(recorder))
+	request := NewRequest("callback", http.MethodPost, server.URL, map[string]string{"status": "SUCCESS"})
+	request.MNO = "vodacom"
+	for i := 0; i < 2; i++ {
+		if _, err := client.Do(context.Background(), request, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	invalid := NewRequest("callback", http.MethodPost, server.URL, nil,
+		WithRequestHeaders(map[string]string{"Content-Type": "application/json"}))
+	invalid.Payload = "{"
+	if _, err := client.Do(context.Background(), invalid, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := recorder.Requests("callback", http.MethodPost, "2xx", "vodacom"); got != 2 {
+		t.Errorf("Requests() = %d, want 2", got)
+	}
+	if got := recorder.InFlight("callback", "vodacom"); got != 0 {
+		t.Errorf("InFlight() = %d, want 0", got)
+	}
+	if got := recorder.Callbacks("callback", metrics.OutcomeOK); got != 2 {
+		t.Errorf("Callbacks(ok) = %d, want 2", got)
+	}
+	if got := recorder.Callbacks("callback", metrics.OutcomeDecodeError); got != 1 {
+		t.Errorf("Callbacks(decode_error) = %d, want 1", got)
+	}
+
+	var buf bytes.Buffer
+	_, _ = recorder.WriteTo(&buf)
+	for _, line := range []string{
+		`base_client_request_duration_seconds_count{name="callback",mno="vodacom"} 2`,
+		`base_client_requests_total{name="callback",method="POST",status_class="4xx",mno=""} 1`,
+		`base_replier_replies_total{status_class="2xx"} 2`,
+		`base_replier_replies_total{status_class="4xx"} 1`,
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("exposition has no line %s:\n%s", line, buf.String())
+		}
+	}
+}
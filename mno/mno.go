@@ -0,0 +1,469 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+// Package mno identifies the mobile network operator (MNO) that owns an
+// MSISDN. Operators are grouped by country and matched using number prefixes
+// or number ranges of the national significant number (NSN), i.e. the number
+// without the country dial code and without the trunk prefix "0".
+//
+// The rules change every now and then when regulators allocate new ranges, so
+// a Registry can be updated at runtime from a JSON or YAML file.
+package mno
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/techcraftlabs/base/countries"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	Vodacom   = "VODACOM"
+	Tigo      = "TIGO"
+	Airtel    = "AIRTEL"
+	Halotel   = "HALOTEL"
+	TTCL      = "TTCL"
+	Zantel    = "ZANTEL"
+	Safaricom = "SAFARICOM"
+	Telkom    = "TELKOM"
+)
+
+const (
+	JSON Format = iota
+	YAML
+)
+
+// defaultWatchInterval is used by Registry.Watch when the interval is not positive
+const defaultWatchInterval = time.Minute
+
+var (
+	ErrInvalidNumber   = errors.New("mno: invalid msisdn")
+	ErrUnknownCountry  = errors.New("mno: unknown country")
+	ErrUnknownOperator = errors.New("mno: no operator matches the msisdn")
+	ErrUnknownFormat   = errors.New("mno: unknown file format")
+)
+
+type (
+	// Format is the encoding of a rules file
+	Format int
+
+	// Range is an inclusive range of NSN prefixes. From and To must have
+	// the same number of digits e.g. From: "700" To: "729" matches every
+	// number starting with 700, 701 ... 729
+	Range struct {
+		From string `json:"from" yaml:"from"`
+		To   string `json:"to" yaml:"to"`
+	}
+
+	// Operator is a mobile network operator and the rules used to
+	// tell whether a number belongs to it.
+	Operator struct {
+		Name     string   `json:"name" yaml:"name"`
+		Country  string   `json:"country,omitempty" yaml:"country,omitempty"`
+		Prefixes []string `json:"prefixes,omitempty" yaml:"prefixes,omitempty"`
+		Ranges   []Range  `json:"ranges,omitempty" yaml:"ranges,omitempty"`
+	}
+
+	// Country holds the numbering plan of a single country. Code is the ISO
+	// code name as in countries.TanzaniaCodeName, DialCode is the country
+	// calling code without the plus sign and NumberLength is the length of
+	// the national significant number.
+	Country struct {
+		Code         string     `json:"code" yaml:"code"`
+		DialCode     string     `json:"dial_code" yaml:"dial_code"`
+		NumberLength int        `json:"number_length" yaml:"number_length"`
+		Operators    []Operator `json:"operators" yaml:"operators"`
+	}
+
+	// Rules is the layout of the JSON/YAML files accepted by Registry.Load
+	Rules struct {
+		Countries []Country `json:"countries" yaml:"countries"`
+	}
+
+	// Registry is a concurrency safe store of numbering plans.
+	Registry struct {
+		mu        sync.RWMutex
+		countries map[string]Country
+		order     []string // codes of the countries, longest dial code first
+	}
+)
+
+func (f Format) String() string {
+	if f == YAML {
+		return "yaml"
+	}
+	return "json"
+}
+
+// Match returns true if the national significant number nsn
+// belongs to the operator
+func (op Operator) Match(nsn string) bool {
+	for _, prefix := range op.Prefixes {
+		if strings.HasPrefix(nsn, prefix) {
+			return true
+		}
+	}
+
+	for _, r := range op.Ranges {
+		n := len(r.From)
+		if n == 0 || n != len(r.To) || len(nsn) < n {
+			continue
+		}
+		// equal length digit strings compare the same way as numbers
+		p := nsn[:n]
+		if p >= r.From && p <= r.To {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewRegistry creates a Registry that contains the given countries.
+// Use Default for a Registry with the builtin numbering plans.
+func NewRegistry(countries ...Country) *Registry {
+	r := &Registry{
+		mu:        sync.RWMutex{},
+		countries: make(map[string]Country),
+	}
+	r.Set(countries...)
+	return r
+}
+
+// Default returns a new Registry with the builtin numbering plans
+func Default() *Registry {
+	return NewRegistry(builtin()...)
+}
+
+// Set adds the countries to the registry, replacing the numbering plan
+// of countries that are already present
+func (r *Registry) Set(countries ...Country) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range countries {
+		code := strings.ToUpper(strings.TrimSpace(c.Code))
+		c.Code = code
+		ops := make([]Operator, len(c.Operators))
+		for i, op := range c.Operators {
+			op.Country = code
+			ops[i] = op
+		}
+		c.Operators = ops
+		r.countries[code] = c
+	}
+
+	r.order = r.order[:0]
+	for code := range r.countries {
+		r.order = append(r.order, code)
+	}
+	sort.Slice(r.order, func(i, j int) bool {
+		a, b := r.countries[r.order[i]], r.countries[r.order[j]]
+		if len(a.DialCode) != len(b.DialCode) {
+			return len(a.DialCode) > len(b.DialCode)
+		}
+		return a.Code < b.Code
+	})
+}
+
+// Country returns the numbering plan of the country with the given code
+func (r *Registry) Country(code string) (Country, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, ok := r.countries[strings.ToUpper(strings.TrimSpace(code))]
+	if !ok {
+		return Country{}, fmt.Errorf("%w: %s", ErrUnknownCountry, code)
+	}
+	return c, nil
+}
+
+// Lookup returns the Operator of an msisdn in international format
+// e.g. +255754000000, 255754000000 or 00255754000000. Countries are tried
+// longest dial code first, a country whose dial code matches but none of
+// its operators does not end the search.
+func (r *Registry) Lookup(msisdn string) (Operator, error) {
+	number, err := digits(msisdn)
+	if err != nil {
+		return Operator{}, err
+	}
+	number = strings.TrimPrefix(number, "00")
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, code := range r.order {
+		c := r.countries[code]
+		if !strings.HasPrefix(number, c.DialCode) {
+			continue
+		}
+		nsn := strings.TrimPrefix(number, c.DialCode)
+		if len(nsn) != c.NumberLength {
+			continue
+		}
+		if op, err := c.match(msisdn, nsn); err == nil {
+			return op, nil
+		}
+	}
+
+	return Operator{}, fmt.Errorf("%w: %s", ErrUnknownOperator, msisdn)
+}
+
+// LookupIn is like Lookup but the number is resolved within the country
+// with the given code, so local formats like 0754000000 or 754000000 are
+// accepted as well as the international one.
+func (r *Registry) LookupIn(code, msisdn string) (Operator, error) {
+	c, err := r.Country(code)
+	if err != nil {
+		return Operator{}, err
+	}
+
+	nsn, err := c.NSN(msisdn)
+	if err != nil {
+		return Operator{}, err
+	}
+
+	return c.match(msisdn, nsn)
+}
+
+// NSN returns the national significant number of msisdn
+func (c Country) NSN(msisdn string) (string, error) {
+	number, err := digits(msisdn)
+	if err != nil {
+		return "", err
+	}
+	number = strings.TrimPrefix(number, "00")
+
+	switch {
+	case len(number) == c.NumberLength:
+		return number, nil
+	case len(number) == c.NumberLength+1 && strings.HasPrefix(number, "0"):
+		return number[1:], nil
+	case len(number) == c.NumberLength+len(c.DialCode) && strings.HasPrefix(number, c.DialCode):
+		return number[len(c.DialCode):], nil
+	}
+
+	return "", fmt.Errorf("%w: %s is not a valid %s number", ErrInvalidNumber, msisdn, c.Code)
+}
+
+func (c Country) match(msisdn, nsn string) (Operator, error) {
+	for _, op := range c.Operators {
+		if op.Match(nsn) {
+			return op, nil
+		}
+	}
+	return Operator{}, fmt.Errorf("%w: %s", ErrUnknownOperator, msisdn)
+}
+
+// Load reads Rules from reader and merges them into the registry. The
+// numbering plans of the countries in the file replace the existing ones.
+func (r *Registry) Load(reader io.Reader, format Format) error {
+	var rules Rules
+	switch format {
+	case JSON:
+		if err := json.NewDecoder(reader).Decode(&rules); err != nil {
+			return fmt.Errorf("mno: could not decode json rules: %w", err)
+		}
+	case YAML:
+		if err := yaml.NewDecoder(reader).Decode(&rules); err != nil {
+			return fmt.Errorf("mno: could not decode yaml rules: %w", err)
+		}
+	default:
+		return ErrUnknownFormat
+	}
+
+	if err := rules.validate(); err != nil {
+		return err
+	}
+
+	r.Set(rules.Countries...)
+	return nil
+}
+
+// LoadFile is like Load, the format is determined by the file extension
+// (.json, .yaml or .yml)
+func (r *Registry) LoadFile(path string) error {
+	format, err := formatOf(path)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("mno: could not open rules file: %w", err)
+	}
+	defer file.Close()
+
+	return r.Load(file, format)
+}
+
+// Watch reloads the rules file at path every time its modification time or
+// its size changes, an older modification time, e.g. of a file restored from a
+// backup, is a change too. It checks the file after every interval, a minute when interval is
+// not positive, and blocks until ctx is done. Errors while reloading are passed
+// to onError if it is not nil, the registry keeps the last good rules in that
+// case.
+func (r *Registry) Watch(ctx context.Context, path string, interval time.Duration, onError func(error)) {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	var (
+		loaded   bool
+		lastMod  time.Time
+		lastSize int64
+	)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		info, err := os.Stat(path)
+		if err == nil && (!loaded || !info.ModTime().Equal(lastMod) || info.Size() != lastSize) {
+			err = r.LoadFile(path)
+			if err == nil {
+				loaded, lastMod, lastSize = true, info.ModTime(), info.Size()
+			}
+		}
+		if err != nil && onError != nil {
+			onError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (rules Rules) validate() error {
+	for _, c := range rules.Countries {
+		if c.Code == "" || c.DialCode == "" || c.NumberLength <= 0 {
+			return fmt.Errorf("mno: country %q must have code, dial_code and number_length", c.Code)
+		}
+		for _, op := range c.Operators {
+			if op.Name == "" {
+				return fmt.Errorf("mno: country %s has an operator with no name", c.Code)
+			}
+			for _, rg := range op.Ranges {
+				if len(rg.From) == 0 || len(rg.From) != len(rg.To) {
+					return fmt.Errorf("mno: %s range %s-%s: from and to must have the same length", op.Name, rg.From, rg.To)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func formatOf(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return JSON, nil
+	case ".yaml", ".yml":
+		return YAML, nil
+	}
+	return 0, fmt.Errorf("%w: %s", ErrUnknownFormat, path)
+}
+
+// digits strips the plus sign, spaces, dashes and brackets that are
+// common in written phone numbers
+func digits(msisdn string) (string, error) {
+	builder := strings.Builder{}
+	for i, r := range strings.TrimSpace(msisdn) {
+		switch {
+		case r >= '0' && r <= '9':
+			builder.WriteRune(r)
+		case r == '+' && i == 0, r == ' ', r == '-', r == '(', r == ')':
+		default:
+			return "", fmt.Errorf("%w: %s", ErrInvalidNumber, msisdn)
+		}
+	}
+
+	if builder.Len() == 0 {
+		return "", fmt.Errorf("%w: %s", ErrInvalidNumber, msisdn)
+	}
+	return builder.String(), nil
+}
+
+func builtin() []Country {
+	var (
+		tz = Country{
+			Code:         countries.TanzaniaCodeName,
+			DialCode:     "255",
+			NumberLength: 9,
+			Operators: []Operator{
+				{Name: Vodacom, Prefixes: []string{"74", "75", "76"}},
+				{Name: Tigo, Prefixes: []string{"65", "67", "71"}},
+				{Name: Airtel, Prefixes: []string{"68", "69", "78"}},
+				{Name: Halotel, Prefixes: []string{"61", "62"}},
+				{Name: TTCL, Prefixes: []string{"73"}},
+				{Name: Zantel, Prefixes: []string{"77"}},
+			},
+		}
+
+		ke = Country{
+			Code:         countries.KenyaCodeName,
+			DialCode:     "254",
+			NumberLength: 9,
+			Operators: []Operator{
+				{
+					Name: Safaricom,
+					Ranges: []Range{
+						{From: "700", To: "729"},
+						{From: "740", To: "743"},
+						{From: "745", To: "746"},
+						{From: "748", To: "748"},
+						{From: "757", To: "759"},
+						{From: "768", To: "769"},
+						{From: "790", To: "799"},
+						{From: "110", To: "111"},
+					},
+				},
+				{
+					Name: Airtel,
+					Ranges: []Range{
+						{From: "730", To: "739"},
+						{From: "750", To: "756"},
+						{From: "762", To: "762"},
+						{From: "780", To: "789"},
+						{From: "100", To: "102"},
+					},
+				},
+				{
+					Name:   Telkom,
+					Ranges: []Range{{From: "770", To: "779"}},
+				},
+			},
+		}
+	)
+
+	return []Country{tz, ke}
+}
@@ -0,0 +1,217 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package mno
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistry_Lookup(t *testing.T) {
+	registry := Default()
+	tests := []struct {
+		name    string
+		msisdn  string
+		want    string
+		wantErr error
+	}{
+		{name: "vodacom tz", msisdn: "+255 754 000 000", want: Vodacom},
+		{name: "tigo tz", msisdn: "255712000000", want: Tigo},
+		{name: "airtel tz", msisdn: "00255684000000", want: Airtel},
+		{name: "halotel tz", msisdn: "255621000000", want: Halotel},
+		{name: "safaricom ke", msisdn: "254722000000", want: Safaricom},
+		{name: "safaricom ke single range", msisdn: "254748000000", want: Safaricom},
+		{name: "airtel ke", msisdn: "+254-733-000000", want: Airtel},
+		{name: "unknown prefix", msisdn: "255999000000", wantErr: ErrUnknownOperator},
+		{name: "invalid number", msisdn: "2557540000ab", wantErr: ErrInvalidNumber},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := registry.Lookup(tt.msisdn)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Lookup() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Lookup() unexpected error: %v", err)
+			}
+			if got.Name != tt.want {
+				t.Errorf("Lookup() = %v, want %v", got.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistry_LookupIn(t *testing.T) {
+	registry := Default()
+	for _, msisdn := range []string{"0754000000", "754000000", "+255754000000"} {
+		op, err := registry.LookupIn("tz", msisdn)
+		if err != nil {
+			t.Fatalf("LookupIn(%s) unexpected error: %v", msisdn, err)
+		}
+		if op.Name != Vodacom || op.Country != "TZ" {
+			t.Errorf("LookupIn(%s) = %+v, want VODACOM in TZ", msisdn, op)
+		}
+	}
+
+	if _, err := registry.LookupIn("XX", "0754000000"); !errors.Is(err, ErrUnknownCountry) {
+		t.Errorf("LookupIn() error = %v, want %v", err, ErrUnknownCountry)
+	}
+}
+
+func TestRegistry_Load(t *testing.T) {
+	rules := `
+countries:
+  - code: TZ
+    dial_code: "255"
+    number_length: 9
+    operators:
+      - name: VODACOM
+        prefixes: ["74", "75", "76", "79"]
+`
+	registry := Default()
+	if err := registry.Load(strings.NewReader(rules), YAML); err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+
+	op, err := registry.Lookup("255790000000")
+	if err != nil || op.Name != Vodacom {
+		t.Errorf("Lookup() after Load = %v, %v want VODACOM", op.Name, err)
+	}
+
+	// the TZ plan is replaced as a whole while KE is untouched
+	if _, err := registry.Lookup("255712000000"); !errors.Is(err, ErrUnknownOperator) {
+		t.Errorf("Lookup() error = %v, want %v", err, ErrUnknownOperator)
+	}
+	if op, _ := registry.Lookup("254722000000"); op.Name != Safaricom {
+		t.Errorf("Lookup() = %v, want SAFARICOM", op.Name)
+	}
+
+	bad := `{"countries":[{"code":"KE","dial_code":"254","number_length":9,
+		"operators":[{"name":"X","ranges":[{"from":"70","to":"729"}]}]}]}`
+	if err := registry.Load(strings.NewReader(bad), JSON); err == nil {
+		t.Errorf("Load() expected a validation error")
+	}
+}
+
+func TestRegistry_Watch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	rules := `{"countries":[{"code":"TZ","dial_code":"255","number_length":9,
+		"operators":[{"name":"VODACOM","prefixes":["79"]}]}]}`
+	if err := os.WriteFile(path, []byte(rules), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	registry := Default()
+	for _, interval := range []time.Duration{0, -time.Second} {
+		// the file is loaded once and Watch returns instead of panicking
+		registry.Watch(ctx, path, interval, func(err error) { t.Errorf("Watch() error: %v", err) })
+	}
+	if op, err := registry.Lookup("255790000000"); err != nil || op.Name != Vodacom {
+		t.Errorf("Lookup() after Watch = %v, %v, want VODACOM", op.Name, err)
+	}
+}
+
+func TestRegistry_WatchOlderFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	registry := NewRegistry()
+	write := func(operator string, modTime time.Time) {
+		rules := `{"countries":[{"code":"TZ","dial_code":"255","number_length":9,
+			"operators":[{"name":"` + operator + `","prefixes":["79"]}]}]}`
+		if err := os.WriteFile(path, []byte(rules), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor := func(operator string) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			op, err := registry.Lookup("255790000000")
+			if err == nil && op.Name == operator {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Lookup() = %v, %v, want %s", op.Name, err, operator)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	now := time.Now()
+	write(Vodacom, now)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		registry.Watch(ctx, path, 5*time.Millisecond, nil)
+	}()
+	defer func() { cancel(); <-done }()
+	waitFor(Vodacom)
+
+	// a file restored from a backup has an older modification time
+	write(Halotel, now.Add(-time.Hour))
+	waitFor(Halotel)
+}
+
+func TestRegistry_LookupOverlappingDialCodes(t *testing.T) {
+	// every 12 digit number starting with 255 is a valid number of both
+	registry := NewRegistry(
+		Country{Code: "XA", DialCode: "25", NumberLength: 10, Operators: []Operator{{Name: "SHORT", Prefixes: []string{"5"}}}},
+		Country{Code: "XB", DialCode: "255", NumberLength: 9, Operators: []Operator{{Name: "LONG", Prefixes: []string{"7"}}}},
+	)
+	tests := []struct {
+		msisdn string
+		want   string
+	}{
+		{"255754000000", "LONG"},
+		// no operator of XB matches, the search goes on with XA
+		{"255654000000", "SHORT"},
+	}
+	for _, tt := range tests {
+		// the countries are in a map, a lookup must not depend on its order
+		for i := 0; i < 20; i++ {
+			op, err := registry.Lookup(tt.msisdn)
+			if err != nil || op.Name != tt.want {
+				t.Fatalf("Lookup(%s) = %v, %v, want %s", tt.msisdn, op.Name, err, tt.want)
+			}
+		}
+	}
+	if _, err := registry.Lookup("254654000000"); !errors.Is(err, ErrUnknownOperator) {
+		t.Errorf("Lookup() error = %v, want %v", err, ErrUnknownOperator)
+	}
+}
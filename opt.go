@@ -2,11 +2,26 @@ package base
 
 import (
 	"io"
+
+	"github.com/techcraftlabs/base/metrics"
+	"github.com/techcraftlabs/base/trace"
 )
 
 type Params struct {
 	DebugMode bool
 	Logger    io.Writer
+	Metrics   metrics.Recorder
+	Tracer    trace.Tracer
+
+	// RequestIDHeader is the header of the request ID read by a Receiver and
+	// echoed by a Replier, DefaultRequestIDHeader when empty
+	RequestIDHeader string
+
+	ExchangeHooks []ExchangeHook
+
+	// Authenticator checks the credentials of the requests received by a
+	// Receiver, none are checked when nil
+	Authenticator Authenticator
 }
 
 type OptionFunc func(params *Params)
@@ -22,3 +37,49 @@ func LoggerOption(writer io.Writer) OptionFunc {
 		params.Logger = writer
 	}
 }
+
+// MetricsOption sets the metrics.Recorder of a Receiver or a Replier
+func MetricsOption(recorder metrics.Recorder) OptionFunc {
+	return func(params *Params) {
+		params.Metrics = recorder
+	}
+}
+
+// TracerOption sets the trace.Tracer used by a Receiver to record a server
+// span for every received request
+func TracerOption(tracer trace.Tracer) OptionFunc {
+	return func(params *Params) {
+		params.Tracer = tracer
+	}
+}
+
+// RequestIDHeaderOption sets the header of the request ID read by a Receiver
+// and echoed by a Replier
+func RequestIDHeaderOption(header string) OptionFunc {
+	return func(params *Params) {
+		params.RequestIDHeader = header
+	}
+}
+
+func requestIDHeader(params *Params) string {
+	if params == nil || params.RequestIDHeader == "" {
+		return DefaultRequestIDHeader
+	}
+	return params.RequestIDHeader
+}
+
+// ExchangeHookOption sets the hooks called by a Receiver after every received
+// request and by a Replier after every reply, it replaces the hooks set before
+func ExchangeHookOption(hooks ...ExchangeHook) OptionFunc {
+	return func(params *Params) {
+		params.ExchangeHooks = hooks
+	}
+}
+
+// AuthenticatorOption sets the Authenticator a Receiver checks every received
+// request with, see JWTAuthenticator
+func AuthenticatorOption(authenticator Authenticator) OptionFunc {
+	return func(params *Params) {
+		params.Authenticator = authenticator
+	}
+}
@@ -0,0 +1,350 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	pendingDir           = "pending"
+	deadDir              = "dead"
+	corruptDir           = "corrupt"
+	defaultLeaseDuration = time.Minute
+)
+
+var (
+	_ Queue = (*FileQueue)(nil)
+
+	// DefaultRetrySchedule is used by NewFileQueue when no schedule is given
+	DefaultRetrySchedule = []time.Duration{
+		10 * time.Second, 30 * time.Second, time.Minute,
+		5 * time.Minute, 15 * time.Minute, time.Hour,
+	}
+)
+
+type (
+	// FileQueue is a Queue that keeps every message in its own JSON file.
+	// Pending messages live in dir/pending and dead letters in dir/dead, files
+	// are replaced atomically so a crash never leaves a half written message.
+	// Files that can not be decoded are moved to dir/corrupt.
+	// Leases are kept in memory so after a restart every pending message is due
+	// again, that is what makes the delivery at least once.
+	FileQueue struct {
+		mu       sync.Mutex
+		dir      string
+		schedule []time.Duration
+		lease    time.Duration
+		leased   map[string]time.Time
+	}
+
+	FileQueueOption func(queue *FileQueue)
+)
+
+// WithRetrySchedule sets the delays between delivery attempts, a message is
+// moved to the dead-letter store after len(schedule) failed retries
+func WithRetrySchedule(schedule ...time.Duration) FileQueueOption {
+	return func(queue *FileQueue) {
+		queue.schedule = schedule
+	}
+}
+
+// WithLeaseDuration sets how long a leased message stays hidden from other workers
+func WithLeaseDuration(d time.Duration) FileQueueOption {
+	return func(queue *FileQueue) {
+		if d > 0 {
+			queue.lease = d
+		}
+	}
+}
+
+// NewFileQueue creates the queue directories in dir if they do not exist
+func NewFileQueue(dir string, opts ...FileQueueOption) (*FileQueue, error) {
+	queue := &FileQueue{
+		mu:       sync.Mutex{},
+		dir:      dir,
+		schedule: DefaultRetrySchedule,
+		lease:    defaultLeaseDuration,
+		leased:   make(map[string]time.Time),
+	}
+
+	for _, opt := range opts {
+		opt(queue)
+	}
+
+	for _, d := range []string{pendingDir, deadDir, corruptDir} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0o700); err != nil {
+			return nil, fmt.Errorf("outbox: %w", err)
+		}
+	}
+
+	return queue, nil
+}
+
+func (q *FileQueue) Enqueue(_ context.Context, message *Message) error {
+	if message.ID == "" {
+		message.ID = NewID()
+	}
+	if err := validID(message.ID); err != nil {
+		return err
+	}
+	if message.CreatedAt.IsZero() {
+		message.CreatedAt = time.Now()
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.write(pendingDir, message)
+}
+
+func (q *FileQueue) Lease(ctx context.Context) (*Message, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	ids, err := q.ids(pendingDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// leased messages are skipped without being read, the others are read
+	// until one is due
+	now := time.Now()
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if until, ok := q.leased[id]; ok && until.After(now) {
+			continue
+		}
+		message, err := q.readOrQuarantine(pendingDir, id)
+		if err != nil {
+			return nil, err
+		}
+		if message == nil || message.NextAttempt.After(now) {
+			continue
+		}
+		q.leased[message.ID] = now.Add(q.lease)
+		return message, nil
+	}
+
+	return nil, ErrEmpty
+}
+
+func (q *FileQueue) Ack(_ context.Context, id string) error {
+	if err := validID(id); err != nil {
+		return err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.leased, id)
+
+	err := os.Remove(q.path(pendingDir, id))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return err
+}
+
+func (q *FileQueue) Nack(_ context.Context, id string, cause error) (bool, error) {
+	if err := validID(id); err != nil {
+		return false, err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.leased, id)
+
+	message, err := q.read(pendingDir, id)
+	if err != nil {
+		return false, err
+	}
+
+	message.Attempts++
+	if cause != nil {
+		message.LastError = cause.Error()
+	}
+
+	if IsPermanent(cause) || message.Attempts > len(q.schedule) {
+		if err := q.write(deadDir, message); err != nil {
+			return false, err
+		}
+		return false, os.Remove(q.path(pendingDir, id))
+	}
+
+	message.NextAttempt = time.Now().Add(q.schedule[message.Attempts-1])
+	if err := q.write(pendingDir, message); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (q *FileQueue) DeadLetters(_ context.Context) ([]*Message, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.list(deadDir)
+}
+
+// Requeue moves a dead letter back to the pending messages with its
+// attempts reset
+func (q *FileQueue) Requeue(_ context.Context, id string) error {
+	if err := validID(id); err != nil {
+		return err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	message, err := q.read(deadDir, id)
+	if err != nil {
+		return err
+	}
+	message.Attempts = 0
+	message.NextAttempt = time.Now()
+	if err := q.write(pendingDir, message); err != nil {
+		return err
+	}
+	return os.Remove(q.path(deadDir, id))
+}
+
+func (q *FileQueue) path(dir, id string) string {
+	return filepath.Join(q.dir, dir, id+".json")
+}
+
+func (q *FileQueue) write(dir string, message *Message) error {
+	buf, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("outbox: could not marshal message %s: %w", message.ID, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Join(q.dir, dir), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("outbox: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("outbox: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("outbox: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("outbox: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), q.path(dir, message.ID))
+}
+
+func (q *FileQueue) read(dir, id string) (*Message, error) {
+	buf, err := os.ReadFile(q.path(dir, id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("outbox: %w", err)
+	}
+
+	message := new(Message)
+	if err := json.Unmarshal(buf, message); err != nil {
+		return nil, fmt.Errorf("%w %s: %v", ErrCorrupt, id, err)
+	}
+	return message, nil
+}
+
+// list returns the messages of dir sorted by ID, i.e. in enqueue order
+func (q *FileQueue) list(dir string) ([]*Message, error) {
+	ids, err := q.ids(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]*Message, 0, len(ids))
+	for _, id := range ids {
+		message, err := q.readOrQuarantine(dir, id)
+		if err != nil {
+			return nil, err
+		}
+		if message != nil {
+			messages = append(messages, message)
+		}
+	}
+	return messages, nil
+}
+
+// readOrQuarantine is read that moves corrupt messages to dir/corrupt, where
+// they can be inspected, and returns a nil message for them so that they do
+// not stall the queue
+func (q *FileQueue) readOrQuarantine(dir, id string) (*Message, error) {
+	message, err := q.read(dir, id)
+	switch {
+	case errors.Is(err, ErrCorrupt):
+		delete(q.leased, id)
+		if err := os.Rename(q.path(dir, id), q.path(corruptDir, id)); err != nil {
+			return nil, fmt.Errorf("outbox: %w", err)
+		}
+		return nil, nil
+	case errors.Is(err, ErrNotFound):
+		return nil, nil
+	}
+	return message, err
+}
+
+// ids returns the IDs of the messages of dir sorted, i.e. in enqueue order
+func (q *FileQueue) ids(dir string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(q.dir, dir))
+	if err != nil {
+		return nil, fmt.Errorf("outbox: %w", err)
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(name, ".json"))
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// validID rejects IDs that are not a plain file name, they could be used
+// to read or remove files outside of the queue directory
+func validID(id string) error {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.HasPrefix(id, ".") {
+		return fmt.Errorf("outbox: invalid message id %q", id)
+	}
+	return nil
+}
@@ -0,0 +1,319 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+// Package outbox persists outgoing payment requests and received callbacks
+// so that they are not lost when the process crashes before acting on them.
+//
+// Messages are delivered at least once: a Message stays in the Queue until it
+// is acknowledged, failed deliveries are retried following a retry schedule and
+// messages that keep failing are moved to a dead-letter store. A Pool of workers
+// leases messages from a Queue and hands them to a Handler, SendHandler is a
+// Handler that sends stored requests with base.Client.Do.
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/techcraftlabs/base"
+)
+
+const (
+	KindRequest Kind = "request"
+	KindReceipt Kind = "receipt"
+)
+
+var (
+	// ErrEmpty is returned by Queue.Lease when no message is due
+	ErrEmpty = errors.New("outbox: no message is due")
+
+	// ErrNotFound is returned when the message ID is not in the queue
+	ErrNotFound = errors.New("outbox: message not found")
+
+	// ErrCorrupt is returned when a stored message can not be decoded
+	ErrCorrupt = errors.New("outbox: corrupt message")
+
+	// credentialHeaders are not stored, credentials are set at send time
+	// with the modifiers passed to SendHandler
+	credentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Api-Key"}
+)
+
+type (
+	Kind string
+
+	// Message is a unit of work stored in a Queue. Request is set for messages
+	// of KindRequest and Receipt for messages of KindReceipt.
+	Message struct {
+		ID          string          `json:"id"`
+		Kind        Kind            `json:"kind"`
+		Request     *StoredRequest  `json:"request,omitempty"`
+		Receipt     *StoredReceipt  `json:"receipt,omitempty"`
+		Attempts    int             `json:"attempts"`
+		CreatedAt   time.Time       `json:"created_at"`
+		NextAttempt time.Time       `json:"next_attempt"`
+		LastError   string          `json:"last_error,omitempty"`
+		Metadata    json.RawMessage `json:"metadata,omitempty"`
+	}
+
+	// StoredRequest is a base.Request with its payload already marshalled and
+	// without its credentials. RequestID is sent with every attempt so that
+	// the receiver can use it as an idempotency key.
+	StoredRequest struct {
+		Name        string            `json:"name"`
+		Method      string            `json:"method"`
+		URL         string            `json:"url"`
+		Endpoint    string            `json:"endpoint,omitempty"`
+		MNO         string            `json:"mno,omitempty"`
+		Group       string            `json:"group,omitempty"`
+		RequestID   string            `json:"request_id"`
+		Headers     map[string]string `json:"headers,omitempty"`
+		QueryParams map[string]string `json:"query_params,omitempty"`
+		Body        []byte            `json:"body,omitempty"`
+	}
+
+	// StoredReceipt is a callback received with base.Receiver.Receive
+	StoredReceipt struct {
+		Name          string      `json:"name"`
+		Method        string      `json:"method"`
+		URL           string      `json:"url"`
+		Header        http.Header `json:"header"`
+		RemoteAddress string      `json:"remote_address,omitempty"`
+		Body          []byte      `json:"body,omitempty"`
+		ReceivedAt    time.Time   `json:"received_at"`
+	}
+
+	// Queue stores messages until they are acknowledged.
+	//
+	// Lease returns the next due message and hides it from other callers for the
+	// lease duration of the queue, if it is neither acknowledged nor rejected
+	// before the lease expires it is delivered again. Nack records a failed
+	// delivery, the message is retried later or moved to the dead-letter store
+	// when it has no retries left or err is Permanent, retry reports which.
+	Queue interface {
+		Enqueue(ctx context.Context, message *Message) error
+		Lease(ctx context.Context) (*Message, error)
+		Ack(ctx context.Context, id string) error
+		Nack(ctx context.Context, id string, err error) (retry bool, nackErr error)
+		DeadLetters(ctx context.Context) ([]*Message, error)
+		Requeue(ctx context.Context, id string) error
+	}
+
+	permanentError struct {
+		err error
+	}
+)
+
+// NewID returns a unique message ID, IDs created later sort after earlier ones
+func NewID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%020d-%s", time.Now().UnixNano(), hex.EncodeToString(b))
+}
+
+// NewRequestMessage stores request so that it can be sent later. The payload
+// is marshalled with the Content-Type of the request. Credentials are not
+// stored: BasicAuth and the Authorization, Proxy-Authorization, Cookie and
+// X-Api-Key headers are dropped, they are set when the message is sent by the
+// modifiers passed to SendHandler e.g. base.BasicAuthFromSecrets. A request
+// ID is generated when the request has none.
+func NewRequestMessage(request *base.Request) (*Message, error) {
+	stored := &StoredRequest{
+		Name:        request.Name,
+		Method:      request.Method,
+		URL:         request.URL,
+		Endpoint:    request.Endpoint,
+		MNO:         request.MNO,
+		Group:       request.Group,
+		RequestID:   request.RequestID,
+		Headers:     make(map[string]string, len(request.Headers)),
+		QueryParams: request.QueryParams,
+	}
+	if stored.RequestID == "" {
+		stored.RequestID = base.NewRequestID()
+	}
+	for key, value := range request.Headers {
+		if !credential(key) {
+			stored.Headers[key] = value
+		}
+	}
+
+	if request.Payload != nil {
+		buf, err := base.MarshalPayload(payloadType(request.Headers["Content-Type"]), request.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("outbox: could not marshal payload of %s: %w", request.Name, err)
+		}
+		stored.Body = buf.Bytes()
+	}
+
+	return newMessage(KindRequest, stored, nil), nil
+}
+
+// NewReceiptMessage stores a received callback, payload is the value decoded
+// by base.Receiver.Receive and it is marshalled with the Content-Type of the
+// callback request. As for requests, credential headers are not stored.
+func NewReceiptMessage(name string, receipt *base.Receipt, payload interface{}) (*Message, error) {
+	if receipt == nil || receipt.Request == nil {
+		return nil, errors.New("outbox: receipt has no request")
+	}
+	r := receipt.Request
+	stored := &StoredReceipt{
+		Name:          name,
+		Method:        r.Method,
+		URL:           r.URL.String(),
+		Header:        r.Header.Clone(),
+		RemoteAddress: receipt.RemoteAddress,
+		ReceivedAt:    time.Now(),
+	}
+	for _, key := range credentialHeaders {
+		stored.Header.Del(key)
+	}
+
+	if payload != nil {
+		buf, err := base.MarshalPayload(payloadType(r.Header.Get("Content-Type")), payload)
+		if err != nil {
+			return nil, fmt.Errorf("outbox: could not marshal payload of %s: %w", name, err)
+		}
+		stored.Body = buf.Bytes()
+	}
+
+	return newMessage(KindReceipt, nil, stored), nil
+}
+
+func newMessage(kind Kind, request *StoredRequest, receipt *StoredReceipt) *Message {
+	now := time.Now()
+	return &Message{
+		ID:          NewID(),
+		Kind:        kind,
+		Request:     request,
+		Receipt:     receipt,
+		CreatedAt:   now,
+		NextAttempt: now,
+	}
+}
+
+// Request rebuilds the base.Request and the base.RequestModifier that sets
+// its stored body, both have to be passed to base.Client.Do
+func (s *StoredRequest) Request() (*base.Request, base.RequestModifier) {
+	request := &base.Request{
+		Name:        s.Name,
+		Method:      s.Method,
+		URL:         s.URL,
+		Endpoint:    s.Endpoint,
+		MNO:         s.MNO,
+		Group:       s.Group,
+		RequestID:   s.RequestID,
+		Headers:     make(map[string]string, len(s.Headers)),
+		QueryParams: s.QueryParams,
+	}
+	for key, value := range s.Headers {
+		request.Headers[key] = value
+	}
+
+	body := s.Body
+	modifier := func(req *http.Request) error {
+		if body == nil {
+			return nil
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+		return nil
+	}
+
+	return request, modifier
+}
+
+// Decode unmarshals the stored callback body into v using its Content-Type
+func (s *StoredReceipt) Decode(v interface{}) error {
+	switch payloadType(s.Header.Get("Content-Type")) {
+	case base.JsonPayload:
+		return json.Unmarshal(s.Body, v)
+	case base.XmlPayload, base.TextXmlPayload:
+		return xml.Unmarshal(s.Body, v)
+	case base.FormPayload:
+		form, ok := v.(*url.Values)
+		if !ok {
+			return base.ErrInvalidFormPayload
+		}
+		values, err := url.ParseQuery(string(s.Body))
+		*form = values
+		return err
+	}
+	return fmt.Errorf("outbox: can not decode %s body", s.Header.Get("Content-Type"))
+}
+
+// Permanent marks err as a failure that must not be retried, a message
+// rejected with a permanent error goes straight to the dead-letter store
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err has been marked with Permanent
+func IsPermanent(err error) bool {
+	var perm *permanentError
+	return errors.As(err, &perm)
+}
+
+func (p *permanentError) Error() string {
+	return p.err.Error()
+}
+
+func (p *permanentError) Unwrap() error {
+	return p.err
+}
+
+func credential(header string) bool {
+	for _, key := range credentialHeaders {
+		if strings.EqualFold(header, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func payloadType(contentType string) base.PayloadType {
+	for _, t := range []base.PayloadType{base.JsonPayload, base.TextXmlPayload, base.XmlPayload, base.FormPayload} {
+		if strings.Contains(contentType, t.String()) {
+			return t
+		}
+	}
+	return base.JsonPayload
+}
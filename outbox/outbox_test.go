@@ -0,0 +1,288 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/techcraftlabs/base"
+	"github.com/techcraftlabs/base/metrics"
+)
+
+func TestFileQueue(t *testing.T) {
+	ctx := context.TODO()
+	queue, err := NewFileQueue(t.TempDir(), WithRetrySchedule(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message, err := NewRequestMessage(base.NewRequest("pay", http.MethodPost, "http://example.com", map[string]int{"amount": 1000}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := queue.Enqueue(ctx, message); err != nil {
+		t.Fatal(err)
+	}
+
+	leased, err := queue.Lease(ctx)
+	if err != nil || leased.ID != message.ID || string(leased.Request.Body) != `{"amount":1000}` {
+		t.Fatalf("Lease() = %+v, %v", leased, err)
+	}
+	if _, err := queue.Lease(ctx); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Lease() of a leased message error = %v, want %v", err, ErrEmpty)
+	}
+
+	// one retry then the message is a dead letter
+	if retry, err := queue.Nack(ctx, message.ID, errors.New("timeout")); err != nil || !retry {
+		t.Fatalf("Nack() = %v, %v, want a retry", retry, err)
+	}
+	if leased, err = queue.Lease(ctx); err != nil || leased.Attempts != 1 {
+		t.Fatalf("Lease() after Nack = %+v, %v", leased, err)
+	}
+	if retry, err := queue.Nack(ctx, message.ID, errors.New("timeout")); err != nil || retry {
+		t.Fatalf("Nack() = %v, %v, want a dead letter", retry, err)
+	}
+	dead, err := queue.DeadLetters(ctx)
+	if err != nil || len(dead) != 1 || dead[0].LastError != "timeout" {
+		t.Fatalf("DeadLetters() = %v, %v", dead, err)
+	}
+
+	if err := queue.Requeue(ctx, message.ID); err != nil {
+		t.Fatal(err)
+	}
+	if leased, err = queue.Lease(ctx); err != nil || leased.Attempts != 0 {
+		t.Fatalf("Lease() after Requeue = %+v, %v", leased, err)
+	}
+	if err := queue.Ack(ctx, message.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := queue.Lease(ctx); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Lease() after Ack error = %v, want %v", err, ErrEmpty)
+	}
+}
+
+func TestPool_SendHandler(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	queue, err := NewFileQueue(t.TempDir(), WithRetrySchedule(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	message, _ := NewRequestMessage(base.NewRequest("pay", http.MethodPost, server.URL, map[string]int{"amount": 1000}))
+	_ = queue.Enqueue(context.TODO(), message)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client := base.NewClient(base.WithDebugMode(false))
+	done := make(chan map[string]int, 1)
+	pool := &Pool{
+		Queue:        queue,
+		Workers:      2,
+		PollInterval: time.Millisecond,
+		Handler: SendHandler(client, func(*Message) interface{} { return new(map[string]int) },
+			func(_ *Message, response *base.Response) error {
+				if response.StatusCode == http.StatusOK {
+					done <- *response.Body.(*map[string]int)
+				}
+				return nil
+			}),
+	}
+	go pool.Run(ctx)
+
+	select {
+	case body := <-done:
+		if body["amount"] != 1000 {
+			t.Errorf("response body = %v", body)
+		}
+	case <-ctx.Done():
+		t.Fatal("message was not delivered")
+	}
+
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("server got %d calls, want 2", n)
+	}
+}
+
+func TestPool_RetryMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	// two failed attempts: the first is retried, the second is a dead letter
+	queue, err := NewFileQueue(t.TempDir(), WithRetrySchedule(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	message, _ := NewRequestMessage(base.NewRequest("pay", http.MethodPost, server.URL, map[string]int{"amount": 1000}))
+	_ = queue.Enqueue(context.TODO(), message)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	recorder := metrics.NewMemory()
+	pool := &Pool{
+		Queue:        queue,
+		PollInterval: time.Millisecond,
+		Handler:      SendHandler(base.NewClient(base.WithDebugMode(false)), nil, nil),
+		Metrics:      recorder,
+	}
+	go pool.Run(ctx)
+
+	for ctx.Err() == nil {
+		if dead, _ := queue.DeadLetters(ctx); len(dead) == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if got := recorder.Retries("pay", ""); got != 1 {
+		t.Errorf("Retries() = %d, want 1", got)
+	}
+}
+
+func TestStoredReceipt_Decode(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/callback", nil)
+	r.Header.Set("Content-Type", "application/json")
+	message, err := NewReceiptMessage("callback", &base.Receipt{Request: r}, map[string]string{"status": "SUCCESS"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, _ := json.Marshal(message)
+	restored := new(Message)
+	_ = json.Unmarshal(buf, restored)
+
+	v := make(map[string]string)
+	if err := restored.Receipt.Decode(&v); err != nil || v["status"] != "SUCCESS" {
+		t.Errorf("Decode() = %v, %v", v, err)
+	}
+}
+
+func TestFileQueue_Corrupt(t *testing.T) {
+	ctx := context.TODO()
+	dir := t.TempDir()
+	queue, err := NewFileQueue(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the corrupt message sorts first and must not stall the queue
+	if err := os.WriteFile(filepath.Join(dir, pendingDir, "0-corrupt.json"), []byte(`{"id":`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	message, _ := NewRequestMessage(base.NewRequest("pay", http.MethodPost, "http://example.com", nil))
+	if err := queue.Enqueue(ctx, message); err != nil {
+		t.Fatal(err)
+	}
+
+	if leased, err := queue.Lease(ctx); err != nil || leased.ID != message.ID {
+		t.Fatalf("Lease() = %+v, %v, want %s", leased, err, message.ID)
+	}
+	if _, err := os.Stat(filepath.Join(dir, corruptDir, "0-corrupt.json")); err != nil {
+		t.Errorf("corrupt message not quarantined: %v", err)
+	}
+
+	for name, fn := range map[string]func(id string) error{
+		"Ack":     func(id string) error { return queue.Ack(ctx, id) },
+		"Nack":    func(id string) error { _, err := queue.Nack(ctx, id, nil); return err },
+		"Requeue": func(id string) error { return queue.Requeue(ctx, id) },
+	} {
+		for _, id := range []string{"../pending/" + message.ID, "", ".hidden"} {
+			if err := fn(id); err == nil || !strings.Contains(err.Error(), "invalid message id") {
+				t.Errorf("%s(%q) error = %v, want an invalid message id", name, id, err)
+			}
+		}
+	}
+}
+
+func TestNewRequestMessage_Credentials(t *testing.T) {
+	var got []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	request := base.NewRequest("pay", http.MethodPost, server.URL, map[string]int{"amount": 1000},
+		base.WithBasicAuth("user", "s3cr3t"),
+		base.WithRequestHeaders(map[string]string{"authorization": "Bearer t0k3n", "X-Channel": "ussd"}))
+	message, err := NewRequestMessage(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, _ := json.Marshal(message)
+	for _, secret := range []string{"s3cr3t", "t0k3n"} {
+		if strings.Contains(string(buf), secret) {
+			t.Errorf("stored message contains %s: %s", secret, buf)
+		}
+	}
+	restored := new(Message)
+	_ = json.Unmarshal(buf, restored)
+
+	// every attempt has the stored request ID and the credentials of the modifier
+	handler := SendHandler(base.NewClient(base.WithDebugMode(false)), nil, nil, func(r *http.Request) error {
+		r.SetBasicAuth("user", "rotated")
+		return nil
+	})
+	for i := 0; i < 2; i++ {
+		_ = handler(context.TODO(), restored)
+	}
+	if len(got) != 2 {
+		t.Fatalf("server got %d requests, want 2", len(got))
+	}
+	for _, r := range got {
+		user, pass, _ := r.BasicAuth()
+		if id := r.Header.Get(base.DefaultRequestIDHeader); id == "" || id != message.Request.RequestID {
+			t.Errorf("request id = %q, want %q", id, message.Request.RequestID)
+		}
+		if user != "user" || pass != "rotated" || r.Header.Get("X-Channel") != "ussd" {
+			t.Errorf("request headers = %v", r.Header)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/techcraftlabs/base"
+	"github.com/techcraftlabs/base/metrics"
+)
+
+const defaultPollInterval = time.Second
+
+type (
+	// Handler processes a leased message. Returning nil acknowledges the
+	// message, any other error schedules a retry unless it is Permanent.
+	Handler func(ctx context.Context, message *Message) error
+
+	// Pool runs Workers goroutines that lease messages from Queue and pass
+	// them to Handler. Workers sleep for PollInterval when the queue is empty.
+	// OnError, when set, is called with errors returned by the Queue itself and
+	// Metrics, when set, records the retries of request messages.
+	Pool struct {
+		Queue        Queue
+		Handler      Handler
+		Workers      int
+		PollInterval time.Duration
+		OnError      func(err error)
+		Metrics      metrics.Recorder
+	}
+)
+
+// Run blocks until ctx is done and all the workers have returned
+func (p *Pool) Run(ctx context.Context) {
+	workers := p.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	interval := p.PollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.work(ctx, interval)
+		}()
+	}
+	wg.Wait()
+}
+
+func (p *Pool) work(ctx context.Context, interval time.Duration) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		message, err := p.Queue.Lease(ctx)
+		if err != nil {
+			if !errors.Is(err, ErrEmpty) && ctx.Err() == nil {
+				p.onError(err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+			continue
+		}
+
+		// the message is finished even if ctx is done while it is being
+		// handled, otherwise it would only be retried after the lease expires
+		handleErr := p.Handler(ctx, message)
+		if handleErr == nil {
+			err = p.Queue.Ack(context.Background(), message.ID)
+		} else {
+			var retry bool
+			retry, err = p.Queue.Nack(context.Background(), message.ID, handleErr)
+			// dead letters are not retried
+			if p.Metrics != nil && message.Request != nil && retry {
+				p.Metrics.RetryObserved(message.Request.Name, message.Request.MNO)
+			}
+		}
+		if err != nil {
+			p.onError(err)
+		}
+	}
+}
+
+func (p *Pool) onError(err error) {
+	if p.OnError != nil {
+		p.OnError(err)
+	}
+}
+
+// SendHandler sends messages of KindRequest with client.Do. newBody returns the
+// value the response body is decoded into, it can be nil. onResponse, when not
+// nil, is called with every response and can turn it into an error. modifiers
+// are applied to every request, they set the credentials that are not stored.
+//
+// Transport errors, 429 and 5xx responses are retried, other 4xx responses
+// are permanent failures and messages of other kinds are rejected permanently.
+func SendHandler(client *base.Client, newBody func(message *Message) interface{},
+	onResponse func(message *Message, response *base.Response) error, modifiers ...base.RequestModifier) Handler {
+	return func(ctx context.Context, message *Message) error {
+		if message.Kind != KindRequest || message.Request == nil {
+			return Permanent(fmt.Errorf("outbox: message %s is not a request", message.ID))
+		}
+
+		var body interface{}
+		if newBody != nil {
+			body = newBody(message)
+		}
+
+		request, setBody := message.Request.Request()
+		response, err := client.Do(ctx, request, body, append([]base.RequestModifier{setBody}, modifiers...)...)
+		if err != nil {
+			return err
+		}
+
+		if onResponse != nil {
+			if err := onResponse(message, response); err != nil {
+				return err
+			}
+		}
+
+		status := response.StatusCode
+		switch {
+		case status == http.StatusTooManyRequests || status >= http.StatusInternalServerError:
+			return fmt.Errorf("outbox: %s failed with status %d", request.Name, status)
+		case status >= http.StatusBadRequest:
+			return Permanent(fmt.Errorf("outbox: %s rejected with status %d", request.Name, status))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,468 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package base
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	stdio "io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageRetries    = 5
+	defaultPageRetryDelay = time.Second
+)
+
+var (
+	// ErrStopPagination can be returned by the func passed to Client.Paginate
+	// to stop without an error
+	ErrStopPagination = errors.New("stop pagination")
+
+	// ErrPageRateLimited is returned when a page is still rate limited after
+	// PaginateConfig.MaxRetries retries
+	ErrPageRateLimited = errors.New("page request is rate limited")
+
+	// ErrInvalidPaginateConfig is returned by NewPaginator when the
+	// PaginateConfig can not be used
+	ErrInvalidPaginateConfig = errors.New("invalid paginate config")
+)
+
+var (
+	_ Pager = (*OffsetPager)(nil)
+	_ Pager = (*PagePager)(nil)
+	_ Pager = (*CursorPager)(nil)
+	_ Pager = (*LinkPager)(nil)
+)
+
+type (
+	// Pager moves a Request from one page to the next. First is called once
+	// with the request of the first page, Next is called after every page with
+	// the decoded page, the response and the number of items of the page, it
+	// returns false when there are no more pages.
+	Pager interface {
+		First(request *Request)
+		Next(request *Request, page interface{}, response *Response, items int) bool
+	}
+
+	// OffsetPager pages with offset and limit query parameters ("offset" and
+	// "limit" by default). The last page is the one with less than Limit items.
+	OffsetPager struct {
+		OffsetParam string
+		LimitParam  string
+		Limit       int
+		offset      int
+	}
+
+	// PagePager pages with a page number and a page size query parameters
+	// ("page" and "size" by default). Pages start at Start, 1 by default.
+	// The last page is the one with less than Size items.
+	PagePager struct {
+		PageParam string
+		SizeParam string
+		Size      int
+		Start     int
+		page      int
+	}
+
+	// CursorPager pages with a cursor or page token returned by Cursor, it is
+	// sent in the Param query parameter ("cursor" by default). The last page
+	// is the one with an empty cursor.
+	CursorPager struct {
+		Param  string
+		Cursor func(page interface{}, response *Response) string
+	}
+
+	// LinkPager follows the rel="next" URL of the Link header (RFC 8288)
+	LinkPager struct{}
+
+	// PaginateConfig controls a Paginator. NewPage returns the value a page
+	// is decoded into and Items returns its items. When Items is nil NewPage
+	// must return a pointer to a slice, whose elements are the items.
+	PaginateConfig struct {
+		Pager    Pager
+		NewPage  func() interface{}
+		Items    func(page interface{}) []interface{}
+		MaxPages int
+
+		// MaxRetries is the number of times a rate limited (429) page is
+		// retried, after the Retry-After delay or RetryDelay doubled at every
+		// retry when there is none
+		MaxRetries int
+		RetryDelay time.Duration
+	}
+
+	// Paginator iterates over the items of all the pages of a list endpoint
+	//
+	//	paginator, err := base.NewPaginator(client, request, config)
+	//	if err != nil {
+	//	}
+	//	for paginator.Next(ctx) {
+	//		tx := paginator.Item().(*Transaction)
+	//	}
+	//	if err := paginator.Err(); err != nil {
+	//	}
+	Paginator struct {
+		client    *Client
+		request   *Request
+		config    PaginateConfig
+		modifiers []RequestModifier
+		items     []interface{}
+		item      interface{}
+		pages     int
+		last      bool
+		err       error
+	}
+)
+
+func (p *OffsetPager) First(request *Request) {
+	p.offset = 0
+	request.QueryParams[defaultString(p.OffsetParam, "offset")] = "0"
+	request.QueryParams[defaultString(p.LimitParam, "limit")] = strconv.Itoa(p.Limit)
+}
+
+func (p *OffsetPager) Next(request *Request, _ interface{}, _ *Response, items int) bool {
+	if items == 0 || items < p.Limit {
+		return false
+	}
+	p.offset += items
+	request.QueryParams[defaultString(p.OffsetParam, "offset")] = strconv.Itoa(p.offset)
+	return true
+}
+
+func (p *PagePager) First(request *Request) {
+	p.page = p.Start
+	if p.page == 0 {
+		p.page = 1
+	}
+	request.QueryParams[defaultString(p.PageParam, "page")] = strconv.Itoa(p.page)
+	request.QueryParams[defaultString(p.SizeParam, "size")] = strconv.Itoa(p.Size)
+}
+
+func (p *PagePager) Next(request *Request, _ interface{}, _ *Response, items int) bool {
+	if items == 0 || items < p.Size {
+		return false
+	}
+	p.page++
+	request.QueryParams[defaultString(p.PageParam, "page")] = strconv.Itoa(p.page)
+	return true
+}
+
+func (p *CursorPager) First(request *Request) {
+	delete(request.QueryParams, defaultString(p.Param, "cursor"))
+}
+
+func (p *CursorPager) Next(request *Request, page interface{}, response *Response, _ int) bool {
+	cursor := p.Cursor(page, response)
+	if cursor == "" {
+		return false
+	}
+	request.QueryParams[defaultString(p.Param, "cursor")] = cursor
+	return true
+}
+
+func (p *LinkPager) First(*Request) {}
+
+// Next replaces the URL of the request with the next link, the link holds
+// the query parameters so the ones of the request are removed
+func (p *LinkPager) Next(request *Request, _ interface{}, response *Response, _ int) bool {
+	if response.HTTP == nil {
+		return false
+	}
+	next, ok := ParseLinkHeader(response.HTTP.Header.Values("Link"))["next"]
+	if !ok {
+		return false
+	}
+	if response.HTTP.Request != nil {
+		if base := response.HTTP.Request.URL; base != nil {
+			if u, err := base.Parse(next); err == nil {
+				next = u.String()
+			}
+		}
+	}
+	request.URL = next
+	request.Endpoint = ""
+	request.QueryParams = map[string]string{}
+	return true
+}
+
+// ParseLinkHeader returns the URLs of the Link header values by relation type
+func ParseLinkHeader(values []string) map[string]string {
+	links := make(map[string]string)
+	for _, value := range values {
+		for _, link := range strings.Split(value, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			target = strings.Trim(target, "<>")
+			for _, param := range parts[1:] {
+				key, value, _ := cutString(strings.TrimSpace(param), "=")
+				if !strings.EqualFold(key, "rel") {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(value, `"`)) {
+					links[strings.ToLower(rel)] = target
+				}
+			}
+		}
+	}
+	return links
+}
+
+func cutString(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+func defaultString(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
+
+// NewPaginator returns a Paginator over the pages of request, request is
+// not modified. It returns ErrInvalidPaginateConfig when config has no Pager
+// or NewPage, or a CursorPager without Cursor.
+func NewPaginator(client *Client, request *Request, config PaginateConfig, modifiers ...RequestModifier) (*Paginator, error) {
+	switch pager := config.Pager.(type) {
+	case nil:
+		return nil, fmt.Errorf("%w: Pager is nil", ErrInvalidPaginateConfig)
+	case *CursorPager:
+		if pager == nil || pager.Cursor == nil {
+			return nil, fmt.Errorf("%w: CursorPager has no Cursor", ErrInvalidPaginateConfig)
+		}
+	}
+	if config.NewPage == nil {
+		return nil, fmt.Errorf("%w: NewPage is nil", ErrInvalidPaginateConfig)
+	}
+	if config.MaxRetries <= 0 {
+		config.MaxRetries = defaultPageRetries
+	}
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = defaultPageRetryDelay
+	}
+	if config.Items == nil {
+		config.Items = sliceItems
+	}
+
+	first := *request
+	first.QueryParams = make(map[string]string, len(request.QueryParams))
+	for key, value := range request.QueryParams {
+		first.QueryParams[key] = value
+	}
+	config.Pager.First(&first)
+
+	return &Paginator{
+		client:    client,
+		request:   &first,
+		config:    config,
+		modifiers: modifiers,
+	}, nil
+}
+
+// Next advances to the next item, fetching the next page when needed. It
+// returns false when there are no more items, ctx is done or an error occurred.
+func (p *Paginator) Next(ctx context.Context) bool {
+	for len(p.items) == 0 {
+		if p.err != nil || p.last {
+			return false
+		}
+		if err := ctx.Err(); err != nil {
+			p.err = err
+			return false
+		}
+		if p.config.MaxPages > 0 && p.pages >= p.config.MaxPages {
+			return false
+		}
+		p.err = p.fetch(ctx)
+	}
+
+	p.item, p.items = p.items[0], p.items[1:]
+	return true
+}
+
+// Item returns the current item
+func (p *Paginator) Item() interface{} {
+	return p.item
+}
+
+// Pages returns the number of pages fetched so far
+func (p *Paginator) Pages() int {
+	return p.pages
+}
+
+// Err returns the error that stopped the iteration, if any
+func (p *Paginator) Err() error {
+	return p.err
+}
+
+// fetch uses Client.Stream so that the body of a rate limited response, which
+// is usually not shaped like a page, is not decoded
+func (p *Paginator) fetch(ctx context.Context) error {
+	delay := p.config.RetryDelay
+	for retry := 0; ; retry++ {
+		// Client.Stream appends the endpoint to the URL of the request
+		// it is given, so every attempt uses a copy
+		req := *p.request
+		stream, err := p.client.Stream(ctx, &req, p.modifiers...)
+		if err != nil {
+			return fmt.Errorf("page %d: %w", p.pages+1, err)
+		}
+
+		if stream.StatusCode == http.StatusTooManyRequests {
+			_ = stream.Close()
+			if retry >= p.config.MaxRetries {
+				return fmt.Errorf("%w: page %d after %d retries", ErrPageRateLimited, p.pages+1, retry)
+			}
+			wait := retryAfter(stream.HTTP, delay)
+			delay *= 2
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+			continue
+		}
+
+		if stream.Error != nil {
+			_ = stream.Close()
+			return fmt.Errorf("%w: page %d: status %d", stream.Error, p.pages+1, stream.StatusCode)
+		}
+
+		page := p.config.NewPage()
+		err = decodePage(stream, page)
+		_ = stream.Close()
+		if err != nil {
+			return fmt.Errorf("page %d: %w", p.pages+1, err)
+		}
+
+		response := &Response{
+			HTTP:       stream.HTTP,
+			StatusCode: stream.StatusCode,
+			Body:       page,
+			HeaderMap:  stream.HeaderMap,
+			RequestID:  stream.RequestID,
+		}
+		p.pages++
+		p.items = p.config.Items(page)
+		p.last = !p.config.Pager.Next(p.request, page, response, len(p.items))
+		return nil
+	}
+}
+
+func decodePage(stream *StreamResponse, page interface{}) error {
+	var err error
+	switch categorizeContentType(stream.HeaderMap["content-type"]) {
+	case JsonPayload:
+		err = json.NewDecoder(stream.Body).Decode(page)
+	case XmlPayload, TextXmlPayload:
+		err = xml.NewDecoder(stream.Body).Decode(page)
+	default:
+		return errors.New("unknown content-type header")
+	}
+	if err != nil && !errors.Is(err, stdio.EOF) {
+		return fmt.Errorf("error while decoding response body: %w", err)
+	}
+	return nil
+}
+
+// retryAfter returns the delay of the Retry-After header, in seconds or as
+// an HTTP date, or def when there is none
+func retryAfter(res *http.Response, def time.Duration) time.Duration {
+	if res == nil {
+		return def
+	}
+	value := strings.TrimSpace(res.Header.Get("Retry-After"))
+	if value == "" {
+		return def
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait
+		}
+		return 0
+	}
+	return def
+}
+
+// sliceItems returns the elements of the slice page points to, pointers to
+// the elements when they are not pointers already
+func sliceItems(page interface{}) []interface{} {
+	v := reflect.ValueOf(page)
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice {
+		return nil
+	}
+
+	items := make([]interface{}, v.Len())
+	for i := range items {
+		elem := v.Index(i)
+		if elem.Kind() != reflect.Ptr && elem.CanAddr() {
+			elem = elem.Addr()
+		}
+		items[i] = elem.Interface()
+	}
+	return items
+}
+
+// Paginate calls fn with every item of every page of request, see
+// PaginateConfig. It stops at the first error returned by fn, ErrStopPagination
+// stops without an error, and when ctx is done.
+func (c *Client) Paginate(ctx context.Context, request *Request, config PaginateConfig,
+	fn func(item interface{}) error, modifiers ...RequestModifier) error {
+	paginator, err := NewPaginator(c, request, config, modifiers...)
+	if err != nil {
+		return err
+	}
+	for paginator.Next(ctx) {
+		if err := fn(paginator.Item()); err != nil {
+			if errors.Is(err, ErrStopPagination) {
+				return nil
+			}
+			return err
+		}
+	}
+	return paginator.Err()
+}
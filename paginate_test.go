@@ -0,0 +1,168 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package base
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+type txPage struct {
+	Items []statementLine `json:"items"`
+	Next  string          `json:"next,omitempty"`
+}
+
+func TestClient_Paginate(t *testing.T) {
+	const total = 25
+	var throttled int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first request of every test is rate limited once
+		if atomic.CompareAndSwapInt32(&throttled, 0, 1) {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		q := r.URL.Query()
+		start, size := 0, 10
+		switch {
+		case q.Get("offset") != "":
+			start, _ = strconv.Atoi(q.Get("offset"))
+			size, _ = strconv.Atoi(q.Get("limit"))
+		case q.Get("page") != "":
+			page, _ := strconv.Atoi(q.Get("page"))
+			size, _ = strconv.Atoi(q.Get("size"))
+			start = (page - 1) * size
+		case q.Get("cursor") != "":
+			start, _ = strconv.Atoi(q.Get("cursor"))
+		case q.Get("from") != "":
+			start, _ = strconv.Atoi(q.Get("from"))
+		}
+
+		page := txPage{Items: []statementLine{}}
+		for i := start; i < start+size && i < total; i++ {
+			page.Items = append(page.Items, statementLine{ID: i})
+		}
+		if end := start + size; end < total {
+			page.Next = strconv.Itoa(end)
+			w.Header().Set("Link", fmt.Sprintf(`</history?from=%d>; rel="next", </history>; rel="first"`, end))
+		}
+		w.Header().Set("Content-Type", cTypeJson)
+		_ = json.NewEncoder(w).Encode(page)
+	}))
+	defer server.Close()
+
+	items := func(page interface{}) []interface{} {
+		var items []interface{}
+		for i := range page.(*txPage).Items {
+			items = append(items, &page.(*txPage).Items[i])
+		}
+		return items
+	}
+	cursor := func(page interface{}, _ *Response) string { return page.(*txPage).Next }
+
+	tests := []struct {
+		name  string
+		pager Pager
+		stop  int
+		want  int
+	}{
+		{"offset", &OffsetPager{Limit: 10}, -1, total},
+		{"page", &PagePager{Size: 10}, -1, total},
+		{"cursor", &CursorPager{Cursor: cursor}, -1, total},
+		{"link", &LinkPager{}, -1, total},
+		{"stop", &LinkPager{}, 12, 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&throttled, 0)
+			client := NewClient(WithDebugMode(false))
+			request := NewRequest("history", http.MethodGet, server.URL+"/history", nil)
+			config := PaginateConfig{
+				Pager:   tt.pager,
+				NewPage: func() interface{} { return new(txPage) },
+				Items:   items,
+			}
+
+			got := 0
+			err := client.Paginate(context.Background(), request, config, func(item interface{}) error {
+				if id := item.(*statementLine).ID; id != got {
+					return fmt.Errorf("got item %d, want %d", id, got)
+				}
+				got++
+				if got == tt.stop {
+					return ErrStopPagination
+				}
+				return nil
+			})
+			if err != nil || got != tt.want {
+				t.Errorf("Paginate() = %v after %d items, want %d items", err, got, tt.want)
+			}
+			if len(request.QueryParams) != 0 {
+				t.Errorf("Paginate() modified the request: %v", request.QueryParams)
+			}
+		})
+	}
+}
+
+func TestNewPaginator_InvalidConfig(t *testing.T) {
+	newPage := func() interface{} { return new(txPage) }
+	var nilCursorPager *CursorPager
+
+	tests := []struct {
+		name   string
+		config PaginateConfig
+	}{
+		{"no pager", PaginateConfig{NewPage: newPage}},
+		{"no new page", PaginateConfig{Pager: &OffsetPager{Limit: 10}}},
+		{"cursor pager without cursor", PaginateConfig{Pager: &CursorPager{}, NewPage: newPage}},
+		{"nil cursor pager", PaginateConfig{Pager: nilCursorPager, NewPage: newPage}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := NewRequest("history", http.MethodGet, "http://localhost/history", nil)
+			paginator, err := NewPaginator(NewClient(WithDebugMode(false)), request, tt.config)
+			if paginator != nil || !errors.Is(err, ErrInvalidPaginateConfig) {
+				t.Errorf("NewPaginator() = %v, %v, want %v", paginator, err, ErrInvalidPaginateConfig)
+			}
+		})
+	}
+}
+
+func TestParseLinkHeader(t *testing.T) {
+	links := ParseLinkHeader([]string{`<https://api.example.com/tx?page=3>; rel="next last", <https://api.example.com/tx?page=1>; rel=prev`})
+	if links["next"] != "https://api.example.com/tx?page=3" || links["last"] != links["next"] ||
+		links["prev"] != "https://api.example.com/tx?page=1" {
+		t.Errorf("ParseLinkHeader() = %v", links)
+	}
+}
@@ -0,0 +1,214 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package base
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPollInterval    = 5 * time.Second
+	defaultPollMaxInterval = time.Minute
+	defaultPollMaxDuration = 5 * time.Minute
+)
+
+var (
+	// ErrPollTimeout is returned by Poller.Poll when PollConfig.MaxDuration
+	// elapses before the transaction reaches a terminal state
+	ErrPollTimeout = errors.New("polling timed out before the transaction reached a terminal state")
+
+	// ErrPollInProgress is returned by Poller.Poll when the transaction ID
+	// is already being polled
+	ErrPollInProgress = errors.New("transaction is already being polled")
+)
+
+type (
+	// PollConfig controls a Poller. The first status request is sent after
+	// Interval, then the interval is multiplied by Backoff after every attempt
+	// until it reaches MaxInterval. Terminal reports whether the status response
+	// shows that the transaction is done (success or failure), responses with
+	// Response.Error are passed to it as well so that it can treat some
+	// statuses as terminal.
+	PollConfig struct {
+		Interval    time.Duration
+		Backoff     float64
+		MaxInterval time.Duration
+		MaxDuration time.Duration
+		Terminal    func(response *Response) bool
+	}
+
+	// PollResult is the outcome of Poller.Poll. When the transaction ended because
+	// a callback arrived Callback is true and Receipt and Payload are the ones passed
+	// to Poller.Deliver, otherwise Response is the last status response.
+	PollResult struct {
+		Attempts int
+		Response *Response
+		Callback bool
+		Receipt  *Receipt
+		Payload  interface{}
+	}
+
+	// Poller sends status requests using a Client until a transaction reaches
+	// a terminal state or a callback for the transaction is delivered.
+	Poller struct {
+		mu      sync.Mutex
+		client  *Client
+		config  PollConfig
+		waiting map[string]chan *PollResult
+	}
+)
+
+func NewPoller(client *Client, config PollConfig) *Poller {
+	if config.Interval <= 0 {
+		config.Interval = defaultPollInterval
+	}
+	if config.Backoff < 1 {
+		config.Backoff = 1
+	}
+	if config.MaxInterval <= 0 {
+		config.MaxInterval = defaultPollMaxInterval
+	}
+	if config.MaxDuration <= 0 {
+		config.MaxDuration = defaultPollMaxDuration
+	}
+	if config.Terminal == nil {
+		config.Terminal = func(response *Response) bool {
+			return response.Error == nil
+		}
+	}
+
+	return &Poller{
+		mu:      sync.Mutex{},
+		client:  client,
+		config:  config,
+		waiting: make(map[string]chan *PollResult),
+	}
+}
+
+// Poll polls the status of the transaction txID by sending request until the
+// PollConfig.Terminal returns true, a callback for txID is delivered with
+// Poller.Deliver, ctx is done or PollConfig.MaxDuration elapses.
+// newBody returns the value the status response body is decoded into, it is
+// called for every attempt and can be nil when the body is not needed.
+// Errors returned by Client.Do are not terminal, the last one is returned
+// wrapped with ErrPollTimeout when the time is up.
+func (p *Poller) Poll(ctx context.Context, txID string, request *Request, newBody func() interface{},
+	modifiers ...RequestModifier) (*PollResult, error) {
+
+	callback := make(chan *PollResult, 1)
+	p.mu.Lock()
+	if _, ok := p.waiting[txID]; ok {
+		p.mu.Unlock()
+		return nil, ErrPollInProgress
+	}
+	p.waiting[txID] = callback
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		delete(p.waiting, txID)
+		p.mu.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, p.config.MaxDuration)
+	defer cancel()
+
+	var (
+		attempts int
+		lastErr  error
+		last     *Response
+		interval = p.config.Interval
+		timer    = time.NewTimer(interval)
+	)
+	defer timer.Stop()
+
+	for {
+		select {
+		case result := <-callback:
+			result.Attempts = attempts
+			return result, nil
+
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				if lastErr != nil {
+					return &PollResult{Attempts: attempts, Response: last}, fmt.Errorf("%w: %v", ErrPollTimeout, lastErr)
+				}
+				return &PollResult{Attempts: attempts, Response: last}, ErrPollTimeout
+			}
+			return &PollResult{Attempts: attempts, Response: last}, ctx.Err()
+
+		case <-timer.C:
+			attempts++
+			var body interface{}
+			if newBody != nil {
+				body = newBody()
+			}
+
+			// Client.Do appends the endpoint to the URL of the request
+			// it is given, so every attempt uses a copy
+			req := *request
+			response, err := p.client.Do(ctx, &req, body, modifiers...)
+			if err == nil {
+				last, lastErr = response, nil
+				if p.config.Terminal(response) {
+					return &PollResult{Attempts: attempts, Response: response}, nil
+				}
+			} else {
+				lastErr = err
+			}
+
+			interval = time.Duration(float64(interval) * p.config.Backoff)
+			if interval > p.config.MaxInterval {
+				interval = p.config.MaxInterval
+			}
+			timer.Reset(interval)
+		}
+	}
+}
+
+// Deliver ends the polling of txID with the callback that has been received
+// for it, typically right after Receiver.Receive in the callback handler.
+// It returns false when txID is not being polled.
+func (p *Poller) Deliver(txID string, receipt *Receipt, payload interface{}) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	callback, ok := p.waiting[txID]
+	if !ok {
+		return false
+	}
+
+	select {
+	case callback <- &PollResult{Callback: true, Receipt: receipt, Payload: payload}:
+		return true
+	default:
+		// a callback has already been delivered
+		return false
+	}
+}
@@ -0,0 +1,229 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package base
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type pollStatus struct {
+	Status string `json:"status"`
+}
+
+// statusServer answers with the given statuses in turn, an empty status is a
+// 500 response, and records when the requests arrived
+func statusServer(t *testing.T, statuses ...string) (*httptest.Server, func() []time.Time) {
+	t.Helper()
+	var (
+		mu    sync.Mutex
+		times []time.Time
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		n := len(times)
+		times = append(times, time.Now())
+		mu.Unlock()
+
+		status := statuses[len(statuses)-1]
+		if n < len(statuses) {
+			status = statuses[n]
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if status == "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"error":"try again"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"` + status + `"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]time.Time(nil), times...)
+	}
+}
+
+func terminalStatus(response *Response) bool {
+	body, ok := response.Body.(*pollStatus)
+	return response.Error == nil && ok && body.Status != "PENDING"
+}
+
+func newPollStatus() interface{} {
+	return new(pollStatus)
+}
+
+func TestPoller_Poll(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []string
+		terminal     func(response *Response) bool
+		wantAttempts int
+		wantStatus   int
+	}{
+		{"terminal", []string{"PENDING", "PENDING", "SUCCESS"}, terminalStatus, 3, http.StatusOK},
+		{"error responses are not terminal", []string{"", "", "FAILED"}, terminalStatus, 3, http.StatusOK},
+		{"default terminal", []string{"", "PENDING"}, nil, 2, http.StatusOK},
+		{"error response made terminal", []string{"PENDING", ""}, func(response *Response) bool {
+			return response.StatusCode == http.StatusInternalServerError
+		}, 2, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := statusServer(t, tt.statuses...)
+			poller := NewPoller(NewClient(WithDebugMode(false)), PollConfig{
+				Interval: time.Millisecond,
+				Terminal: tt.terminal,
+			})
+			result, err := poller.Poll(context.Background(), "tx-1",
+				NewRequest("status", http.MethodGet, server.URL, nil), newPollStatus)
+			if err != nil {
+				t.Fatalf("Poll() unexpected error: %v", err)
+			}
+			if result.Attempts != tt.wantAttempts || result.Response.StatusCode != tt.wantStatus || result.Callback {
+				t.Errorf("Poll() = %d attempts with status %d, want %d with %d",
+					result.Attempts, result.Response.StatusCode, tt.wantAttempts, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestPoller_Backoff(t *testing.T) {
+	server, times := statusServer(t, "PENDING", "PENDING", "PENDING", "PENDING", "SUCCESS")
+	started := time.Now()
+	poller := NewPoller(NewClient(WithDebugMode(false)), PollConfig{
+		Interval:    20 * time.Millisecond,
+		Backoff:     2,
+		MaxInterval: 50 * time.Millisecond,
+		Terminal:    terminalStatus,
+	})
+	if _, err := poller.Poll(context.Background(), "tx-1",
+		NewRequest("status", http.MethodGet, server.URL, nil), newPollStatus); err != nil {
+		t.Fatal(err)
+	}
+
+	// the intervals double from 20ms until they reach the 50ms maximum
+	want := []time.Duration{20, 40, 50, 50, 50}
+	got := times()
+	if len(got) != len(want) {
+		t.Fatalf("got %d attempts, want %d", len(got), len(want))
+	}
+	previous := started
+	for i, at := range got {
+		if gap := at.Sub(previous); gap < want[i]*time.Millisecond {
+			t.Errorf("attempt %d sent %v after the previous one, want at least %v", i+1, gap, want[i]*time.Millisecond)
+		}
+		previous = at
+	}
+}
+
+func TestPoller_Timeout(t *testing.T) {
+	server, _ := statusServer(t, "PENDING")
+	poller := NewPoller(NewClient(WithDebugMode(false)), PollConfig{
+		Interval:    time.Millisecond,
+		MaxDuration: 50 * time.Millisecond,
+		Terminal:    terminalStatus,
+	})
+	result, err := poller.Poll(context.Background(), "tx-1",
+		NewRequest("status", http.MethodGet, server.URL, nil), newPollStatus)
+	if !errors.Is(err, ErrPollTimeout) {
+		t.Fatalf("Poll() error = %v, want %v", err, ErrPollTimeout)
+	}
+	if result.Attempts == 0 || result.Response == nil {
+		t.Errorf("Poll() = %+v, want the last status response", result)
+	}
+}
+
+func TestPoller_Deliver(t *testing.T) {
+	server, times := statusServer(t, "PENDING")
+	poller := NewPoller(NewClient(WithDebugMode(false)), PollConfig{
+		Interval: time.Hour,
+		Terminal: terminalStatus,
+	})
+	request := NewRequest("status", http.MethodGet, server.URL, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	results := make(chan *PollResult, 2)
+	errs := make(chan error, 2)
+	for _, txID := range []string{"tx-1", "tx-2"} {
+		go func(txID string) {
+			result, err := poller.Poll(ctx, txID, request, newPollStatus)
+			results <- result
+			errs <- err
+		}(txID)
+	}
+
+	// wait for both transactions to be polled
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		poller.mu.Lock()
+		n := len(poller.waiting)
+		poller.mu.Unlock()
+		if n == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("transactions are not being polled")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := poller.Poll(ctx, "tx-1", request, newPollStatus); !errors.Is(err, ErrPollInProgress) {
+		t.Errorf("second Poll() of tx-1 error = %v, want %v", err, ErrPollInProgress)
+	}
+	if poller.Deliver("tx-3", &Receipt{}, nil) {
+		t.Errorf("Deliver() of a transaction that is not polled = true")
+	}
+
+	receipt := &Receipt{RequestID: "callback-1"}
+	if !poller.Deliver("tx-1", receipt, pollStatus{Status: "SUCCESS"}) {
+		t.Fatal("Deliver() of tx-1 = false")
+	}
+	result, err := <-results, <-errs
+	if err != nil || !result.Callback || result.Receipt != receipt || result.Payload != (pollStatus{Status: "SUCCESS"}) {
+		t.Errorf("Poll() of tx-1 = %+v, %v, want the delivered callback", result, err)
+	}
+
+	// tx-2 is still polled until ctx is cancelled
+	cancel()
+	if result, err = <-results, <-errs; !errors.Is(err, context.Canceled) || result.Callback {
+		t.Errorf("Poll() of tx-2 = %+v, %v, want %v", result, err, context.Canceled)
+	}
+	if poller.Deliver("tx-2", receipt, nil) {
+		t.Errorf("Deliver() after Poll returned = true")
+	}
+	if n := len(times()); n != 0 {
+		t.Errorf("server got %d status requests, want 0", n)
+	}
+}
@@ -0,0 +1,105 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package base
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	_ RateLimiter = (*tokenBucket)(nil)
+)
+
+type (
+	// RateLimiter blocks until the caller is allowed to send the next
+	// request or ctx is done.
+	RateLimiter interface {
+		Wait(ctx context.Context) error
+	}
+
+	tokenBucket struct {
+		mu     sync.Mutex
+		rate   float64 // tokens per second
+		burst  float64
+		tokens float64
+		last   time.Time
+	}
+)
+
+// NewRateLimiter returns a token bucket RateLimiter that allows limit requests
+// every per duration with bursts of up to burst requests. e.g. NewRateLimiter(10, time.Second, 1)
+// allows 10 requests per second evenly spaced. It returns an error when limit
+// or per is not positive.
+func NewRateLimiter(limit int, per time.Duration, burst int) (RateLimiter, error) {
+	if limit <= 0 || per <= 0 {
+		return nil, fmt.Errorf("base: rate limiter limit and per must be positive, got %d and %v", limit, per)
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		mu:     sync.Mutex{},
+		rate:   float64(limit) / per.Seconds(),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}, nil
+}
+
+func (tb *tokenBucket) Wait(ctx context.Context) error {
+	tb.mu.Lock()
+	now := time.Now()
+	tb.tokens += now.Sub(tb.last).Seconds() * tb.rate
+	if tb.tokens > tb.burst {
+		tb.tokens = tb.burst
+	}
+	tb.last = now
+
+	// reserve a token, the balance goes negative when the caller has to wait
+	tb.tokens--
+	if tb.tokens >= 0 {
+		tb.mu.Unlock()
+		return nil
+	}
+	delay := time.Duration(-tb.tokens / tb.rate * float64(time.Second))
+	tb.mu.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// give back the reserved token
+		tb.mu.Lock()
+		tb.tokens++
+		tb.mu.Unlock()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,98 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package base
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_Wait(t *testing.T) {
+	limiter, err := NewRateLimiter(10, time.Second, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// the burst is allowed right away, the next request waits for a token
+	started := time.Now()
+	for i := 0; i < 2; i++ {
+		if err := limiter.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(started); elapsed > 50*time.Millisecond {
+		t.Errorf("burst waited %v", elapsed)
+	}
+	if err := limiter.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(started); elapsed < 90*time.Millisecond {
+		t.Errorf("third request waited %v, want about 100ms", elapsed)
+	}
+}
+
+func TestRateLimiter_Cancel(t *testing.T) {
+	limiter, err := NewRateLimiter(1, time.Minute, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// the token reserved by the cancelled Wait is given back
+	if tokens := limiter.(*tokenBucket).tokens; tokens < -0.01 || tokens > 0.01 {
+		t.Errorf("tokens after a cancelled Wait = %f, want 0", tokens)
+	}
+}
+
+func TestNewRateLimiter_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit int
+		per   time.Duration
+	}{
+		{"zero limit", 0, time.Second},
+		{"negative limit", -1, time.Second},
+		{"zero per", 10, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if limiter, err := NewRateLimiter(tt.limit, tt.per, 1); limiter != nil || err == nil {
+				t.Errorf("NewRateLimiter(%d, %v, 1) = %v, %v, want an error", tt.limit, tt.per, limiter, err)
+			}
+		})
+	}
+}
@@ -30,12 +30,17 @@ import (
 	"context"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
+	"github.com/techcraftlabs/base/crypto"
+	"github.com/techcraftlabs/base/metrics"
+	"github.com/techcraftlabs/base/trace"
 	stdio "io"
 	"net/http"
 	"net/http/httputil"
 	"strings"
 	"sync"
+	"time"
 )
 
 var (
@@ -47,6 +52,12 @@ type (
 		mu        sync.Mutex
 		Logger    stdio.Writer
 		DebugMode bool
+		Metrics   metrics.Recorder
+		Tracer    trace.Tracer
+
+		RequestIDHeader string
+		ExchangeHooks   []ExchangeHook
+		Authenticator   Authenticator
 	}
 
 	Receiver interface {
@@ -65,14 +76,39 @@ type (
 		ApiKey        string
 		RemoteAddress string
 		ForwardedFor  string
+		TraceParent   string
+		TraceState    string
+
+		// RequestID is read from the request ID header, a new one is
+		// generated when the header is missing
+		RequestID string
+
+		// Claims of the bearer token, set by JWTAuthenticator
+		Claims *crypto.Claims
+
+		body []byte
 	}
 )
 
-func NewReceiver(writer stdio.Writer, debug bool) Receiver {
+func NewReceiver(writer stdio.Writer, debug bool, opts ...OptionFunc) Receiver {
+	params := &Params{
+		DebugMode: debug,
+		Logger:    writer,
+	}
+	for _, opt := range opts {
+		opt(params)
+	}
+
 	return &receiver{
 		mu:        sync.Mutex{},
-		Logger:    writer,
-		DebugMode: debug,
+		Logger:    params.Logger,
+		DebugMode: params.DebugMode,
+		Metrics:   params.Metrics,
+		Tracer:    params.Tracer,
+
+		RequestIDHeader: params.RequestIDHeader,
+		ExchangeHooks:   params.ExchangeHooks,
+		Authenticator:   params.Authenticator,
 	}
 }
 
@@ -82,22 +118,114 @@ func (rc *receiver) update(params *Params) {
 	if params != nil {
 		rc.Logger = params.Logger
 		rc.DebugMode = params.DebugMode
+		rc.Metrics = params.Metrics
+		rc.Tracer = params.Tracer
+		rc.RequestIDHeader = params.RequestIDHeader
+		rc.ExchangeHooks = params.ExchangeHooks
+		rc.Authenticator = params.Authenticator
 	}
 }
 
-func (rc *receiver) Receive(ctx context.Context, rn string, r *http.Request, v interface{}, opts ...OptionFunc) (*Receipt, error) {
-	params := &Params{
+// params returns a snapshot of the settings of the receiver, Receive only
+// reads the snapshot so that it can be called concurrently
+func (rc *receiver) params() *Params {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return &Params{
 		DebugMode: rc.DebugMode,
 		Logger:    rc.Logger,
+		Metrics:   rc.Metrics,
+		Tracer:    rc.Tracer,
+
+		RequestIDHeader: rc.RequestIDHeader,
+		ExchangeHooks:   rc.ExchangeHooks,
+		Authenticator:   rc.Authenticator,
 	}
+}
+
+func (rc *receiver) Receive(ctx context.Context, rn string, r *http.Request, v interface{}, opts ...OptionFunc) (*Receipt, error) {
+	params := rc.params()
 
 	for _, opt := range opts {
 		opt(params)
 	}
 
 	// update receiver in case the options changed
-	rc.update(params)
+	if len(opts) > 0 {
+		rc.update(params)
+	}
+
+	// the span is a child of the remote span in the traceparent header
+	// and is carried by the context of Receipt.Request
+	tracer := params.Tracer
+	if tracer == nil {
+		tracer = trace.Nop{}
+	}
+	ctx, _, _ = trace.Extract(ctx, r.Header)
+	started := time.Now()
+	ctx, span := tracer.Start(ctx, rn, trace.SpanKindServer)
+	span.SetAttribute("http.method", r.Method)
+	defer span.End()
+
+	receipt, err := rc.receive(ctx, rn, params, r, v)
+	if receipt != nil && params.Authenticator != nil {
+		// an unauthenticated request is rejected even if its body is invalid
+		if authErr := params.Authenticator.Authenticate(receipt); authErr != nil {
+			err = &AuthError{Err: authErr}
+		}
+	}
+	if err != nil {
+		span.SetError(err)
+	}
+	if len(params.ExchangeHooks) > 0 {
+		exchange := &Exchange{
+			Kind:     ExchangeReceived,
+			Name:     rn,
+			Request:  r,
+			Started:  started,
+			Duration: time.Since(started),
+			Err:      err,
+		}
+		if receipt != nil {
+			exchange.RequestID = receipt.RequestID
+			exchange.Request = receipt.Request
+			exchange.RequestBody = receipt.body
+		}
+		callHooks(params.ExchangeHooks, exchange)
+	}
+	if params.Metrics != nil {
+		params.Metrics.CallbackReceived(rn, receiveOutcome(receipt, err))
+	}
 
+	return receipt, err
+}
+
+// Context returns the context of the received request, it carries the
+// request ID and the span context extracted from the traceparent and
+// tracestate headers
+func (r *Receipt) Context() context.Context {
+	if r == nil || r.Request == nil {
+		return context.Background()
+	}
+	return r.Request.Context()
+}
+
+// receiveOutcome tells apart errors while reading the request body, which
+// return no Receipt, from authentication errors and errors while decoding it
+func receiveOutcome(receipt *Receipt, err error) string {
+	switch {
+	case err == nil:
+		return metrics.OutcomeOK
+	case receipt == nil:
+		return metrics.OutcomeReadError
+	case errors.Is(err, ErrUnauthorized):
+		return metrics.OutcomeUnauthorized
+	default:
+		return metrics.OutcomeDecodeError
+	}
+}
+
+func (rc *receiver) receive(ctx context.Context, rn string, params *Params, r *http.Request, v interface{}) (*Receipt, error) {
 	var (
 		bodyBytes []byte
 		err       error
@@ -126,6 +254,14 @@ func (rc *receiver) Receive(ctx context.Context, rn string, r *http.Request, v i
 	receipt.RemoteAddress = r.RemoteAddr
 	receipt.ForwardedFor = r.Header.Get("X-Forwarded-For")
 	receipt.ApiKey = r.Header.Get("X-Api-key")
+	receipt.TraceParent = r.Header.Get(trace.TraceParentHeader)
+	receipt.TraceState = r.Header.Get(trace.TraceStateHeader)
+
+	receipt.RequestID = r.Header.Get(requestIDHeader(params))
+	if receipt.RequestID == "" {
+		receipt.RequestID = NewRequestID()
+	}
+	ctx = ContextWithRequestID(ctx, receipt.RequestID)
 
 	rClone := r.Clone(ctx)
 	receipt.Request = rClone
@@ -141,12 +277,13 @@ func (rc *receiver) Receive(ctx context.Context, rn string, r *http.Request, v i
 
 	// restore request body
 	r.Body = stdio.NopCloser(bytes.NewBuffer(bodyBytes))
+	receipt.body = bodyBytes
 
 	defer func(debug bool) {
 		if debug {
-			rc.logRequest(rn, r)
+			rc.logRequest(params.Logger, logName(strings.ToUpper(rn), receipt.RequestID), r)
 		}
-	}(rc.DebugMode)
+	}(params.DebugMode)
 
 	if v == nil {
 		return receipt, nil
@@ -172,13 +309,14 @@ func (rc *receiver) Receive(ctx context.Context, rn string, r *http.Request, v i
 	return receipt, err
 }
 
-// logRequest is called to print the details of http.Request received
-func (rc *receiver) logRequest(name string, request *http.Request) {
+// logRequest is called to print the details of http.Request received, name
+// is not uppercased so that the request ID in it is logged as is
+func (rc *receiver) logRequest(logger stdio.Writer, name string, request *http.Request) {
 
-	rn := strings.ToUpper(fmt.Sprintf("%s request (RECEIVED)", name))
-	if request != nil && rc.DebugMode {
+	rn := fmt.Sprintf("%s REQUEST (RECEIVED)", name)
+	if request != nil && logger != nil {
 		reqDump, _ := httputil.DumpRequest(request, true)
-		_, err := fmt.Fprintf(rc.Logger, "\n\n%s : %s\n\n", rn, reqDump)
+		_, err := fmt.Fprintf(logger, "\n\n%s : %s\n\n", rn, reqDump)
 		if err != nil {
 			fmt.Printf("Error while logging %s request: %v\n",
 				strings.ToLower(name), err)
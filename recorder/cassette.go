@@ -0,0 +1,195 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package recorder
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	markInteraction = "### INTERACTION"
+	markResponse    = "### RESPONSE"
+	markEnd         = "### END"
+)
+
+// Load reads the interactions of the cassette at path
+func Load(path string) ([]*Interaction, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("recorder: %w", err)
+	}
+	defer file.Close()
+	return Read(file)
+}
+
+// Write writes interactions in the cassette format. Each request and response
+// is written in HTTP/1.1 wire format with a Content-Length that matches the
+// (possibly scrubbed) body, between marker lines:
+//
+//	### INTERACTION 1
+//	POST https://api.example.com/push HTTP/1.1
+//	Content-Type: application/json
+//	Content-Length: 13
+//
+//	{"amount":10}
+//	### RESPONSE
+//	HTTP/1.1 200 OK
+//	...
+//	### END
+func Write(w io.Writer, interactions []*Interaction) error {
+	bw := bufio.NewWriter(w)
+	for n, i := range interactions {
+		req := i.Request
+		fmt.Fprintf(bw, "%s %d\r\n%s %s HTTP/1.1\r\n", markInteraction, n+1, req.Method, req.URL)
+		writeMessage(bw, req.Header, req.Body)
+
+		res := i.Response
+		status := res.Status
+		if status == "" {
+			status = fmt.Sprintf("%d %s", res.StatusCode, http.StatusText(res.StatusCode))
+		}
+		fmt.Fprintf(bw, "\r\n%s\r\nHTTP/1.1 %s\r\n", markResponse, status)
+		writeMessage(bw, res.Header, res.Body)
+		fmt.Fprintf(bw, "\r\n%s\r\n\r\n", markEnd)
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("recorder: %w", err)
+	}
+	return nil
+}
+
+func writeMessage(w *bufio.Writer, header http.Header, body []byte) {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		switch http.CanonicalHeaderKey(key) {
+		case "Content-Length", "Transfer-Encoding":
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		for _, value := range header[key] {
+			fmt.Fprintf(w, "%s: %s\r\n", key, value)
+		}
+	}
+	fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(body))
+	_, _ = w.Write(body)
+}
+
+// Read parses interactions written by Write
+func Read(r io.Reader) ([]*Interaction, error) {
+	var (
+		br           = bufio.NewReader(r)
+		interactions []*Interaction
+	)
+
+	for {
+		line, err := nextLine(br)
+		if errors.Is(err, io.EOF) {
+			return interactions, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, markInteraction) {
+			return nil, fmt.Errorf("recorder: expected %q got %q", markInteraction, line)
+		}
+		n := len(interactions) + 1
+
+		req, err := http.ReadRequest(br)
+		if err != nil {
+			return nil, fmt.Errorf("recorder: interaction %d: invalid request: %w", n, err)
+		}
+		reqBody, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, fmt.Errorf("recorder: interaction %d: %w", n, err)
+		}
+
+		if line, err = nextLine(br); err != nil || line != markResponse {
+			return nil, fmt.Errorf("recorder: interaction %d: expected %q", n, markResponse)
+		}
+
+		res, err := http.ReadResponse(br, req)
+		if err != nil {
+			return nil, fmt.Errorf("recorder: interaction %d: invalid response: %w", n, err)
+		}
+		resBody, err := io.ReadAll(res.Body)
+		if err != nil {
+			return nil, fmt.Errorf("recorder: interaction %d: %w", n, err)
+		}
+
+		if line, err = nextLine(br); err != nil || line != markEnd {
+			return nil, fmt.Errorf("recorder: interaction %d: expected %q", n, markEnd)
+		}
+
+		req.Header.Del("Content-Length")
+		res.Header.Del("Content-Length")
+		interactions = append(interactions, &Interaction{
+			Request: RecordedRequest{
+				Method: req.Method,
+				URL:    req.RequestURI,
+				Header: req.Header,
+				Body:   emptyToNil(reqBody),
+			},
+			Response: RecordedResponse{
+				StatusCode: res.StatusCode,
+				Status:     res.Status,
+				Header:     res.Header,
+				Body:       emptyToNil(resBody),
+			},
+		})
+	}
+}
+
+// nextLine returns the next non blank line
+func nextLine(br *bufio.Reader) (string, error) {
+	for {
+		line, err := br.ReadString('\n')
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" {
+			return trimmed, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+func emptyToNil(b []byte) []byte {
+	if len(b) == 0 {
+		return nil
+	}
+	return b
+}
@@ -0,0 +1,192 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package recorder
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces the secrets removed by the scrubbers
+const Redacted = "[REDACTED]"
+
+var (
+	// DefaultMatcher matches on the method and the URL
+	DefaultMatcher = All(MatchMethod(), MatchURL())
+)
+
+type (
+	// Matcher reports whether the recorded interaction i is the one of req.
+	// body is the request body, req.Body can not be read by matchers
+	Matcher func(req *http.Request, body []byte, i *Interaction) bool
+
+	// Scrubber removes secrets from an interaction before it is saved
+	Scrubber func(i *Interaction)
+)
+
+// All matches when every matcher matches
+func All(matchers ...Matcher) Matcher {
+	return func(req *http.Request, body []byte, i *Interaction) bool {
+		for _, match := range matchers {
+			if !match(req, body, i) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func MatchMethod() Matcher {
+	return func(req *http.Request, _ []byte, i *Interaction) bool {
+		return req.Method == i.Request.Method
+	}
+}
+
+// MatchURL compares the whole URL including the query string
+func MatchURL() Matcher {
+	return func(req *http.Request, _ []byte, i *Interaction) bool {
+		return req.URL.String() == i.Request.URL
+	}
+}
+
+// MatchPath compares the URL path only, ignoring the host and the query string
+func MatchPath() Matcher {
+	return func(req *http.Request, _ []byte, i *Interaction) bool {
+		recorded := i.Request.URL
+		if at := strings.Index(recorded, "://"); at >= 0 {
+			recorded = recorded[at+3:]
+			if slash := strings.Index(recorded, "/"); slash >= 0 {
+				recorded = recorded[slash:]
+			} else {
+				recorded = "/"
+			}
+		}
+		if q := strings.Index(recorded, "?"); q >= 0 {
+			recorded = recorded[:q]
+		}
+		return req.URL.Path == recorded
+	}
+}
+
+// MatchHeaders compares the values of the given headers. Do not include
+// headers that are scrubbed, their recorded value is Redacted
+func MatchHeaders(names ...string) Matcher {
+	return func(req *http.Request, _ []byte, i *Interaction) bool {
+		for _, name := range names {
+			if req.Header.Get(name) != i.Request.Header.Get(name) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// MatchBody compares the request bodies, JSON bodies are compared by
+// value so formatting and key order do not matter
+func MatchBody() Matcher {
+	return func(_ *http.Request, body []byte, i *Interaction) bool {
+		if bytes.Equal(body, i.Request.Body) {
+			return true
+		}
+		var a, b interface{}
+		if json.Unmarshal(body, &a) != nil || json.Unmarshal(i.Request.Body, &b) != nil {
+			return false
+		}
+		x, _ := json.Marshal(a)
+		y, _ := json.Marshal(b)
+		return bytes.Equal(x, y)
+	}
+}
+
+// ScrubHeaders replaces the values of the given request and response headers with Redacted
+func ScrubHeaders(names ...string) Scrubber {
+	return func(i *Interaction) {
+		for _, name := range names {
+			for _, header := range []http.Header{i.Request.Header, i.Response.Header} {
+				if header.Get(name) != "" {
+					header.Set(name, Redacted)
+				}
+			}
+		}
+	}
+}
+
+// ScrubJSONFields replaces the values of the given fields with Redacted in
+// JSON request and response bodies, at any depth
+func ScrubJSONFields(fields ...string) Scrubber {
+	set := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		set[field] = true
+	}
+
+	scrub := func(body []byte) []byte {
+		var v interface{}
+		if json.Unmarshal(body, &v) != nil {
+			return body
+		}
+		buf, err := json.Marshal(redact(v, set))
+		if err != nil {
+			return body
+		}
+		return buf
+	}
+
+	return func(i *Interaction) {
+		i.Request.Body = scrub(i.Request.Body)
+		i.Response.Body = scrub(i.Response.Body)
+	}
+}
+
+func redact(v interface{}, fields map[string]bool) interface{} {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		for key, value := range node {
+			if fields[key] {
+				node[key] = Redacted
+				continue
+			}
+			node[key] = redact(value, fields)
+		}
+	case []interface{}:
+		for n, value := range node {
+			node[n] = redact(value, fields)
+		}
+	}
+	return v
+}
+
+// ScrubRegexp replaces matches of re in the request URL and in the request
+// and response bodies with repl, e.g. to scrub XML elements or query params
+func ScrubRegexp(re *regexp.Regexp, repl string) Scrubber {
+	return func(i *Interaction) {
+		i.Request.URL = re.ReplaceAllString(i.Request.URL, repl)
+		i.Request.Body = re.ReplaceAll(i.Request.Body, []byte(repl))
+		i.Response.Body = re.ReplaceAll(i.Response.Body, []byte(repl))
+	}
+}
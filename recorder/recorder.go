@@ -0,0 +1,323 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+// Package recorder provides an http.RoundTripper that records the exchanges
+// made through base.Client.Do into cassette files and replays them offline,
+// so integration tests do not depend on MNO sandboxes.
+//
+//	rec, err := recorder.New("testdata/push.cassette", recorder.ModeReplayOrRecord,
+//		recorder.WithScrubbers(recorder.ScrubHeaders("Authorization")))
+//	defer rec.Stop()
+//	client := base.NewClient(base.WithTransport(rec))
+//
+// A cassette stores every exchange in HTTP/1.1 wire format, the same format
+// the DebugMode dumps of base.Client are written in, so it can be read and
+// edited by hand.
+package recorder
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	// ModeReplay serves every request from the cassette and never touches the network
+	ModeReplay Mode = iota
+	// ModeRecord sends every request and overwrites the cassette with the exchanges
+	ModeRecord
+	// ModeReplayOrRecord replays the exchanges found in the cassette and records the rest
+	ModeReplayOrRecord
+	// ModePassthrough sends every request and records nothing
+	ModePassthrough
+)
+
+// ErrNoInteraction is returned in ModeReplay when no recorded
+// interaction matches the request
+var ErrNoInteraction = errors.New("recorder: no recorded interaction matches the request")
+
+var (
+	_ http.RoundTripper = (*Recorder)(nil)
+)
+
+type (
+	Mode int
+
+	// Interaction is a recorded request and its response
+	Interaction struct {
+		Request  RecordedRequest
+		Response RecordedResponse
+	}
+
+	RecordedRequest struct {
+		Method string
+		URL    string
+		Header http.Header
+		Body   []byte
+	}
+
+	RecordedResponse struct {
+		StatusCode int
+		Status     string
+		Header     http.Header
+		Body       []byte
+	}
+
+	// Recorder is an http.RoundTripper that records and replays interactions
+	Recorder struct {
+		mu           sync.Mutex
+		path         string
+		mode         Mode
+		transport    http.RoundTripper
+		matcher      Matcher
+		scrubbers    []Scrubber
+		interactions []*Interaction
+		used         []bool
+		dirty        bool
+	}
+
+	Option func(recorder *Recorder)
+)
+
+func (m Mode) String() string {
+	names := [...]string{"replay", "record", "replay-or-record", "passthrough"}
+	if m < 0 || int(m) >= len(names) {
+		return fmt.Sprintf("Mode(%d)", int(m))
+	}
+	return names[m]
+}
+
+// WithRealTransport sets the http.RoundTripper used to send requests that
+// are not replayed, default is http.DefaultTransport
+func WithRealTransport(transport http.RoundTripper) Option {
+	return func(recorder *Recorder) {
+		if transport != nil {
+			recorder.transport = transport
+		}
+	}
+}
+
+// WithMatcher sets the Matcher used to find the recorded interaction of a
+// request, default is DefaultMatcher
+func WithMatcher(matcher Matcher) Option {
+	return func(recorder *Recorder) {
+		if matcher != nil {
+			recorder.matcher = matcher
+		}
+	}
+}
+
+// WithScrubbers adds scrubbers that remove secrets from interactions before
+// they are written to the cassette
+func WithScrubbers(scrubbers ...Scrubber) Option {
+	return func(recorder *Recorder) {
+		recorder.scrubbers = append(recorder.scrubbers, scrubbers...)
+	}
+}
+
+// New creates a Recorder for the cassette at path. In ModeReplay the cassette
+// must exist, in ModeReplayOrRecord it is loaded when it exists.
+func New(path string, mode Mode, opts ...Option) (*Recorder, error) {
+	r := &Recorder{
+		mu:        sync.Mutex{},
+		path:      path,
+		mode:      mode,
+		transport: http.DefaultTransport,
+		matcher:   DefaultMatcher,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if mode == ModeReplay || mode == ModeReplayOrRecord {
+		interactions, err := Load(path)
+		if err != nil && !(mode == ModeReplayOrRecord && errors.Is(err, os.ErrNotExist)) {
+			return nil, err
+		}
+		r.interactions = interactions
+		r.used = make([]bool, len(interactions))
+	}
+	// ModeRecord replaces the cassette even when nothing is recorded
+	r.dirty = mode == ModeRecord
+
+	return r, nil
+}
+
+// Interactions returns the interactions replayed or recorded so far
+func (r *Recorder) Interactions() []*Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*Interaction(nil), r.interactions...)
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.mode == ModeReplay || r.mode == ModeReplayOrRecord {
+		if i := r.find(req, body); i != nil {
+			return i.Response.toHTTP(req), nil
+		}
+		if r.mode == ModeReplay {
+			return nil, fmt.Errorf("%w: %s %s", ErrNoInteraction, req.Method, req.URL)
+		}
+	}
+
+	res, err := r.transport.RoundTrip(req)
+	if err != nil || r.mode == ModePassthrough {
+		return res, err
+	}
+
+	resBody, err := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(resBody))
+
+	interaction := &Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: req.Header.Clone(),
+			Body:   body,
+		},
+		Response: RecordedResponse{
+			StatusCode: res.StatusCode,
+			Status:     res.Status,
+			Header:     res.Header.Clone(),
+			Body:       resBody,
+		},
+	}
+	for _, scrub := range r.scrubbers {
+		scrub(interaction)
+	}
+
+	r.mu.Lock()
+	r.interactions = append(r.interactions, interaction)
+	r.used = append(r.used, true)
+	r.dirty = true
+	r.mu.Unlock()
+
+	return res, nil
+}
+
+// find returns the first unused interaction that matches the request, when all
+// the matching interactions have been used the last one of them is reused.
+func (r *Recorder) find(req *http.Request, body []byte) *Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var last *Interaction
+	for i, interaction := range r.interactions {
+		if !r.matcher(req, body, interaction) {
+			continue
+		}
+		if !r.used[i] {
+			r.used[i] = true
+			return interaction
+		}
+		last = interaction
+	}
+	return last
+}
+
+// Stop writes the cassette if new interactions have been recorded, in
+// ModeRecord it is always written. The cassette is replaced atomically.
+func (r *Recorder) Stop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.dirty {
+		return nil
+	}
+
+	dir := filepath.Dir(r.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("recorder: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("recorder: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	// cassettes are checked in with the tests, keep the permissions os.Create gives
+	if err := tmp.Chmod(0o644); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("recorder: %w", err)
+	}
+	if err := Write(tmp, r.interactions); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("recorder: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		return fmt.Errorf("recorder: %w", err)
+	}
+	r.dirty = false
+	return nil
+}
+
+func (rr RecordedResponse) toHTTP(req *http.Request) *http.Response {
+	status := rr.Status
+	if status == "" {
+		status = fmt.Sprintf("%d %s", rr.StatusCode, http.StatusText(rr.StatusCode))
+	}
+	return &http.Response{
+		Status:        status,
+		StatusCode:    rr.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        rr.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(rr.Body)),
+		ContentLength: int64(len(rr.Body)),
+		Request:       req,
+	}
+}
+
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
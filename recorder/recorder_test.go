@@ -0,0 +1,322 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package recorder
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/techcraftlabs/base"
+)
+
+type balance struct {
+	Account string `json:"account"`
+	Amount  int    `json:"amount"`
+	Token   string `json:"token,omitempty"`
+}
+
+func TestRecorder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"account":"` + r.URL.Query().Get("account") + `","amount":1000,"token":"t0k3n"}`))
+	}))
+	cassette := filepath.Join(t.TempDir(), "balance.cassette")
+
+	send := func(rec *Recorder, account string) (*balance, error) {
+		client := base.NewClient(base.WithDebugMode(false), base.WithTransport(rec))
+		request := base.NewRequest("balance", http.MethodGet, server.URL+"/balance",
+			nil, base.WithQueryParams(map[string]string{"account": account}))
+		request.AddHeader("Authorization", "Bearer secret")
+		b := new(balance)
+		_, err := client.Do(context.TODO(), request, b)
+		return b, err
+	}
+
+	rec, err := New(cassette, ModeRecord, WithScrubbers(ScrubHeaders("Authorization"), ScrubJSONFields("token")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := send(rec, "A1"); err != nil || b.Token != "t0k3n" {
+		t.Fatalf("recording: %+v, %v", b, err)
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	buf, _ := os.ReadFile(cassette)
+	if strings.Contains(string(buf), "secret") || strings.Contains(string(buf), "t0k3n") {
+		t.Errorf("cassette contains secrets:\n%s", buf)
+	}
+
+	rec, err = New(cassette, ModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := send(rec, "A1")
+	if err != nil || b.Account != "A1" || b.Amount != 1000 || b.Token != Redacted {
+		t.Errorf("replay: %+v, %v", b, err)
+	}
+
+	if _, err := send(rec, "B2"); !errors.Is(err, ErrNoInteraction) {
+		t.Errorf("replay of unknown request error = %v, want %v", err, ErrNoInteraction)
+	}
+}
+
+func TestRecorder_StopReplacesStaleCassette(t *testing.T) {
+	cassette := filepath.Join(t.TempDir(), "stale.cassette")
+	if err := os.WriteFile(cassette, []byte("stale"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := New(cassette, ModeRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	interactions, err := Load(cassette)
+	if err != nil || len(interactions) != 0 {
+		t.Errorf("Load() = %d interactions, %v, want an empty cassette", len(interactions), err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(cassette)); len(entries) != 1 {
+		t.Errorf("got %d files next to the cassette, want the cassette only", len(entries))
+	}
+}
+
+func TestMatchers(t *testing.T) {
+	recorded := &Interaction{Request: RecordedRequest{
+		Method: http.MethodPost,
+		URL:    "https://api.example.com/v1/push?ref=1",
+		Header: http.Header{"X-Channel": {"ussd"}},
+		Body:   []byte(`{"amount":10,"msisdn":"255754000000"}`),
+	}}
+
+	tests := []struct {
+		name    string
+		matcher Matcher
+		url     string
+		header  http.Header
+		body    string
+		want    bool
+	}{
+		{"body equal", MatchBody(), "/", nil, `{"amount":10,"msisdn":"255754000000"}`, true},
+		{"body json key order", MatchBody(), "/", nil, `{ "msisdn": "255754000000", "amount": 10 }`, true},
+		{"body json value differs", MatchBody(), "/", nil, `{"amount":11,"msisdn":"255754000000"}`, false},
+		{"body not json", MatchBody(), "/", nil, `amount=10`, false},
+		{"headers equal", MatchHeaders("X-Channel"), "/", http.Header{"X-Channel": {"ussd"}}, "", true},
+		{"headers differ", MatchHeaders("X-Channel"), "/", http.Header{"X-Channel": {"app"}}, "", false},
+		{"headers missing", MatchHeaders("X-Channel"), "/", nil, "", false},
+		{"path ignores host and query", MatchPath(), "http://127.0.0.1:8080/v1/push?ref=2", nil, "", true},
+		{"path differs", MatchPath(), "https://api.example.com/v1/pull?ref=1", nil, "", false},
+		{"all", All(MatchMethod(), MatchPath(), MatchBody()), "http://localhost/v1/push", nil,
+			`{"msisdn":"255754000000","amount":10}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.url, strings.NewReader(tt.body))
+			for key, values := range tt.header {
+				req.Header[key] = values
+			}
+			if got := tt.matcher(req, []byte(tt.body), recorded); got != tt.want {
+				t.Errorf("match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScrubRegexp(t *testing.T) {
+	tests := []struct {
+		name     string
+		re       *regexp.Regexp
+		repl     string
+		in, want Interaction
+	}{
+		{
+			name: "query param",
+			re:   regexp.MustCompile(`pin=[0-9]+`),
+			repl: "pin=" + Redacted,
+			in:   Interaction{Request: RecordedRequest{URL: "https://api.example.com/pay?pin=1234&id=9"}},
+			want: Interaction{Request: RecordedRequest{URL: "https://api.example.com/pay?pin=[REDACTED]&id=9"}},
+		},
+		{
+			name: "xml element",
+			re:   regexp.MustCompile(`<password>[^<]*</password>`),
+			repl: "<password>" + Redacted + "</password>",
+			in: Interaction{
+				Request:  RecordedRequest{Body: []byte("<login><password>s3cret</password></login>")},
+				Response: RecordedResponse{Body: []byte("<echo><password>s3cret</password></echo>")},
+			},
+			want: Interaction{
+				Request:  RecordedRequest{Body: []byte("<login><password>[REDACTED]</password></login>")},
+				Response: RecordedResponse{Body: []byte("<echo><password>[REDACTED]</password></echo>")},
+			},
+		},
+		{
+			name: "no match",
+			re:   regexp.MustCompile(`token=\w+`),
+			repl: Redacted,
+			in:   Interaction{Request: RecordedRequest{URL: "/balance", Body: []byte("{}")}},
+			want: Interaction{Request: RecordedRequest{URL: "/balance", Body: []byte("{}")}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.in
+			ScrubRegexp(tt.re, tt.repl)(&got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecorder_Modes(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		_, _ = io.WriteString(w, "live "+r.URL.Path)
+	}))
+	defer server.Close()
+
+	recorded := []*Interaction{{
+		Request:  RecordedRequest{Method: http.MethodGet, URL: server.URL + "/recorded"},
+		Response: RecordedResponse{StatusCode: http.StatusOK, Body: []byte("replayed")},
+	}}
+
+	tests := []struct {
+		name      string
+		mode      Mode
+		paths     []string
+		wantBody  []string
+		wantHits  int32
+		wantSaved int
+	}{
+		{"replay or record", ModeReplayOrRecord, []string{"/recorded", "/new"},
+			[]string{"replayed", "live /new"}, 1, 2},
+		{"passthrough", ModePassthrough, []string{"/recorded", "/new"},
+			[]string{"live /recorded", "live /new"}, 2, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&hits, 0)
+			cassette := filepath.Join(t.TempDir(), "modes.cassette")
+			var buf bytes.Buffer
+			if err := Write(&buf, recorded); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(cassette, buf.Bytes(), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			rec, err := New(cassette, tt.mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			client := &http.Client{Transport: rec}
+			for n, path := range tt.paths {
+				res, err := client.Get(server.URL + path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body, _ := io.ReadAll(res.Body)
+				_ = res.Body.Close()
+				if string(body) != tt.wantBody[n] {
+					t.Errorf("GET %s = %q, want %q", path, body, tt.wantBody[n])
+				}
+			}
+			if err := rec.Stop(); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := atomic.LoadInt32(&hits); got != tt.wantHits {
+				t.Errorf("server hits = %d, want %d", got, tt.wantHits)
+			}
+			saved, err := Load(cassette)
+			if err != nil || len(saved) != tt.wantSaved {
+				t.Errorf("cassette has %d interactions, %v, want %d", len(saved), err, tt.wantSaved)
+			}
+		})
+	}
+}
+
+func TestCassette_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name         string
+		interactions []*Interaction
+	}{
+		{"empty", nil},
+		{"no bodies", []*Interaction{{
+			Request:  RecordedRequest{Method: http.MethodGet, URL: "https://api.example.com/status", Header: http.Header{}},
+			Response: RecordedResponse{StatusCode: http.StatusNoContent, Status: "204 No Content", Header: http.Header{}},
+		}}},
+		{"bodies with blank lines and markers", []*Interaction{
+			{
+				Request: RecordedRequest{Method: http.MethodPost, URL: "https://api.example.com/push?ref=1",
+					Header: http.Header{"Content-Type": {"text/plain"}},
+					Body:   []byte("first\r\n\r\n\r\n### END\r\n\nlast\n\n")},
+				Response: RecordedResponse{StatusCode: http.StatusOK, Status: "200 OK",
+					Header: http.Header{"Content-Type": {"text/plain"}, "X-Trace": {"a", "b"}},
+					Body:   []byte("\r\n\r\n### RESPONSE\r\n\r\n")},
+			},
+			{
+				Request: RecordedRequest{Method: http.MethodPost, URL: "https://api.example.com/push?ref=2",
+					Header: http.Header{"Content-Type": {"application/json"}},
+					Body:   []byte("{\n\n  \"amount\": 10\n}\n")},
+				Response: RecordedResponse{StatusCode: http.StatusAccepted, Status: "202 Accepted",
+					Header: http.Header{}, Body: []byte("\n")},
+			},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, tt.interactions); err != nil {
+				t.Fatal(err)
+			}
+			got, err := Read(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.interactions) {
+				t.Errorf("round trip mismatch\ngot:  %+v\nwant: %+v", got, tt.interactions)
+			}
+		})
+	}
+}
@@ -28,9 +28,11 @@ package base
 import (
 	"encoding/json"
 	"encoding/xml"
+	"github.com/techcraftlabs/base/metrics"
 	"io"
 	"net/http"
 	"sync"
+	"time"
 )
 
 var (
@@ -42,6 +44,10 @@ type (
 		mu        sync.Mutex
 		Logger    io.Writer
 		DebugMode bool
+		Metrics   metrics.Recorder
+
+		RequestIDHeader string
+		ExchangeHooks   []ExchangeHook
 	}
 	Replier interface {
 		Reply(writer http.ResponseWriter, r *Response, opts ...OptionFunc)
@@ -54,38 +60,89 @@ func (rp *replier) update(params *Params) {
 	if params != nil {
 		rp.DebugMode = params.DebugMode
 		rp.Logger = params.Logger
+		rp.Metrics = params.Metrics
+		rp.RequestIDHeader = params.RequestIDHeader
+		rp.ExchangeHooks = params.ExchangeHooks
 	}
 }
 
-func (rp *replier) Reply(writer http.ResponseWriter, response *Response, opts ...OptionFunc) {
-	params := &Params{
+// params returns a snapshot of the settings of the replier, Reply only reads
+// the snapshot so that it can be called concurrently
+func (rp *replier) params() *Params {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	return &Params{
 		DebugMode: rp.DebugMode,
 		Logger:    rp.Logger,
+		Metrics:   rp.Metrics,
+
+		RequestIDHeader: rp.RequestIDHeader,
+		ExchangeHooks:   rp.ExchangeHooks,
 	}
+}
+
+func (rp *replier) Reply(writer http.ResponseWriter, response *Response, opts ...OptionFunc) {
+	params := rp.params()
 	for _, opt := range opts {
 		opt(params)
 	}
 
-	rp.update(params)
+	if len(opts) > 0 {
+		rp.update(params)
+	}
 
 	responseFmt, err := responseFormat(response)
 	if err != nil {
 		return
 	}
 	defer func(debug bool) {
-		if debug {
-			_, _ = rp.Logger.Write([]byte(responseFmt))
+		if debug && params.Logger != nil {
+			_, _ = params.Logger.Write([]byte(responseFmt))
 		}
-	}(rp.DebugMode)
+	}(params.DebugMode)
 
-	reply(writer, response)
+	// echo the request ID of the received request
+	if response.RequestID != "" {
+		writer.Header().Set(requestIDHeader(params), response.RequestID)
+	}
+	if len(params.ExchangeHooks) == 0 {
+		reply(writer, response)
+	} else {
+		started := time.Now()
+		recorder := &replyRecorder{ResponseWriter: writer}
+		reply(recorder, response)
+		callHooks(params.ExchangeHooks, &Exchange{
+			Kind:         ExchangeReplied,
+			RequestID:    response.RequestID,
+			Response:     recorder.response(),
+			ResponseBody: recorder.body.Bytes(),
+			Started:      started,
+			Duration:     time.Since(started),
+			Err:          response.Error,
+		})
+	}
+	if params.Metrics != nil {
+		params.Metrics.ReplySent(response.StatusCode)
+	}
 }
 
-func NewReplier(writer io.Writer, debug bool) Replier {
+func NewReplier(writer io.Writer, debug bool, opts ...OptionFunc) Replier {
+	params := &Params{
+		DebugMode: debug,
+		Logger:    writer,
+	}
+	for _, opt := range opts {
+		opt(params)
+	}
+
 	return &replier{
 		mu:        sync.Mutex{},
-		Logger:    writer,
-		DebugMode: debug,
+		Logger:    params.Logger,
+		DebugMode: params.DebugMode,
+		Metrics:   params.Metrics,
+
+		RequestIDHeader: params.RequestIDHeader,
+		ExchangeHooks:   params.ExchangeHooks,
 	}
 }
 
@@ -37,11 +37,16 @@ type (
 	// Request encapsulate details of a request to be sent to server
 	// Endpoint is dynamic that is appended to URL
 	// e.g if the url is www.server.com/users/user-id, user-id is the endpoint
+	// MNO and Group are the RequestInformer.RecipientMNO and RequestInformer.RequestGroup
+	// of requests made by MakeInternalRequest, they are used by Router to pick a Client
 	Request struct {
 		Name        string
 		Method      string
 		URL         string
 		Endpoint    string
+		MNO         string
+		Group       string
+		RequestID   string
 		BasicAuth   *BasicAuth
 		Payload     interface{}
 		Headers     map[string]string
@@ -53,6 +58,7 @@ type (
 		method      string
 		url         string
 		endpoint    string
+		requestID   string
 		basicAuth   *BasicAuth
 		payload     interface{}
 		headers     map[string]string
@@ -117,6 +123,7 @@ func (r *RequestBuilder) Build() *Request {
 		Method:      r.method,
 		URL:         r.url,
 		Endpoint:    r.endpoint,
+		RequestID:   r.requestID,
 		BasicAuth:   r.basicAuth,
 		Payload:     r.payload,
 		Headers:     r.headers,
@@ -150,7 +157,10 @@ func MakeInternalRequest(informer RequestInformer, payload interface{}, opts ...
 	method := informer.RequestMethod()
 	name := informer.String()
 	url := appendEndpoint(baseURL, endpoint)
-	return NewRequest(name, method, url, payload, opts...)
+	request := NewRequest(name, method, url, payload, opts...)
+	request.MNO = informer.RecipientMNO()
+	request.Group = informer.RequestGroup()
+	return request
 }
 
 func NewRequest(name, method, url string, payload interface{}, opts ...RequestOption) *Request {
@@ -213,6 +223,14 @@ func WithBasicAuth(username, password string) RequestOption {
 	}
 }
 
+// WithRequestID sets the request ID sent by Client.Do instead of the one
+// carried by the context or a generated one
+func WithRequestID(id string) RequestOption {
+	return func(request *RequestBuilder) {
+		request.requestID = id
+	}
+}
+
 func (request *Request) AddHeader(key, value string) {
 	request.Headers[key] = value
 }
@@ -0,0 +1,143 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package base
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestID_Propagation(t *testing.T) {
+	var received *Receipt
+	receiver := NewReceiver(io.Discard, false, RequestIDHeaderOption("X-Correlation-ID"))
+	replier := NewReplier(io.Discard, false, RequestIDHeaderOption("X-Correlation-ID"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receipt, err := receiver.Receive(r.Context(), "callback", r, nil)
+		if err != nil {
+			t.Errorf("Receive() unexpected error: %v", err)
+		}
+		received = receipt
+		replier.Reply(w, NewResponse(http.StatusOK, nil, WithResponseRequestID(receipt.RequestID)))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		opts    []RequestOption
+		want    string
+		wantNew bool
+	}{
+		{"from context", ContextWithRequestID(context.Background(), "ctx-id"), nil, "ctx-id", false},
+		{"from request", ContextWithRequestID(context.Background(), "ctx-id"), []RequestOption{WithRequestID("req-id")}, "req-id", false},
+		{"generated", context.Background(), nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(WithDebugMode(false), WithRequestIDHeader("X-Correlation-ID"))
+			response, err := client.Do(tt.ctx, NewRequest("callback", http.MethodPost, server.URL, nil, tt.opts...), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			id := response.RequestID
+			if tt.wantNew && len(id) != 36 || !tt.wantNew && id != tt.want {
+				t.Errorf("Response.RequestID = %q, want %q", id, tt.want)
+			}
+			if received.RequestID != id || RequestIDFromContext(received.Context()) != id {
+				t.Errorf("Receipt.RequestID = %q, want %q", received.RequestID, id)
+			}
+			if echoed := response.HTTP.Header.Get("X-Correlation-ID"); echoed != id {
+				t.Errorf("echoed request id = %q, want %q", echoed, id)
+			}
+		})
+	}
+}
+
+func TestClient_ZeroValue(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get(DefaultRequestIDHeader))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &Client{Http: http.DefaultClient}
+	ctx := ContextWithRequestID(context.Background(), "zero-id")
+	if _, err := client.Do(ctx, NewRequest("zero", http.MethodGet, server.URL, nil), nil); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	response, err := client.Stream(ctx, NewRequest("zero", http.MethodGet, server.URL, nil))
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+	_ = response.Close()
+
+	if len(got) != 2 || got[0] != "zero-id" || got[1] != "zero-id" {
+		t.Errorf("request ids = %v, want zero-id twice", got)
+	}
+}
+
+func TestRequestID_Logs(t *testing.T) {
+	var clientLog, receiveLog, replyLog bytes.Buffer
+	receiver := NewReceiver(&receiveLog, true)
+	replier := NewReplier(&replyLog, true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receipt, err := receiver.Receive(r.Context(), "callback", r, nil)
+		if err != nil {
+			t.Errorf("Receive() unexpected error: %v", err)
+		}
+		replier.Reply(w, NewResponse(http.StatusOK, map[string]string{"status": "ok"},
+			WithResponseRequestID(receipt.RequestID)))
+	}))
+	defer server.Close()
+
+	client := NewClient(WithDebugMode(true), WithLogger(&clientLog))
+	response, err := client.Do(context.Background(), NewRequest("callback", http.MethodPost, server.URL,
+		map[string]string{"status": "SUCCESS"}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// generated request IDs are lowercase and are logged as they are sent
+	id := response.RequestID
+	if id == "" || id != strings.ToLower(id) {
+		t.Fatalf("request id = %q, want a generated lowercase id", id)
+	}
+	for name, log := range map[string]string{"client": clientLog.String(), "receive": receiveLog.String(), "reply": replyLog.String()} {
+		if !strings.Contains(log, id) {
+			t.Errorf("%s log does not contain request id %s:\n%s", name, id, log)
+		}
+	}
+	if !strings.Contains(receiveLog.String(), "CALLBACK [request id: "+id+"] REQUEST (RECEIVED)") {
+		t.Errorf("receive log = %s", receiveLog.String())
+	}
+}
@@ -41,6 +41,7 @@ type (
 		Body       interface{}
 		HeaderMap  map[string]string
 		Error      error
+		RequestID  string
 	}
 
 	ResponseBuilder struct {
@@ -48,6 +49,7 @@ type (
 		payload    interface{}
 		headers    map[string]string
 		error      error
+		requestID  string
 	}
 
 	responseBuilder interface {
@@ -87,6 +89,7 @@ func (r *ResponseBuilder) Build() *Response {
 		Body:       r.payload,
 		HeaderMap:  r.headers,
 		Error:      r.error,
+		RequestID:  r.requestID,
 	}
 }
 
@@ -158,6 +161,14 @@ func WithResponseError(err error) ResponseOption {
 	}
 }
 
+// WithResponseRequestID sets the request ID echoed by Replier.Reply,
+// usually Receipt.RequestID
+func WithResponseRequestID(id string) ResponseOption {
+	return func(response *ResponseBuilder) {
+		response.requestID = id
+	}
+}
+
 func responseFormat(response *Response) (string, error) {
 
 	var (
@@ -190,6 +201,11 @@ func responseFormat(response *Response) (string, error) {
 	}
 	payload := buffer.String()
 
-	fmtString := fmt.Sprintf("\nRESPONSE DUMP:\nstatus code: %d\nheaders: %sother details:\nerror: %s\npayload: %s\n", statusCode, headersString, errMsg, payload)
+	requestID := ""
+	if response.RequestID != "" {
+		requestID = fmt.Sprintf("request id: %s\n", response.RequestID)
+	}
+
+	fmtString := fmt.Sprintf("\nRESPONSE DUMP:\n%sstatus code: %d\nheaders: %sother details:\nerror: %s\npayload: %s\n", requestID, statusCode, headersString, errMsg, payload)
 	return fmtString, nil
 }
@@ -0,0 +1,161 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package base
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrNoRoute is returned when there is no Route registered for the MNO and group
+// of a request and there is no default Route either
+var ErrNoRoute = errors.New("no route registered for the request")
+
+type (
+	// Route is the Client and the connection details used to send requests to
+	// a single MNO (and optionally a single group of its APIs). BaseURL when not
+	// empty replaces RequestInformer.RequestBaseURL, BasicAuth and Headers are
+	// added to requests that do not set them and Modifiers are passed to Client.Do
+	// after the ones supplied by the caller.
+	// TLS, timeouts and rate limits are configured on the Client using
+	// WithCACert, WithTimeout and WithRateLimiter
+	Route struct {
+		Client    *Client
+		BaseURL   string
+		BasicAuth *BasicAuth
+		Headers   map[string]string
+		Modifiers []RequestModifier
+	}
+
+	// Router sends requests through the Route registered for their MNO and group.
+	// Lookup falls back from (mno, group) to (mno, "") and then to ("", "")
+	// so a Route registered with an empty group serves all the groups of an MNO
+	// and a Route registered with empty mno and group is the default Route.
+	Router struct {
+		mu     sync.RWMutex
+		routes map[routeKey]*Route
+	}
+
+	routeKey struct {
+		mno   string
+		group string
+	}
+)
+
+func NewRouter() *Router {
+	return &Router{
+		mu:     sync.RWMutex{},
+		routes: make(map[routeKey]*Route),
+	}
+}
+
+func newRouteKey(mno, group string) routeKey {
+	return routeKey{
+		mno:   strings.ToUpper(strings.TrimSpace(mno)),
+		group: strings.ToUpper(strings.TrimSpace(group)),
+	}
+}
+
+// Register adds route for the mno and group replacing the existing one if any.
+func (r *Router) Register(mno, group string, route *Route) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes[newRouteKey(mno, group)] = route
+}
+
+// Route returns the Route that serves requests of the mno and group
+func (r *Router) Route(mno, group string) (*Route, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := []routeKey{
+		newRouteKey(mno, group),
+		newRouteKey(mno, ""),
+		newRouteKey("", ""),
+	}
+	for _, key := range keys {
+		if route, ok := r.routes[key]; ok && route != nil && route.Client != nil {
+			return route, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: mno=%q group=%q", ErrNoRoute, mno, group)
+}
+
+// Send is MakeInternalRequest followed by Client.Do of the Route that serves
+// informer.RecipientMNO and informer.RequestGroup
+func (r *Router) Send(ctx context.Context, informer RequestInformer, payload, body interface{},
+	opts ...RequestOption) (*Response, error) {
+	route, err := r.Route(informer.RecipientMNO(), informer.RequestGroup())
+	if err != nil {
+		return nil, err
+	}
+
+	request := MakeInternalRequest(informer, payload, opts...)
+	if route.BaseURL != "" {
+		request.URL = appendEndpoint(route.BaseURL, informer.RequestEndpoint())
+	}
+
+	return route.do(ctx, request, body)
+}
+
+// Do sends request through the Route that serves request.MNO and request.Group
+func (r *Router) Do(ctx context.Context, request *Request, body interface{}, modifiers ...RequestModifier) (*Response, error) {
+	route, err := r.Route(request.MNO, request.Group)
+	if err != nil {
+		return nil, err
+	}
+
+	return route.do(ctx, request, body, modifiers...)
+}
+
+func (route *Route) do(ctx context.Context, request *Request, body interface{}, modifiers ...RequestModifier) (*Response, error) {
+	// the defaults of the route are set on a copy, the caller may reuse request
+	copied := *request
+	request = &copied
+	if request.BasicAuth == nil {
+		request.BasicAuth = route.BasicAuth
+	}
+
+	if len(route.Headers) > 0 {
+		headers := make(map[string]string, len(route.Headers)+len(request.Headers))
+		for key, value := range route.Headers {
+			headers[key] = value
+		}
+		for key, value := range request.Headers {
+			headers[key] = value
+		}
+		request.Headers = headers
+	}
+
+	mods := make([]RequestModifier, 0, len(modifiers)+len(route.Modifiers))
+	mods = append(mods, modifiers...)
+	mods = append(mods, route.Modifiers...)
+	return route.Client.Do(ctx, request, body, mods...)
+}
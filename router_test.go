@@ -0,0 +1,136 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package base
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type informer struct {
+	mno   string
+	group string
+}
+
+func (i informer) String() string          { return "balance" }
+func (i informer) RequestBaseURL() string  { return "http://unused.invalid" }
+func (i informer) RequestEndpoint() string { return "/balance" }
+func (i informer) RequestMethod() string   { return http.MethodPost }
+func (i informer) RequestName() string     { return "balance" }
+func (i informer) RecipientMNO() string    { return i.mno }
+func (i informer) RequestGroup() string    { return i.group }
+
+func TestRouter_Send(t *testing.T) {
+	newServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", cTypeJson)
+			user, _, _ := r.BasicAuth()
+			_, _ = fmt.Fprintf(w, `{"name":%q,"job":%q}`, name, user+r.URL.Path)
+		}))
+	}
+
+	vodacom, airtel := newServer("vodacom"), newServer("airtel")
+	defer vodacom.Close()
+	defer airtel.Close()
+
+	client := NewClient(WithDebugMode(false), WithLogger(io.Discard))
+	router := NewRouter()
+	router.Register("vodacom", "", &Route{
+		Client:    client,
+		BaseURL:   vodacom.URL,
+		BasicAuth: &BasicAuth{Username: "voda"},
+	})
+	router.Register("airtel", "disbursement", &Route{Client: client, BaseURL: airtel.URL})
+
+	tests := []struct {
+		name    string
+		inf     informer
+		want    User
+		wantErr error
+	}{
+		{name: "mno route", inf: informer{mno: "VODACOM", group: "collection"}, want: User{Name: "vodacom", Job: "voda/balance"}},
+		{name: "group route", inf: informer{mno: "AIRTEL", group: "disbursement"}, want: User{Name: "airtel", Job: "/balance"}},
+		{name: "no route", inf: informer{mno: "AIRTEL", group: "collection"}, wantErr: ErrNoRoute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := new(User)
+			_, err := router.Send(context.TODO(), tt.inf, map[string]string{}, u)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Send() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Send() unexpected error: %v", err)
+			}
+			if u.Name != tt.want.Name || u.Job != tt.want.Job {
+				t.Errorf("Send() = %+v, want %+v", *u, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouter_DoDoesNotModifyRequest(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _, _ := r.BasicAuth()
+		got = append(got, user+" "+r.Header.Get("X-Route"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewClient(WithDebugMode(false), WithLogger(io.Discard))
+	router := NewRouter()
+	router.Register("vodacom", "", &Route{
+		Client:    client,
+		BasicAuth: &BasicAuth{Username: "voda"},
+		Headers:   map[string]string{"X-Route": "vodacom"},
+	})
+	router.Register("airtel", "", &Route{Client: client})
+
+	request := NewRequest("balance", http.MethodGet, server.URL, nil)
+	headers := fmt.Sprint(request.Headers)
+	for _, mno := range []string{"VODACOM", "AIRTEL"} {
+		request.MNO = mno
+		if _, err := router.Do(context.TODO(), request, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if request.BasicAuth != nil || fmt.Sprint(request.Headers) != headers {
+		t.Errorf("request modified by the route: %+v %v", request.BasicAuth, request.Headers)
+	}
+	if len(got) != 2 || got[0] != "voda vodacom" || got[1] != " " {
+		t.Errorf("routes sent %q, want the vodacom defaults only on the first request", got)
+	}
+}
@@ -0,0 +1,173 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultWatchInterval is used by Watch when the interval is not positive
+const defaultWatchInterval = time.Minute
+
+var _ Provider = (*Cache)(nil)
+
+type (
+	// RotateFunc is called with the new value of a secret that changed
+	RotateFunc func(name string, value []byte)
+
+	// Cache keeps the secrets of a Provider for a TTL. A secret that has a
+	// different value when it is fetched again is reported to the RotateFuncs
+	// registered with OnRotate.
+	Cache struct {
+		provider Provider
+		ttl      time.Duration
+		now      func() time.Time
+
+		mu       sync.Mutex
+		entries  map[string]*cacheEntry
+		watchers map[string][]RotateFunc
+	}
+
+	cacheEntry struct {
+		value   []byte
+		fetched time.Time
+	}
+)
+
+// NewCache returns a Cache of the secrets of provider, ttl <= 0 keeps them
+// until Refresh or Invalidate
+func NewCache(provider Provider, ttl time.Duration) *Cache {
+	return &Cache{
+		provider: provider,
+		ttl:      ttl,
+		now:      time.Now,
+		entries:  make(map[string]*cacheEntry),
+		watchers: make(map[string][]RotateFunc),
+	}
+}
+
+// Secret returns a copy of the cached value of name, it is fetched from the
+// provider when it is missing or older than the TTL
+func (c *Cache) Secret(ctx context.Context, name string) ([]byte, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[name]
+	if ok && (c.ttl <= 0 || c.now().Sub(entry.fetched) < c.ttl) {
+		value := append([]byte(nil), entry.value...)
+		c.mu.Unlock()
+		return value, nil
+	}
+	c.mu.Unlock()
+
+	return c.fetch(ctx, name)
+}
+
+// OnRotate calls fn every time the value of name changes, it can be used
+// more than once for the same name
+func (c *Cache) OnRotate(name string, fn RotateFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.watchers[name] = append(c.watchers[name], fn)
+}
+
+// Invalidate removes name from the cache, it is fetched on the next Secret
+func (c *Cache) Invalidate(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, name)
+}
+
+// Refresh fetches again the cached secrets and the ones watched with OnRotate
+// and returns the first error. Secrets that could not be fetched keep their
+// cached value.
+func (c *Cache) Refresh(ctx context.Context) error {
+	c.mu.Lock()
+	names := make([]string, 0, len(c.entries)+len(c.watchers))
+	for name := range c.entries {
+		names = append(names, name)
+	}
+	for name := range c.watchers {
+		if _, ok := c.entries[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	c.mu.Unlock()
+
+	var first error
+	for _, name := range names {
+		if _, err := c.fetch(ctx, name); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Watch calls Refresh after every interval so that rotated secrets are picked
+// up before their TTL expires, a minute when interval is not positive. It
+// blocks until ctx is done, errors are passed to onError if it is not nil.
+func (c *Cache) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := c.Refresh(ctx); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
+
+func (c *Cache) fetch(ctx context.Context, name string) ([]byte, error) {
+	value, err := c.provider.Secret(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	previous, existed := c.entries[name]
+	// the cache keeps its own copy, callers may modify or zero the values
+	// they are given
+	c.entries[name] = &cacheEntry{value: append([]byte(nil), value...), fetched: c.now()}
+	var watchers []RotateFunc
+	if existed && !bytes.Equal(previous.value, value) {
+		watchers = append(watchers, c.watchers[name]...)
+	}
+	c.mu.Unlock()
+
+	for _, fn := range watchers {
+		fn(name, append([]byte(nil), value...))
+	}
+	return value, nil
+}
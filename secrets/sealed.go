@@ -0,0 +1,219 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultIterations of PBKDF2-HMAC-SHA256 used by Seal
+	DefaultIterations = 310000
+
+	maxIterations = 10000000
+
+	saltSize = 16
+	keySize  = 32
+)
+
+// magic starts a sealed file, the last byte is the format version
+var magic = []byte("BSEC\x01")
+
+var ErrDecrypt = errors.New("secrets: wrong passphrase or corrupted file")
+
+var _ Provider = (*EncryptedFile)(nil)
+
+// EncryptedFile reads secrets from a file written by WriteEncryptedFile.
+// The file is read again when its modification time changes.
+type EncryptedFile struct {
+	path       string
+	passphrase []byte
+
+	mu      sync.Mutex
+	modTime time.Time
+	secrets map[string]string
+}
+
+// NewEncryptedFile returns the provider of the secrets of the file at path,
+// the file is read on the first call to Secret
+func NewEncryptedFile(path string, passphrase []byte) *EncryptedFile {
+	return &EncryptedFile{
+		path:       path,
+		passphrase: passphrase,
+	}
+}
+
+func (f *EncryptedFile) Secret(_ context.Context, name string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return nil, err
+	}
+	if f.secrets == nil || !info.ModTime().Equal(f.modTime) {
+		data, err := os.ReadFile(f.path)
+		if err != nil {
+			return nil, err
+		}
+		secrets, err := Open(data, f.passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.path, err)
+		}
+		f.secrets, f.modTime = secrets, info.ModTime()
+	}
+
+	value, ok := f.secrets[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return []byte(value), nil
+}
+
+// WriteEncryptedFile seals secrets with the passphrase and writes them to
+// the file at path, readable by the owner only
+func WriteEncryptedFile(path string, secrets map[string]string, passphrase []byte) error {
+	data, err := Seal(secrets, passphrase, DefaultIterations)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// Seal encrypts secrets with AES-256-GCM under a key derived from the
+// passphrase with PBKDF2-HMAC-SHA256, iterations <= 0 uses DefaultIterations.
+// The salt and the number of iterations are stored with the ciphertext.
+func Seal(secrets map[string]string, passphrase []byte, iterations int) ([]byte, error) {
+	if iterations <= 0 {
+		iterations = DefaultIterations
+	}
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, len(magic)+4+saltSize)
+	copy(header, magic)
+	binary.BigEndian.PutUint32(header[len(magic):], uint32(iterations))
+	salt := header[len(magic)+4:]
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(passphrase, salt, iterations)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := append(header, nonce...)
+	return aead.Seal(out, nonce, plaintext, header), nil
+}
+
+// Open decrypts what Seal returned
+func Open(data, passphrase []byte) (map[string]string, error) {
+	headerSize := len(magic) + 4 + saltSize
+	if len(data) < headerSize || !bytes.Equal(data[:len(magic)], magic) {
+		return nil, fmt.Errorf("%w: not a sealed secrets file", ErrDecrypt)
+	}
+	header := data[:headerSize]
+	iterations := int(binary.BigEndian.Uint32(header[len(magic):]))
+	salt := header[len(magic)+4:]
+
+	aead, err := newAEAD(passphrase, salt, iterations)
+	if err != nil {
+		return nil, err
+	}
+	rest := data[headerSize:]
+	if len(rest) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], header)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+	return secrets, nil
+}
+
+func newAEAD(passphrase, salt []byte, iterations int) (cipher.AEAD, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("secrets: empty passphrase")
+	}
+	if iterations <= 0 || iterations > maxIterations {
+		return nil, fmt.Errorf("%w: invalid iterations", ErrDecrypt)
+	}
+	block, err := aes.NewCipher(pbkdf2SHA256(passphrase, salt, iterations, keySize))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2SHA256 is PBKDF2 (RFC 8018) with HMAC-SHA256
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	key := make([]byte, 0, keyLen+sha256.Size)
+	var counter [4]byte
+	for block := uint32(1); len(key) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(counter[:], block)
+		prf.Write(counter[:])
+		u := prf.Sum(nil)
+		t := append([]byte(nil), u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
@@ -0,0 +1,111 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+// Package secrets reads credentials and keys from the environment, from
+// files and from a file sealed with a passphrase, instead of code or plain
+// configuration.
+//
+//	provider := secrets.NewCache(secrets.NewEncryptedFile("secrets.enc", passphrase), 5*time.Minute)
+//	provider.OnRotate("mpesa-password", func(name string, value []byte) { log.Printf("%s rotated", name) })
+//	go provider.Watch(ctx, time.Minute, nil)
+//
+//	client.Do(ctx, request, body, base.BasicAuthFromSecrets(provider, "mpesa-username", "mpesa-password"))
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+var ErrNotFound = errors.New("secrets: not found")
+
+var (
+	_ Provider = Env{}
+	_ Provider = Dir{}
+	_ Provider = ProviderFunc(nil)
+)
+
+type (
+	// Provider returns the value of the secret name, an error that wraps
+	// ErrNotFound when there is none. Implementations must be safe for
+	// concurrent use.
+	Provider interface {
+		Secret(ctx context.Context, name string) ([]byte, error)
+	}
+
+	ProviderFunc func(ctx context.Context, name string) ([]byte, error)
+
+	// Env reads secrets from environment variables, the name is upper cased,
+	// the characters other than letters and digits are replaced by
+	// underscores and Prefix is prepended: "mpesa-password" is read from
+	// MPESA_PASSWORD, APP_MPESA_PASSWORD with Prefix "APP_".
+	Env struct {
+		Prefix string
+	}
+
+	// Dir reads every secret from the file of the same name in the directory
+	// Path, e.g. Docker and Kubernetes secrets. Trailing new lines are removed.
+	Dir struct {
+		Path string
+	}
+)
+
+func (f ProviderFunc) Secret(ctx context.Context, name string) ([]byte, error) {
+	return f(ctx, name)
+}
+
+func (e Env) Secret(_ context.Context, name string) ([]byte, error) {
+	key := e.Prefix + strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII || !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return '_'
+		}
+		return unicode.ToUpper(r)
+	}, name)
+
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return nil, fmt.Errorf("%w: environment variable %s is not set", ErrNotFound, key)
+	}
+	return []byte(value), nil
+}
+
+func (d Dir) Secret(_ context.Context, name string) ([]byte, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return nil, fmt.Errorf("secrets: invalid name %q", name)
+	}
+	b, err := os.ReadFile(filepath.Join(d.Path, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(strings.TrimRight(string(b), "\r\n")), nil
+}
@@ -0,0 +1,179 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package secrets
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProviders(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "api-key"), []byte("k3y\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	sealed := filepath.Join(dir, "secrets.enc")
+	data, err := Seal(map[string]string{"mpesa-password": "s3cret"}, []byte("passphrase"), 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(sealed, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("APP_MPESA_PASSWORD", "from-env")
+	defer os.Unsetenv("APP_MPESA_PASSWORD")
+
+	tests := []struct {
+		name     string
+		provider Provider
+		secret   string
+		want     string
+		wantErr  error
+	}{
+		{"env", Env{Prefix: "APP_"}, "mpesa-password", "from-env", nil},
+		{"env missing", Env{}, "mpesa-password", "", ErrNotFound},
+		{"dir", Dir{Path: dir}, "api-key", "k3y", nil},
+		{"dir missing", Dir{Path: dir}, "token", "", ErrNotFound},
+		{"encrypted file", NewEncryptedFile(sealed, []byte("passphrase")), "mpesa-password", "s3cret", nil},
+		{"encrypted file missing", NewEncryptedFile(sealed, []byte("passphrase")), "api-key", "", ErrNotFound},
+		{"wrong passphrase", NewEncryptedFile(sealed, []byte("wrong")), "mpesa-password", "", ErrDecrypt},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.provider.Secret(context.Background(), tt.secret)
+			if !errors.Is(err, tt.wantErr) || tt.wantErr == nil && err != nil {
+				t.Fatalf("Secret() error = %v, want %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Secret() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := (Dir{Path: dir}).Secret(context.Background(), "../secrets.enc"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Dir.Secret(../) error = %v, want an invalid name error", err)
+	}
+}
+
+func TestPBKDF2(t *testing.T) {
+	// RFC 7914 section 11
+	got := hex.EncodeToString(pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1, 64))
+	want := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+		"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"
+	if got != want {
+		t.Errorf("pbkdf2SHA256() = %s, want %s", got, want)
+	}
+}
+
+func TestCache(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		value = "v1"
+		calls int
+	)
+	provider := ProviderFunc(func(ctx context.Context, name string) ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		return []byte(value), nil
+	})
+	now := time.Unix(1635000000, 0)
+	cache := NewCache(provider, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	var rotated []string
+	cache.OnRotate("password", func(name string, v []byte) {
+		rotated = append(rotated, string(v))
+	})
+
+	get := func() string {
+		v, err := cache.Secret(context.Background(), "password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(v)
+	}
+
+	if got := get(); got != "v1" {
+		t.Fatalf("Secret() = %q, want v1", got)
+	}
+	mu.Lock()
+	value = "v2"
+	mu.Unlock()
+	if got := get(); got != "v1" || calls != 1 {
+		t.Fatalf("Secret() within TTL = %q after %d calls, want the cached v1", got, calls)
+	}
+
+	now = now.Add(time.Minute)
+	if got := get(); got != "v2" {
+		t.Fatalf("Secret() after TTL = %q, want v2", got)
+	}
+	mu.Lock()
+	value = "v3"
+	mu.Unlock()
+	if err := cache.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := get(); got != "v3" {
+		t.Fatalf("Secret() after Refresh = %q, want v3", got)
+	}
+	if len(rotated) != 2 || rotated[0] != "v2" || rotated[1] != "v3" {
+		t.Errorf("rotations = %v, want [v2 v3]", rotated)
+	}
+}
+
+func TestCache_Copies(t *testing.T) {
+	cache := NewCache(ProviderFunc(func(ctx context.Context, name string) ([]byte, error) {
+		return []byte("s3cr3t"), nil
+	}), time.Minute)
+
+	// zeroing the values that are returned does not change the cached one
+	for i := 0; i < 2; i++ {
+		v, err := cache.Secret(context.Background(), "password")
+		if err != nil || string(v) != "s3cr3t" {
+			t.Fatalf("Secret() = %q, %v, want s3cr3t", v, err)
+		}
+		for j := range v {
+			v[j] = 0
+		}
+	}
+}
+
+func TestCache_WatchInterval(t *testing.T) {
+	cache := NewCache(Env{Prefix: "BASE_TEST_"}, time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, interval := range []time.Duration{0, -time.Second} {
+		// returns right away instead of panicking in time.NewTicker
+		cache.Watch(ctx, interval, nil)
+	}
+}
@@ -34,47 +34,121 @@ import (
 	"fmt"
 	stdio "io"
 	"net/http"
+	"reflect"
 	"strings"
+	"time"
+
+	"github.com/techcraftlabs/base/trace"
 )
 
 const errStatusCodeMargin = 400
 
 var DoErr = errors.New("result code is above or equal to 400")
 
+// ErrInvalidBody is returned by Do when body is not a non-nil pointer
+var ErrInvalidBody = errors.New("body must be a non-nil pointer")
+
 // Do perform http request and return *Response. It takes *Request and body as input. It will inspect
 // the header of the http.Response then determine the Content-Type of the response body. It will then
 // unmarshal the content of the response body to the specified type. Error returned by this function
 // is operation error. In case the response status code is equal or above to 400 and the operations like
 // unmarshalling or reading header have all gone correctly the error will be nil but Response.Error will not.
+// DoTyped returns typed success and error bodies instead.
 func (c *Client) Do(ctx context.Context, request *Request, body interface{}, modifiers ...RequestModifier) (*Response, error) {
+	if body != nil {
+		if v := reflect.ValueOf(body); v.Kind() != reflect.Ptr || v.IsNil() {
+			return nil, fmt.Errorf("%w: got %T", ErrInvalidBody, body)
+		}
+	}
+	typed, err := doTyped[struct{}, struct{}](ctx, c, request, body, body, true, modifiers...)
+	if typed == nil {
+		return nil, err
+	}
+	return typed.Response, err
+}
+
+// doTyped sends request and decodes the response body into success when the
+// status code is below 400 and into failure otherwise, nil targets are not
+// decoded. Targets that are a *Resp or an *ErrResp are set in Body and
+// ErrorBody of the TypedResponse. It is the implementation of Do and DoTyped. When strict is false,
+// as for DoTyped, empty and 204 bodies are not decoded and an error body that
+// can not be decoded is reported in Response.Error instead of failing.
+func doTyped[Resp, ErrResp any](ctx context.Context, c *Client, request *Request, success, failure interface{},
+	strict bool, modifiers ...RequestModifier) (typed *TypedResponse[Resp, ErrResp], err error) {
 
 	var (
 		rn               = request.Name
+		requestID        = request.RequestID
 		errDecodingBody  = errors.New("error while decoding response body")
 		errUnknownHeader = errors.New("unknown content-type header")
 	)
 
 	var (
+		started      = time.Now()
 		_, cancel    = context.WithTimeout(ctx, defaultTimeout)
 		req          *http.Request
 		res          *http.Response
 		reqBodyBytes []byte
 		resBodyBytes []byte
+		response     *Response
 	)
 	defer cancel()
+	ctx, requestID = withRequestID(ctx, requestID)
+	ctx, span := c.getTracer().Start(ctx, rn, trace.SpanKindClient)
+	defer func() {
+		span.SetAttribute("http.method", request.Method)
+		span.SetAttribute("request_id", requestID)
+		if request.MNO != "" {
+			span.SetAttribute("mno", request.MNO)
+		}
+		if res != nil {
+			span.SetAttribute("http.status_code", res.StatusCode)
+		}
+		if err != nil {
+			span.SetError(err)
+		}
+		span.End()
+	}()
+	if len(c.hooks) > 0 {
+		defer func() {
+			callHooks(c.hooks, &Exchange{
+				Kind:         ExchangeOutgoing,
+				Name:         rn,
+				RequestID:    requestID,
+				Request:      req,
+				RequestBody:  reqBodyBytes,
+				Response:     res,
+				ResponseBody: resBodyBytes,
+				Started:      started,
+				Duration:     time.Since(started),
+				Err:          err,
+			})
+		}()
+	}
+	if c.metrics != nil {
+		c.metrics.RequestStarted(rn, request.MNO)
+		defer func() {
+			status := 0
+			if res != nil {
+				status = res.StatusCode
+			}
+			c.metrics.RequestFinished(rn, request.Method, request.MNO, status, time.Since(started))
+		}()
+	}
 	defer func(debug bool) {
 		if debug {
 			req.Body = stdio.NopCloser(bytes.NewBuffer(reqBodyBytes))
+			name := logName(strings.ToUpper(rn), requestID)
 			if res == nil {
-				c.logOut(strings.ToUpper(rn), req, nil)
+				c.logOut(name, req, nil)
 
 				return
 			}
 			res.Body = stdio.NopCloser(bytes.NewBuffer(resBodyBytes))
-			c.logOut(strings.ToUpper(rn), req, res)
+			c.logOut(name, req, res)
 		}
 	}(c.DebugMode)
-	req, err := NewRequestWithContext(ctx, request, modifiers...)
+	req, requestID, err = c.newHTTPRequest(ctx, request, requestID, modifiers...)
 
 	if err != nil {
 		return nil, err
@@ -85,6 +159,13 @@ func (c *Client) Do(ctx context.Context, request *Request, body interface{}, mod
 	}
 
 	req.Body = stdio.NopCloser(bytes.NewBuffer(reqBodyBytes))
+
+	if c.limiter != nil {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+
 	res, doErr := c.Http.Do(req)
 
 	if doErr != nil {
@@ -95,10 +176,11 @@ func (c *Client) Do(ctx context.Context, request *Request, body interface{}, mod
 		resBodyBytes, _ = stdio.ReadAll(res.Body)
 	}
 
-	response := new(Response)
+	response = new(Response)
 	statusCode := res.StatusCode
 	response.StatusCode = statusCode
 	response.HTTP = res
+	response.RequestID = requestID
 
 	//change res.Header to map[string]string
 	header := make(map[string]string)
@@ -120,11 +202,32 @@ func (c *Client) Do(ctx context.Context, request *Request, body interface{}, mod
 	isXML := cType == XmlPayload || cType == TextXmlPayload
 	isOK := statusCode < errStatusCodeMargin
 
+	typed = &TypedResponse[Resp, ErrResp]{Response: response}
+	hasBody := len(resBodyBytes) > 0 && statusCode != http.StatusNoContent
+	var body interface{}
+	if isOK && success != nil && (strict || hasBody) {
+		body = success
+		typed.Body, _ = success.(*Resp)
+	} else if !isOK && failure != nil && (strict || hasBody) {
+		body = failure
+		typed.ErrorBody, _ = failure.(*ErrResp)
+	}
+
+	// undecodable error bodies, e.g. the HTML of a gateway, keep the response
+	undecodable := func(dErr error) (*TypedResponse[Resp, ErrResp], error) {
+		typed.ErrorBody = nil
+		response.Error = fmt.Errorf("%w: %v", DoErr, dErr)
+		return typed, nil
+	}
+
 	if body != nil {
 		if isJSON {
 			dErr := json.NewDecoder(bytes.NewBuffer(resBodyBytes)).Decode(body)
 			isDecodeErr := dErr != nil && !errors.Is(dErr, stdio.EOF)
 
+			if isDecodeErr && !isOK && !strict {
+				return undecodable(fmt.Errorf("%v: %w", errDecodingBody, dErr))
+			}
 			if isDecodeErr {
 				return nil, fmt.Errorf("%w: %v", dErr, errDecodingBody)
 			}
@@ -133,15 +236,18 @@ func (c *Client) Do(ctx context.Context, request *Request, body interface{}, mod
 
 			if !isOK {
 				response.Error = DoErr
-				return response, nil
+				return typed, nil
 			}
 
-			return response, nil
+			return typed, nil
 
 		} else if isXML {
 
 			dErr := xml.NewDecoder(bytes.NewBuffer(resBodyBytes)).Decode(body)
 			isDecodeErr := dErr != nil && !errors.Is(dErr, stdio.EOF)
+			if isDecodeErr && !isOK && !strict {
+				return undecodable(fmt.Errorf("%v: %w", errDecodingBody, dErr))
+			}
 			if isDecodeErr {
 				return nil, fmt.Errorf("%w: %v", dErr, errDecodingBody)
 			}
@@ -149,12 +255,15 @@ func (c *Client) Do(ctx context.Context, request *Request, body interface{}, mod
 			response.Body = body
 			if !isOK {
 				response.Error = DoErr
-				return response, nil
+				return typed, nil
 			}
-			return response, nil
+			return typed, nil
 
 		} else {
 			//response.Error = errUnknownHeader
+			if !isOK && !strict {
+				return undecodable(fmt.Errorf("%w: %q", errUnknownHeader, contentType))
+			}
 			return nil, errUnknownHeader
 		}
 	}
@@ -162,6 +271,38 @@ func (c *Client) Do(ctx context.Context, request *Request, body interface{}, mod
 	if !isOK {
 		response.Error = DoErr
 	}
-	return response, nil
+	return typed, nil
+
+}
 
+// withRequestID returns the request ID to send, the one of the Request, of
+// the context or a new one, and a context that carries it
+func withRequestID(ctx context.Context, requestID string) (context.Context, string) {
+	if requestID == "" {
+		requestID = RequestIDFromContext(ctx)
+	}
+	if requestID == "" {
+		requestID = NewRequestID()
+	}
+	return ContextWithRequestID(ctx, requestID), requestID
+}
+
+// newHTTPRequest is NewRequestWithContext that also sets the trace and the
+// request ID headers. A request ID set with the headers or by a modifier takes
+// precedence, the one that is sent is returned.
+func (c *Client) newHTTPRequest(ctx context.Context, request *Request, requestID string,
+	modifiers ...RequestModifier) (*http.Request, string, error) {
+	req, err := NewRequestWithContext(ctx, request, modifiers...)
+	if err != nil {
+		return nil, requestID, err
+	}
+
+	trace.Inject(ctx, req.Header)
+	idHeader := c.requestIDHeader()
+	if id := req.Header.Get(idHeader); id != "" {
+		requestID = id
+	} else {
+		req.Header.Set(idHeader, requestID)
+	}
+	return req, requestID, nil
 }
@@ -0,0 +1,312 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package server
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/techcraftlabs/base"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	receiptType = reflect.TypeOf((*base.Receipt)(nil))
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+type (
+	// Error is an error with the status code and the body of the reply,
+	// handlers return it to choose how a failure is reported
+	Error struct {
+		Status  int
+		Code    string
+		Message string
+		Err     error
+	}
+
+	// ErrorBody is the body of the replies of the default ErrorMapper
+	ErrorBody struct {
+		XMLName   xml.Name `json:"-" xml:"error"`
+		Code      string   `json:"code,omitempty" xml:"code,omitempty"`
+		Message   string   `json:"message" xml:"message"`
+		RequestID string   `json:"request_id,omitempty" xml:"request_id,omitempty"`
+	}
+
+	// ErrorMapper returns the status code and the body of the reply to err,
+	// a nil body replies with no body
+	ErrorMapper func(err error) (status int, body interface{})
+
+	// Validator is implemented by request types that check themselves after
+	// they are decoded, a failure is replied with 400 Bad Request
+	Validator interface {
+		Validate() error
+	}
+
+	// StatusCoder is implemented by response types that are not replied
+	// with 200 OK
+	StatusCoder interface {
+		StatusCode() int
+	}
+
+	handler struct {
+		name     string
+		newReq   func() interface{}
+		call     func(ctx context.Context, receipt *base.Receipt, req interface{}) (interface{}, error)
+		receiver base.Receiver
+		replier  base.Replier
+		mapError ErrorMapper
+		validate func(v interface{}) error
+	}
+)
+
+// NewError returns an *Error with status, code and message
+func NewError(status int, code, message string) *Error {
+	return &Error{
+		Status:  status,
+		Code:    code,
+		Message: message,
+	}
+}
+
+func (e *Error) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.Status)
+	}
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", msg, e.Err)
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// DefaultErrorMapper replies to an *Error with its status, to
+// base.ErrUnauthorized with 401 and to every other error with 500. The
+// messages of unknown errors are not sent to the caller.
+func DefaultErrorMapper(err error) (int, interface{}) {
+	var e *Error
+	switch {
+	case errors.As(err, &e):
+		msg := e.Message
+		if msg == "" {
+			msg = http.StatusText(e.Status)
+		}
+		return e.Status, &ErrorBody{Code: e.Code, Message: msg}
+	case errors.Is(err, base.ErrUnauthorized):
+		return http.StatusUnauthorized, &ErrorBody{Code: "unauthorized", Message: http.StatusText(http.StatusUnauthorized)}
+	default:
+		return http.StatusInternalServerError, &ErrorBody{Code: "internal_error", Message: http.StatusText(http.StatusInternalServerError)}
+	}
+}
+
+// NewHandler returns an http.Handler that calls fn with the received
+// requests. fn must be a
+//
+//	func(ctx context.Context, receipt *base.Receipt, request *Req) (*Resp, error)
+//
+// NewHandler panics otherwise, NewTypedHandler checks it at compile time.
+// The handler receives the request into a new Req with the Receiver, so its
+// Authenticator is checked, validates it, calls fn and replies with the
+// Replier in the format of the Accept header, the one of the request by
+// default. Requests that are not JSON or XML are replied with 415
+// Unsupported Media Type. Errors are replied as mapped by the ErrorMapper,
+// DefaultErrorMapper by default.
+func NewHandler(name string, fn interface{}, opts ...Option) http.Handler {
+	return newHandler(name, fn, newOptions(opts...))
+}
+
+func newHandler(name string, fn interface{}, o *options) *handler {
+	v := reflect.ValueOf(fn)
+	t := v.Type()
+	if t.Kind() != reflect.Func || t.NumIn() != 3 || t.NumOut() != 2 ||
+		t.In(0) != contextType || t.In(1) != receiptType || t.In(2).Kind() != reflect.Ptr ||
+		t.Out(0).Kind() != reflect.Ptr || t.Out(1) != errorType {
+		panic(fmt.Sprintf("server: handler %s: %T is not a func(context.Context, *base.Receipt, *Req) (*Resp, error)", name, fn))
+	}
+
+	reqType := t.In(2).Elem()
+	newReq := func() interface{} {
+		return reflect.New(reqType).Interface()
+	}
+	call := func(ctx context.Context, receipt *base.Receipt, req interface{}) (interface{}, error) {
+		out := v.Call([]reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(receipt), reflect.ValueOf(req)})
+		var err error
+		if e := out[1].Interface(); e != nil {
+			err = e.(error)
+		}
+		if out[0].IsNil() {
+			return nil, err
+		}
+		return out[0].Interface(), err
+	}
+	return buildHandler(name, newReq, call, o)
+}
+
+// NewTypedHandler is NewHandler for a func whose types are checked at
+// compile time
+func NewTypedHandler[Req, Resp any](name string, fn func(ctx context.Context, receipt *base.Receipt, request *Req) (*Resp, error),
+	opts ...Option) http.Handler {
+	return newTypedHandler(name, fn, newOptions(opts...))
+}
+
+func newTypedHandler[Req, Resp any](name string, fn func(ctx context.Context, receipt *base.Receipt, request *Req) (*Resp, error),
+	o *options) *handler {
+	newReq := func() interface{} {
+		return new(Req)
+	}
+	call := func(ctx context.Context, receipt *base.Receipt, req interface{}) (interface{}, error) {
+		resp, err := fn(ctx, receipt, req.(*Req))
+		if resp == nil {
+			return nil, err
+		}
+		return resp, err
+	}
+	return buildHandler(name, newReq, call, o)
+}
+
+func buildHandler(name string, newReq func() interface{},
+	call func(ctx context.Context, receipt *base.Receipt, req interface{}) (interface{}, error), o *options) *handler {
+	return &handler{
+		name:     name,
+		newReq:   newReq,
+		call:     call,
+		receiver: o.receiver,
+		replier:  o.replier,
+		mapError: o.mapError,
+		validate: o.validate,
+	}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	format := negotiate(r)
+	req := h.newReq()
+
+	receipt, err := h.receiver.Receive(r.Context(), h.name, r, req)
+	switch {
+	case errors.Is(err, base.ErrUnauthorized):
+	case err != nil && receipt == nil:
+		err = &Error{Status: http.StatusBadRequest, Code: "invalid_request", Message: "could not read the request", Err: err}
+	case err != nil:
+		err = &Error{Status: http.StatusBadRequest, Code: "invalid_request", Message: "could not decode the request", Err: err}
+	case !decodable(r.Header.Get("Content-Type")):
+		// the Receiver leaves the request zero valued, it must not reach fn
+		err = &Error{Status: http.StatusUnsupportedMediaType, Code: "unsupported_media_type", Message: "the request body must be JSON or XML"}
+	default:
+		err = h.check(req)
+	}
+	if err != nil {
+		h.replyError(w, receipt, format, err)
+		return
+	}
+
+	body, err := h.call(receipt.Context(), receipt, req)
+	if err != nil {
+		h.replyError(w, receipt, format, err)
+		return
+	}
+
+	status := http.StatusOK
+	if body == nil {
+		status = http.StatusNoContent
+	} else if sc, ok := body.(StatusCoder); ok {
+		status = sc.StatusCode()
+	}
+	h.replier.Reply(w, base.NewResponse(status, body, format, base.WithResponseRequestID(receipt.RequestID)))
+}
+
+func (h *handler) check(v interface{}) error {
+	var err error
+	if validator, ok := v.(Validator); ok {
+		err = validator.Validate()
+	}
+	if err == nil && h.validate != nil {
+		err = h.validate(v)
+	}
+	if err != nil {
+		var e *Error
+		if errors.As(err, &e) {
+			return err
+		}
+		return &Error{Status: http.StatusBadRequest, Code: "invalid_request", Message: err.Error(), Err: err}
+	}
+	return nil
+}
+
+func (h *handler) replyError(w http.ResponseWriter, receipt *base.Receipt, format base.ResponseOption, err error) {
+	status, body := h.mapError(err)
+	opts := []base.ResponseOption{format, base.WithResponseError(err)}
+	if receipt != nil {
+		opts = append(opts, base.WithResponseRequestID(receipt.RequestID))
+		body = withRequestID(body, receipt.RequestID)
+	}
+	h.replier.Reply(w, base.NewResponse(status, body, opts...))
+}
+
+// withRequestID returns a copy of an *ErrorBody with the request ID, the
+// mappers may return the same body every time
+func withRequestID(body interface{}, requestID string) interface{} {
+	eb, ok := body.(*ErrorBody)
+	if !ok || eb == nil || eb.RequestID != "" {
+		return body
+	}
+	cp := *eb
+	cp.RequestID = requestID
+	return &cp
+}
+
+// decodable reports whether the Receiver decodes a body of contentType
+func decodable(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	for _, t := range []string{"application/json", "application/xml", "text/xml"} {
+		if strings.Contains(contentType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiate returns the option that sets the Content-Type of the reply from
+// the Accept header, or from the Content-Type of the request, JSON by default
+func negotiate(r *http.Request) base.ResponseOption {
+	accept := strings.ToLower(r.Header.Get("Accept"))
+	xmlFirst := func(s string) bool {
+		x, j := strings.Index(s, "xml"), strings.Index(s, "json")
+		return x >= 0 && (j < 0 || x < j)
+	}
+	if xmlFirst(accept) || !strings.Contains(accept, "json") && xmlFirst(strings.ToLower(r.Header.Get("Content-Type"))) {
+		return base.WithResponseHeaders(map[string]string{"Content-Type": "application/xml"})
+	}
+	return base.WithResponseHeaders(map[string]string{"Content-Type": "application/json"})
+}
@@ -0,0 +1,146 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/techcraftlabs/base"
+	baseio "github.com/techcraftlabs/base/io"
+)
+
+type (
+	// PanicError is the error of a recovered panic
+	PanicError struct {
+		Value interface{}
+		Stack []byte
+	}
+
+	// RecoveryConfig of Recover. Acknowledge maps the *PanicError to the
+	// reply, DefaultErrorMapper (500 Internal Server Error) by default. Use
+	// StaticErrorMapper for operators that want a 200 with a failure code.
+	RecoveryConfig struct {
+		Logger          io.Writer
+		Replier         base.Replier
+		Acknowledge     ErrorMapper
+		RequestIDHeader string
+	}
+
+	recoveryWriter struct {
+		http.ResponseWriter
+		wroteHeader bool
+	}
+)
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// StaticErrorMapper replies to every error with status and body, e.g. the
+// failure acknowledgement of an operator that retries anything but a 200
+func StaticErrorMapper(status int, body interface{}) ErrorMapper {
+	return func(error) (int, interface{}) {
+		return status, body
+	}
+}
+
+// Recover returns a Middleware that recovers the panics of the handlers it
+// wraps, logs them with their stack and the request ID and replies with the
+// acknowledgement of config in the negotiated format. Nothing is replied if
+// the handler already started its reply. A request without a request ID gets
+// one, so the log and the Receipt of the handler have the same.
+func Recover(config RecoveryConfig) Middleware {
+	logger := config.Logger
+	if logger == nil {
+		logger = baseio.StdErr
+	}
+	replier := config.Replier
+	if replier == nil {
+		replier = base.NewReplier(logger, false)
+	}
+	acknowledge := config.Acknowledge
+	if acknowledge == nil {
+		acknowledge = DefaultErrorMapper
+	}
+	idHeader := config.RequestIDHeader
+	if idHeader == "" {
+		idHeader = base.DefaultRequestIDHeader
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(idHeader)
+			if requestID == "" {
+				requestID = base.NewRequestID()
+				r.Header.Set(idHeader, requestID)
+			}
+			rw := &recoveryWriter{ResponseWriter: w}
+
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+
+				err := &PanicError{Value: v, Stack: debug.Stack()}
+				_, _ = fmt.Fprintf(logger, "\n\nPANIC %s %s (request id: %s): %v\n%s\n", r.Method, r.URL.Path, requestID, v, err.Stack)
+				if rw.wroteHeader {
+					return
+				}
+
+				status, body := acknowledge(err)
+				body = withRequestID(body, requestID)
+				replier.Reply(w, base.NewResponse(status, body, negotiate(r),
+					base.WithResponseError(err), base.WithResponseRequestID(requestID)))
+			}()
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+func (w *recoveryWriter) WriteHeader(status int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recoveryWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recoveryWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.wroteHeader = true
+		f.Flush()
+	}
+}
@@ -0,0 +1,139 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package server
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/techcraftlabs/base"
+)
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestRecover(t *testing.T) {
+	type operatorAck struct {
+		ResultCode string `json:"resultCode" xml:"resultCode"`
+	}
+
+	tests := []struct {
+		name       string
+		config     RecoveryConfig
+		handler    http.HandlerFunc
+		requestID  string
+		wantStatus int
+		wantBody   string
+		wantLogged bool
+	}{
+		{
+			name:       "default acknowledgement",
+			handler:    func(w http.ResponseWriter, r *http.Request) { panic("nil map") },
+			requestID:  "req-1",
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   `"request_id":"req-1"`,
+			wantLogged: true,
+		},
+		{
+			name:       "operator acknowledgement",
+			config:     RecoveryConfig{Acknowledge: StaticErrorMapper(http.StatusOK, &operatorAck{ResultCode: "1"})},
+			handler:    func(w http.ResponseWriter, r *http.Request) { panic(io.ErrUnexpectedEOF) },
+			wantStatus: http.StatusOK,
+			wantBody:   `{"resultCode":"1"}`,
+			wantLogged: true,
+		},
+		{
+			name: "reply already started",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				panic("after reply")
+			},
+			wantStatus: http.StatusAccepted,
+			wantLogged: true,
+		},
+		{
+			name:       "no panic",
+			handler:    func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) },
+			wantStatus: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := new(syncBuffer)
+			tt.config.Logger = logs
+			tt.config.Replier = base.NewReplier(io.Discard, false)
+
+			var seenID string
+			handler := Recover(tt.config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seenID = r.Header.Get(base.DefaultRequestIDHeader)
+				tt.handler(w, r)
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/callback", strings.NewReader(`{}`))
+			if tt.requestID != "" {
+				req.Header.Set(base.DefaultRequestIDHeader, tt.requestID)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", rec.Body, tt.wantBody)
+			}
+			logged := logs.String()
+			if tt.wantLogged != strings.Contains(logged, "PANIC") {
+				t.Errorf("logs = %q, want logged %v", logged, tt.wantLogged)
+			}
+			if tt.wantLogged && (!strings.Contains(logged, "request id: "+seenID) || !strings.Contains(logged, "recover_test.go")) {
+				t.Errorf("logs = %q, want the request id %s and the stack", logged, seenID)
+			}
+			if seenID == "" || tt.requestID != "" && seenID != tt.requestID {
+				t.Errorf("handler request id = %q, want %q", seenID, tt.requestID)
+			}
+		})
+	}
+}
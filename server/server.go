@@ -0,0 +1,250 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+// Package server serves callback endpoints with typed handlers built on
+// base.Receiver and base.Replier.
+//
+//	srv := server.New(":8080",
+//		server.WithReceiver(base.NewReceiver(os.Stderr, false, base.AuthenticatorOption(auth))),
+//		server.WithMiddleware(server.Recover(server.RecoveryConfig{Logger: os.Stderr}), logRequests))
+//	srv.Handle("/callbacks/c2b", "c2b-callback", func(ctx context.Context, receipt *base.Receipt, cb *C2BCallback) (*Ack, error) {
+//		return &Ack{Code: "0"}, store(ctx, cb)
+//	})
+//
+//	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+//	defer stop()
+//	err := srv.ListenAndServe(ctx) // shuts down gracefully when ctx is done
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/techcraftlabs/base"
+	baseio "github.com/techcraftlabs/base/io"
+)
+
+const (
+	defaultShutdownTimeout   = 30 * time.Second
+	defaultReadHeaderTimeout = 10 * time.Second
+)
+
+type (
+	// Middleware wraps an http.Handler
+	Middleware func(next http.Handler) http.Handler
+
+	Option func(o *options)
+
+	options struct {
+		logger          io.Writer
+		receiver        base.Receiver
+		replier         base.Replier
+		middlewares     []Middleware
+		mapError        ErrorMapper
+		validate        func(v interface{}) error
+		shutdownTimeout time.Duration
+	}
+
+	// Server is an http.Server whose handlers are wrapped by the middlewares
+	// and that shuts down gracefully
+	Server struct {
+		HTTP *http.Server
+		mux  *http.ServeMux
+		o    *options
+	}
+)
+
+func newOptions(opts ...Option) *options {
+	o := &options{
+		logger:          baseio.StdErr,
+		mapError:        DefaultErrorMapper,
+		shutdownTimeout: defaultShutdownTimeout,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.receiver == nil {
+		o.receiver = base.NewReceiver(o.logger, false)
+	}
+	if o.replier == nil {
+		o.replier = base.NewReplier(o.logger, false)
+	}
+	return o
+}
+
+// WithLogger sets the logger of the default Receiver and Replier and of the
+// server errors, os.Stderr by default. Nil value is ignored
+func WithLogger(logger io.Writer) Option {
+	return func(o *options) {
+		if logger == nil {
+			return
+		}
+		o.logger = logger
+	}
+}
+
+// WithReceiver sets the Receiver of the handlers, e.g. one with an
+// Authenticator. Nil value is ignored
+func WithReceiver(receiver base.Receiver) Option {
+	return func(o *options) {
+		if receiver == nil {
+			return
+		}
+		o.receiver = receiver
+	}
+}
+
+// WithReplier sets the Replier of the handlers. Nil value is ignored
+func WithReplier(replier base.Replier) Option {
+	return func(o *options) {
+		if replier == nil {
+			return
+		}
+		o.replier = replier
+	}
+}
+
+// WithMiddleware adds middlewares to the Server, the first one is the
+// outermost. It can be used more than once.
+func WithMiddleware(middlewares ...Middleware) Option {
+	return func(o *options) {
+		o.middlewares = append(o.middlewares, middlewares...)
+	}
+}
+
+// WithErrorMapper sets how the errors of the handlers are replied,
+// DefaultErrorMapper by default. Nil value is ignored
+func WithErrorMapper(mapper ErrorMapper) Option {
+	return func(o *options) {
+		if mapper == nil {
+			return
+		}
+		o.mapError = mapper
+	}
+}
+
+// WithValidator validates every decoded request, after its own Validate
+// method if it is a Validator
+func WithValidator(validate func(v interface{}) error) Option {
+	return func(o *options) {
+		o.validate = validate
+	}
+}
+
+// WithShutdownTimeout sets how long ListenAndServe waits for the requests in
+// flight when its context is done, 30 seconds by default
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		if timeout <= 0 {
+			return
+		}
+		o.shutdownTimeout = timeout
+	}
+}
+
+// Chain wraps h with the middlewares, the first one is the outermost
+func Chain(h http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// New returns a Server listening on addr
+func New(addr string, opts ...Option) *Server {
+	s := &Server{
+		mux: http.NewServeMux(),
+		o:   newOptions(opts...),
+	}
+	s.HTTP = &http.Server{
+		Addr:              addr,
+		Handler:           Chain(s.mux, s.o.middlewares...),
+		ReadHeaderTimeout: defaultReadHeaderTimeout,
+		ErrorLog:          log.New(s.o.logger, "server: ", log.LstdFlags),
+	}
+	return s
+}
+
+// Handle registers NewHandler(name, fn) for pattern, see http.ServeMux
+func (s *Server) Handle(pattern, name string, fn interface{}) {
+	s.mux.Handle(pattern, newHandler(name, fn, s.o))
+}
+
+// HandleTyped registers NewTypedHandler(name, fn) for pattern of s
+func HandleTyped[Req, Resp any](s *Server, pattern, name string,
+	fn func(ctx context.Context, receipt *base.Receipt, request *Req) (*Resp, error)) {
+	s.mux.Handle(pattern, newTypedHandler(name, fn, s.o))
+}
+
+// HandleHTTP registers a plain http.Handler for pattern, it is wrapped by
+// the middlewares too
+func (s *Server) HandleHTTP(pattern string, h http.Handler) {
+	s.mux.Handle(pattern, h)
+}
+
+// Handler returns the handler of the Server, the ServeMux wrapped by the
+// middlewares, e.g. to be used with httptest.NewServer
+func (s *Server) Handler() http.Handler {
+	return s.HTTP.Handler
+}
+
+// ListenAndServe listens on the address of the Server and serves until ctx
+// is done, then shuts down gracefully
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	l, err := net.Listen("tcp", s.HTTP.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, l)
+}
+
+// Serve serves the connections of l until ctx is done, then stops accepting
+// connections and waits for the requests in flight up to the shutdown
+// timeout
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	served := make(chan error, 1)
+	go func() {
+		served <- s.HTTP.Serve(l)
+	}()
+
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.o.shutdownTimeout)
+	defer cancel()
+	err := s.HTTP.Shutdown(shutdownCtx)
+	if serveErr := <-served; !errors.Is(serveErr, http.ErrServerClosed) && err == nil {
+		err = serveErr
+	}
+	return err
+}
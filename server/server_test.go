@@ -0,0 +1,258 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/techcraftlabs/base"
+)
+
+type (
+	callback struct {
+		Reference string `json:"reference" xml:"reference"`
+		Amount    int    `json:"amount" xml:"amount"`
+	}
+
+	ack struct {
+		Code      string `json:"code" xml:"code"`
+		Reference string `json:"reference" xml:"reference"`
+	}
+)
+
+func (c *callback) Validate() error {
+	if c.Reference == "" {
+		return errors.New("reference is required")
+	}
+	return nil
+}
+
+func TestHandler(t *testing.T) {
+	var order []string
+	tag := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	auth := base.AuthenticatorFunc(func(receipt *base.Receipt) error {
+		if receipt.ApiKey != "k3y" {
+			return errors.New("wrong api key")
+		}
+		return nil
+	})
+
+	srv := New("", WithLogger(io.Discard), WithMiddleware(tag("outer"), tag("inner")),
+		WithReceiver(base.NewReceiver(io.Discard, false, base.AuthenticatorOption(auth))))
+	srv.Handle("/callback", "callback", func(ctx context.Context, receipt *base.Receipt, cb *callback) (*ack, error) {
+		switch cb.Reference {
+		case "duplicate":
+			return nil, NewError(http.StatusConflict, "duplicate", "already processed")
+		case "broken":
+			return nil, errors.New("database is down")
+		case "ignored":
+			return nil, nil
+		}
+		return &ack{Code: "0", Reference: cb.Reference}, nil
+	})
+	HandleTyped(srv, "/typed", "typed", func(ctx context.Context, receipt *base.Receipt, cb *callback) (*ack, error) {
+		return &ack{Code: "0", Reference: cb.Reference}, nil
+	})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		accept      string
+		apiKey      string
+		body        string
+		wantStatus  int
+		wantType    string
+		wantBody    string
+	}{
+		{"json", "/callback", "application/json", "", "k3y", `{"reference":"r1","amount":10}`, 200, "application/json", `"reference":"r1"`},
+		{"xml by content type", "/callback", "application/xml", "", "k3y", `<callback><reference>r2</reference></callback>`, 200, "application/xml", "<reference>r2</reference>"},
+		{"xml by accept", "/callback", "application/json", "application/xml", "k3y", `{"reference":"r3"}`, 200, "application/xml", "<code>0</code>"},
+		{"unauthorized", "/callback", "application/json", "", "wrong", `{"reference":"r1"}`, 401, "application/json", `"code":"unauthorized"`},
+		{"invalid json", "/callback", "application/json", "", "k3y", `{"reference":`, 400, "application/json", `"code":"invalid_request"`},
+		{"validation", "/callback", "application/json", "", "k3y", `{"amount":10}`, 400, "application/json", "reference is required"},
+		{"mapped error", "/callback", "application/json", "", "k3y", `{"reference":"duplicate"}`, 409, "application/json", `"message":"already processed"`},
+		{"internal error", "/callback", "application/json", "", "k3y", `{"reference":"broken"}`, 500, "application/json", `"code":"internal_error"`},
+		{"no content", "/callback", "application/json", "", "k3y", `{"reference":"ignored"}`, 204, "", ""},
+		{"no content type", "/callback", "", "", "k3y", `{"reference":"r5"}`, 415, "application/json", `"code":"unsupported_media_type"`},
+		{"form", "/callback", "application/x-www-form-urlencoded", "", "k3y", `reference=r5`, 415, "application/json", `"code":"unsupported_media_type"`},
+		{"typed", "/typed", "application/json", "", "k3y", `{"reference":"r4"}`, 200, "application/json", `"reference":"r4"`},
+		{"typed validation", "/typed", "application/json", "", "k3y", `{}`, 400, "application/json", "reference is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order = nil
+			req, _ := http.NewRequest(http.MethodPost, ts.URL+tt.path, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			req.Header.Set("X-Api-Key", tt.apiKey)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			body, _ := io.ReadAll(res.Body)
+
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d (%s)", res.StatusCode, tt.wantStatus, body)
+			}
+			if tt.wantType != "" && !strings.HasPrefix(res.Header.Get("Content-Type"), tt.wantType) {
+				t.Errorf("Content-Type = %q, want %q", res.Header.Get("Content-Type"), tt.wantType)
+			}
+			if !strings.Contains(string(body), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", body, tt.wantBody)
+			}
+			if strings.Contains(string(body), "database") {
+				t.Errorf("body = %s, leaks the internal error", body)
+			}
+			if res.Header.Get(base.DefaultRequestIDHeader) == "" {
+				t.Errorf("reply has no %s header", base.DefaultRequestIDHeader)
+			}
+			if len(order) != 2 || order[0] != "outer" || order[1] != "inner" {
+				t.Errorf("middleware order = %v, want [outer inner]", order)
+			}
+		})
+	}
+
+	t.Run("error body request id", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/callback", strings.NewReader(`{"reference":"broken"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Api-Key", "k3y")
+		req.Header.Set(base.DefaultRequestIDHeader, "req-1")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		var eb ErrorBody
+		if err := json.NewDecoder(res.Body).Decode(&eb); err != nil || eb.RequestID != "req-1" {
+			t.Errorf("ErrorBody = %+v, %v, want request id req-1", eb, err)
+		}
+	})
+}
+
+func TestNewHandler_InvalidFunc(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NewHandler() with an invalid func should panic")
+		}
+	}()
+	NewHandler("invalid", func(cb *callback) error { return nil })
+}
+
+func TestServer_GracefulShutdown(t *testing.T) {
+	started := make(chan struct{})
+	srv := New("", WithLogger(io.Discard), WithShutdownTimeout(5*time.Second))
+	srv.HandleHTTP("/slow", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		_, _ = w.Write([]byte("done"))
+	}))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ctx, l) }()
+
+	result := make(chan string, 1)
+	go func() {
+		res, err := http.Get("http://" + l.Addr().String() + "/slow")
+		if err != nil {
+			result <- err.Error()
+			return
+		}
+		defer res.Body.Close()
+		b, _ := io.ReadAll(res.Body)
+		result <- string(b)
+	}()
+
+	<-started
+	cancel()
+	if got := <-result; got != "done" {
+		t.Errorf("request in flight = %q, want done", got)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("Serve() error = %v", err)
+	}
+}
+
+func TestHandler_Concurrent(t *testing.T) {
+	srv := New("", WithLogger(io.Discard),
+		WithReceiver(base.NewReceiver(io.Discard, true)),
+		WithReplier(base.NewReplier(io.Discard, true)))
+	srv.Handle("/callback", "callback", func(ctx context.Context, receipt *base.Receipt, cb *callback) (*ack, error) {
+		return &ack{Code: "0", Reference: cb.Reference}, nil
+	})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				res, err := http.Post(ts.URL+"/callback", "application/json", strings.NewReader(`{"reference":"r1"}`))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				_, _ = io.Copy(io.Discard, res.Body)
+				res.Body.Close()
+				if res.StatusCode != http.StatusOK {
+					t.Errorf("status = %d, want 200", res.StatusCode)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
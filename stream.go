@@ -0,0 +1,309 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package base
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	stdio "io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/techcraftlabs/base/trace"
+)
+
+const (
+	cTypeNDJSON    = "application/x-ndjson"
+	cTypeJSONLines = "application/jsonl"
+)
+
+// ErrNotJSONArray is returned by ElementDecoder.Next when a JSON response
+// is not an array
+var ErrNotJSONArray = errors.New("response body is not a json array")
+
+type (
+	// StreamResponse is returned by Client.Stream. Body must be closed, the
+	// request is only finished (traced, measured and passed to the exchange
+	// hooks) when it is.
+	StreamResponse struct {
+		HTTP       *http.Response
+		StatusCode int
+		HeaderMap  map[string]string
+		Body       stdio.ReadCloser
+		Error      error
+		RequestID  string
+	}
+
+	// ElementDecoder decodes the elements of a JSON array or the values of
+	// NDJSON (JSON lines) one at a time
+	ElementDecoder struct {
+		decoder *json.Decoder
+		ndjson  bool
+		started bool
+		done    bool
+	}
+
+	// streamBody finishes the request when the body is closed, it keeps the
+	// start of the body for the debug logs and the exchange hooks
+	streamBody struct {
+		stdio.ReadCloser
+		once    sync.Once
+		capture bool
+		limit   int
+		head    bytes.Buffer
+		err     error
+		finish  func(head []byte, err error)
+	}
+)
+
+// Stream sends the request like Do but does not read the response body, it
+// is returned as StreamResponse.Body so that large bodies such as statements
+// and reconciliation files are not held in memory. Use StreamResponse.Decoder
+// or StreamResponse.Each to decode JSON arrays and NDJSON element by element.
+// When the status code is 400 or above StreamResponse.Error is DoErr.
+//
+// The timeout of the http.Client (see WithTimeout) also bounds the time spent
+// reading the body.
+func (c *Client) Stream(ctx context.Context, request *Request, modifiers ...RequestModifier) (*StreamResponse, error) {
+	var (
+		rn           = request.Name
+		started      = time.Now()
+		requestID    string
+		req          *http.Request
+		reqBodyBytes []byte
+	)
+
+	ctx, requestID = withRequestID(ctx, request.RequestID)
+	ctx, span := c.getTracer().Start(ctx, rn, trace.SpanKindClient)
+	if c.metrics != nil {
+		c.metrics.RequestStarted(rn, request.MNO)
+	}
+
+	finish := func(res *http.Response, head []byte, err error) {
+		status := 0
+		if res != nil {
+			status = res.StatusCode
+			span.SetAttribute("http.status_code", status)
+		}
+		span.SetAttribute("http.method", request.Method)
+		span.SetAttribute("request_id", requestID)
+		if err != nil {
+			span.SetError(err)
+		}
+		span.End()
+
+		if c.metrics != nil {
+			c.metrics.RequestFinished(rn, request.Method, request.MNO, status, time.Since(started))
+		}
+		callHooks(c.hooks, &Exchange{
+			Kind:         ExchangeOutgoing,
+			Name:         rn,
+			RequestID:    requestID,
+			Request:      req,
+			RequestBody:  reqBodyBytes,
+			Response:     res,
+			ResponseBody: head,
+			Started:      started,
+			Duration:     time.Since(started),
+			Err:          err,
+		})
+		if c.DebugMode && req != nil {
+			req.Body = stdio.NopCloser(bytes.NewReader(reqBodyBytes))
+			if res != nil {
+				res.Body = stdio.NopCloser(bytes.NewReader(head))
+			}
+			c.logOut(logName(strings.ToUpper(rn), requestID)+" (STREAM)", req, res)
+		}
+	}
+
+	req, requestID, err := c.newHTTPRequest(ctx, request, requestID, modifiers...)
+	if err != nil {
+		finish(nil, nil, err)
+		return nil, err
+	}
+
+	if req.Body != nil {
+		reqBodyBytes, _ = stdio.ReadAll(req.Body)
+	}
+	req.Body = stdio.NopCloser(bytes.NewBuffer(reqBodyBytes))
+
+	if c.limiter != nil {
+		if err := c.limiter.Wait(ctx); err != nil {
+			finish(nil, nil, err)
+			return nil, err
+		}
+	}
+
+	res, err := c.Http.Do(req)
+	if err != nil {
+		finish(nil, nil, err)
+		return nil, err
+	}
+
+	headers := make(map[string]string)
+	for k, v := range res.Header {
+		headers[strings.ToLower(k)] = v[0]
+	}
+
+	body := &streamBody{
+		ReadCloser: res.Body,
+		capture:    c.DebugMode || len(c.hooks) > 0,
+		limit:      c.debugLimit,
+		finish: func(head []byte, err error) {
+			finish(res, head, err)
+		},
+	}
+
+	response := &StreamResponse{
+		HTTP:       res,
+		StatusCode: res.StatusCode,
+		HeaderMap:  headers,
+		Body:       body,
+		RequestID:  requestID,
+	}
+	if res.StatusCode >= errStatusCodeMargin {
+		response.Error = DoErr
+	}
+	return response, nil
+}
+
+func (b *streamBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if b.capture && n > 0 {
+		// one byte more than the limit tells the debug logs that the body
+		// has been truncated
+		keep := n
+		if b.limit > 0 {
+			if room := b.limit + 1 - b.head.Len(); room < keep {
+				keep = room
+			}
+		}
+		if keep > 0 {
+			b.head.Write(p[:keep])
+		}
+	}
+	if err != nil && !errors.Is(err, stdio.EOF) && b.err == nil {
+		b.err = err
+	}
+	return n, err
+}
+
+func (b *streamBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		b.finish(b.head.Bytes(), b.err)
+	})
+	return err
+}
+
+// Close closes the body
+func (s *StreamResponse) Close() error {
+	return s.Body.Close()
+}
+
+// Decoder returns an ElementDecoder of the body, it decodes NDJSON when the
+// Content-Type is application/x-ndjson or application/jsonl and the elements
+// of a JSON array otherwise
+func (s *StreamResponse) Decoder() *ElementDecoder {
+	return NewElementDecoder(s.Body, s.HeaderMap["content-type"])
+}
+
+// Each calls fn with every element of the body, newElem returns the value the
+// next element is decoded into. It stops at the first error returned by fn,
+// the body is not closed.
+func (s *StreamResponse) Each(newElem func() interface{}, fn func(elem interface{}) error) error {
+	decoder := s.Decoder()
+	for {
+		elem := newElem()
+		err := decoder.Next(elem)
+		if errors.Is(err, stdio.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(elem); err != nil {
+			return err
+		}
+	}
+}
+
+// NewElementDecoder returns an ElementDecoder that reads from reader. NDJSON
+// is expected when contentType is application/x-ndjson or application/jsonl,
+// a JSON array otherwise.
+func NewElementDecoder(reader stdio.Reader, contentType string) *ElementDecoder {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	return &ElementDecoder{
+		decoder: json.NewDecoder(reader),
+		ndjson:  mediaType == cTypeNDJSON || mediaType == cTypeJSONLines,
+	}
+}
+
+// Next decodes the next element into v, it returns io.EOF when there are
+// no more elements
+func (d *ElementDecoder) Next(v interface{}) error {
+	if d.done {
+		return stdio.EOF
+	}
+
+	if d.ndjson {
+		err := d.decoder.Decode(v)
+		if errors.Is(err, stdio.EOF) {
+			d.done = true
+		}
+		return err
+	}
+
+	if !d.started {
+		d.started = true
+		token, err := d.decoder.Token()
+		if errors.Is(err, stdio.EOF) {
+			d.done = true
+			return stdio.EOF
+		}
+		if err != nil {
+			return err
+		}
+		if delim, ok := token.(json.Delim); !ok || delim != '[' {
+			d.done = true
+			return fmt.Errorf("%w: starts with %v", ErrNotJSONArray, token)
+		}
+	}
+
+	if !d.decoder.More() {
+		d.done = true
+		if _, err := d.decoder.Token(); err != nil {
+			return err
+		}
+		return stdio.EOF
+	}
+	return d.decoder.Decode(v)
+}
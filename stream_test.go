@@ -0,0 +1,143 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package base
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	stdio "io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type statementLine struct {
+	ID     int    `json:"id"`
+	Amount string `json:"amount"`
+}
+
+func TestClient_Stream(t *testing.T) {
+	const lines = 1000
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ndjson := r.URL.Path == "/ndjson"
+		if ndjson {
+			w.Header().Set("Content-Type", cTypeNDJSON)
+		} else {
+			w.Header().Set("Content-Type", cTypeJson)
+			_, _ = fmt.Fprint(w, "[")
+		}
+		for i := 0; i < lines; i++ {
+			switch {
+			case ndjson:
+				_, _ = fmt.Fprintf(w, "{\"id\":%d,\"amount\":\"100.00\"}\n", i)
+			case i > 0:
+				_, _ = fmt.Fprint(w, ",")
+				fallthrough
+			default:
+				_, _ = fmt.Fprintf(w, "{\"id\":%d,\"amount\":\"100.00\"}", i)
+			}
+		}
+		if !ndjson {
+			_, _ = fmt.Fprint(w, "]")
+		}
+	}))
+	defer server.Close()
+
+	for _, path := range []string{"/array", "/ndjson"} {
+		t.Run(path, func(t *testing.T) {
+			var (
+				logs     bytes.Buffer
+				exchange *Exchange
+			)
+			client := NewClient(WithLogger(&logs), WithDebugBodyLimit(64),
+				WithExchangeHook(func(e *Exchange) { exchange = e }))
+
+			response, err := client.Stream(context.Background(), NewRequest("statement", http.MethodGet, server.URL+path, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			count := 0
+			err = response.Each(func() interface{} { return new(statementLine) }, func(elem interface{}) error {
+				if line := elem.(*statementLine); line.ID != count {
+					return fmt.Errorf("got line %d, want %d", line.ID, count)
+				}
+				count++
+				return nil
+			})
+			if err != nil || count != lines {
+				t.Fatalf("Each() = %v after %d lines, want %d lines", err, count, lines)
+			}
+
+			if exchange != nil {
+				t.Fatalf("exchange hook called before the body is closed")
+			}
+			_ = response.Close()
+			if exchange == nil || len(exchange.ResponseBody) != 65 {
+				t.Fatalf("exchange hook not called with the start of the body: %+v", exchange)
+			}
+			if !strings.Contains(logs.String(), "body truncated to 64 bytes") {
+				t.Errorf("debug log not truncated:\n%s", logs.String())
+			}
+		})
+	}
+}
+
+func TestElementDecoder(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    int
+		wantErr error
+	}{
+		{"empty array", "[]", 0, nil},
+		{"empty body", "", 0, nil},
+		{"object", `{"id":1}`, 0, ErrNotJSONArray},
+		{"array", `[{"id":1}, {"id":2}]`, 2, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoder := NewElementDecoder(strings.NewReader(tt.body), cTypeJson)
+			got := 0
+			var err error
+			for {
+				if err = decoder.Next(new(statementLine)); err != nil {
+					break
+				}
+				got++
+			}
+			if errors.Is(err, stdio.EOF) {
+				err = nil
+			}
+			if got != tt.want || !errors.Is(err, tt.wantErr) {
+				t.Errorf("decoded %d elements with error %v, want %d with %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+// Package trace implements W3C Trace Context (traceparent and tracestate
+// headers) propagation and a small Tracer interface used by base.Client and
+// base.Receiver to record spans.
+//
+// The default Tracer is Nop, it records nothing and only carries the span
+// context it is given so the headers of an incoming request are propagated to
+// the outgoing ones. NewLogTracer writes finished spans to an io.Writer.
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	TraceParentHeader = "traceparent"
+	TraceStateHeader  = "tracestate"
+)
+
+const (
+	SpanKindInternal SpanKind = iota
+	SpanKindClient
+	SpanKindServer
+)
+
+// FlagSampled is the sampled bit of the trace flags
+const FlagSampled byte = 0x01
+
+// ErrInvalidTraceParent is returned when a traceparent header can not be parsed
+var ErrInvalidTraceParent = errors.New("trace: invalid traceparent")
+
+type (
+	TraceID [16]byte
+	SpanID  [8]byte
+
+	SpanKind int
+
+	// SpanContext is the part of a span that is propagated between services
+	SpanContext struct {
+		TraceID    TraceID
+		SpanID     SpanID
+		Flags      byte
+		TraceState string
+		Remote     bool
+	}
+
+	// Span is a unit of work. SetError marks it as failed and End finishes it,
+	// calls after End are ignored
+	Span interface {
+		SpanContext() SpanContext
+		SetAttribute(key string, value interface{})
+		SetError(err error)
+		End()
+	}
+
+	// Tracer starts spans, the returned context carries the new span
+	Tracer interface {
+		Start(ctx context.Context, name string, kind SpanKind) (context.Context, Span)
+	}
+
+	spanContextKey struct{}
+)
+
+func (k SpanKind) String() string {
+	switch k {
+	case SpanKindClient:
+		return "client"
+	case SpanKindServer:
+		return "server"
+	}
+	return "internal"
+}
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// NewTraceID returns a random TraceID
+func NewTraceID() TraceID {
+	var id TraceID
+	for id == (TraceID{}) {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+// NewSpanID returns a random SpanID
+func NewSpanID() SpanID {
+	var id SpanID
+	for id == (SpanID{}) {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+// IsValid reports whether both IDs are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// TraceParent formats the span context as a version 00 traceparent header value
+func (sc SpanContext) TraceParent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, sc.Flags)
+}
+
+// ParseTraceParent parses a traceparent header value
+func ParseTraceParent(value string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return SpanContext{}, ErrInvalidTraceParent
+	}
+	// version 00 has exactly 4 fields, future versions may append more
+	if parts[0] == "00" && len(parts) != 4 {
+		return SpanContext{}, ErrInvalidTraceParent
+	}
+
+	var (
+		sc    SpanContext
+		flags [1]byte
+	)
+	if err := decodeHex(sc.TraceID[:], parts[1]); err != nil {
+		return SpanContext{}, err
+	}
+	if err := decodeHex(sc.SpanID[:], parts[2]); err != nil {
+		return SpanContext{}, err
+	}
+	if err := decodeHex(flags[:], parts[3]); err != nil {
+		return SpanContext{}, err
+	}
+	sc.Flags = flags[0]
+	sc.Remote = true
+
+	if !sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceParent
+	}
+	return sc, nil
+}
+
+func decodeHex(dst []byte, s string) error {
+	if len(s) != hex.EncodedLen(len(dst)) || strings.ToLower(s) != s {
+		return ErrInvalidTraceParent
+	}
+	if _, err := hex.Decode(dst, []byte(s)); err != nil {
+		return ErrInvalidTraceParent
+	}
+	return nil
+}
+
+// ContextWithSpanContext returns a copy of ctx that carries sc
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the SpanContext carried by ctx
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok && sc.IsValid()
+}
+
+// Inject sets the traceparent and tracestate headers from the span context of ctx
+func Inject(ctx context.Context, header http.Header) {
+	sc, ok := SpanContextFromContext(ctx)
+	if !ok {
+		return
+	}
+	header.Set(TraceParentHeader, sc.TraceParent())
+	if sc.TraceState != "" {
+		header.Set(TraceStateHeader, sc.TraceState)
+	}
+}
+
+// Extract reads the traceparent and tracestate headers and returns a copy of
+// ctx that carries the remote span context. ctx is returned unchanged when
+// there is no valid traceparent header.
+func Extract(ctx context.Context, header http.Header) (context.Context, SpanContext, bool) {
+	sc, err := ParseTraceParent(header.Get(TraceParentHeader))
+	if err != nil {
+		return ctx, SpanContext{}, false
+	}
+	sc.TraceState = strings.Join(header.Values(TraceStateHeader), ",")
+	return ContextWithSpanContext(ctx, sc), sc, true
+}
@@ -0,0 +1,109 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package trace
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{"valid", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"future version with more fields", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"version 00 with more fields", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true},
+		{"invalid version", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"zero trace id", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", true},
+		{"upper case", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", true},
+		{"short span id", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902-01", true},
+		{"empty", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := ParseTraceParent(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTraceParent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !errors.Is(err, ErrInvalidTraceParent) {
+					t.Errorf("ParseTraceParent() error = %v, want ErrInvalidTraceParent", err)
+				}
+				return
+			}
+			// always formatted as version 00
+			if got, want := sc.TraceParent(), "00"+tt.value[2:55]; got != want {
+				t.Errorf("TraceParent() = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	incoming := http.Header{}
+	incoming.Set(TraceParentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	incoming.Set(TraceStateHeader, "congo=t61rcWkgMzE")
+
+	ctx, remote, ok := Extract(context.Background(), incoming)
+	if !ok || !remote.Remote {
+		t.Fatalf("Extract() = %+v, %v", remote, ok)
+	}
+
+	// Nop propagates the remote span context unchanged
+	_, span := Nop{}.Start(ctx, "nop", SpanKindServer)
+	if span.SpanContext() != remote {
+		t.Errorf("Nop span context = %+v, want %+v", span.SpanContext(), remote)
+	}
+
+	var buf bytes.Buffer
+	ctx, span = NewLogTracer(&buf).Start(ctx, "push", SpanKindClient)
+	span.SetAttribute("http.status_code", 200)
+	span.End()
+	span.End()
+
+	outgoing := http.Header{}
+	Inject(ctx, outgoing)
+	sc := span.SpanContext()
+	if outgoing.Get(TraceParentHeader) != sc.TraceParent() || sc.TraceID != remote.TraceID || sc.SpanID == remote.SpanID {
+		t.Errorf("Inject() traceparent = %s, remote = %s", outgoing.Get(TraceParentHeader), remote.TraceParent())
+	}
+	if outgoing.Get(TraceStateHeader) != "congo=t61rcWkgMzE" {
+		t.Errorf("Inject() tracestate = %s", outgoing.Get(TraceStateHeader))
+	}
+
+	line := buf.String()
+	if strings.Count(line, "\n") != 1 || !strings.Contains(line, "parent_id=00f067aa0ba902b7") ||
+		!strings.Contains(line, "http.status_code=200") {
+		t.Errorf("unexpected span log: %q", line)
+	}
+}
@@ -0,0 +1,168 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package trace
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	_ Tracer = Nop{}
+	_ Tracer = (*LogTracer)(nil)
+)
+
+type (
+	// Nop is a Tracer that records nothing. Its spans carry the span context
+	// of the parent so that the trace headers are still propagated.
+	Nop struct{}
+
+	nopSpan struct {
+		sc SpanContext
+	}
+
+	// LogTracer writes a line for every finished span to a writer
+	LogTracer struct {
+		mu     sync.Mutex
+		writer io.Writer
+	}
+
+	logSpan struct {
+		tracer *LogTracer
+		name   string
+		kind   SpanKind
+		sc     SpanContext
+		parent SpanID
+		start  time.Time
+
+		mu    sync.Mutex
+		attrs map[string]interface{}
+		err   error
+		ended bool
+	}
+)
+
+func (Nop) Start(ctx context.Context, _ string, _ SpanKind) (context.Context, Span) {
+	sc, _ := SpanContextFromContext(ctx)
+	return ctx, nopSpan{sc: sc}
+}
+
+func (s nopSpan) SpanContext() SpanContext { return s.sc }
+
+func (nopSpan) SetAttribute(string, interface{}) {}
+
+func (nopSpan) SetError(error) {}
+
+func (nopSpan) End() {}
+
+// NewLogTracer returns a Tracer that writes finished spans to writer
+func NewLogTracer(writer io.Writer) *LogTracer {
+	return &LogTracer{writer: writer}
+}
+
+// Start starts a child span of the span carried by ctx, or the root span of
+// a new sampled trace when there is none
+func (t *LogTracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, Span) {
+	span := &logSpan{
+		tracer: t,
+		name:   name,
+		kind:   kind,
+		start:  time.Now(),
+		attrs:  make(map[string]interface{}),
+	}
+
+	if parent, ok := SpanContextFromContext(ctx); ok {
+		span.sc = SpanContext{
+			TraceID:    parent.TraceID,
+			Flags:      parent.Flags,
+			TraceState: parent.TraceState,
+		}
+		span.parent = parent.SpanID
+	} else {
+		span.sc = SpanContext{TraceID: NewTraceID(), Flags: FlagSampled}
+	}
+	span.sc.SpanID = NewSpanID()
+
+	return ContextWithSpanContext(ctx, span.sc), span
+}
+
+func (s *logSpan) SpanContext() SpanContext {
+	return s.sc
+}
+
+func (s *logSpan) SetAttribute(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs[key] = value
+}
+
+func (s *logSpan) SetError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+func (s *logSpan) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	duration := time.Since(s.start)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "span name=%q kind=%s trace_id=%s span_id=%s", s.name, s.kind, s.sc.TraceID, s.sc.SpanID)
+	if s.parent != (SpanID{}) {
+		fmt.Fprintf(&b, " parent_id=%s", s.parent)
+	}
+	fmt.Fprintf(&b, " duration=%s", duration)
+	if s.err != nil {
+		fmt.Fprintf(&b, " status=error error=%q", s.err.Error())
+	} else {
+		b.WriteString(" status=ok")
+	}
+
+	keys := make([]string, 0, len(s.attrs))
+	for key := range s.attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&b, " %s=%v", key, s.attrs[key])
+	}
+	b.WriteString("\n")
+	s.mu.Unlock()
+
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	_, _ = io.WriteString(s.tracer.writer, b.String())
+}
@@ -0,0 +1,111 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package base
+
+import (
+	"context"
+)
+
+type (
+	// TypedRequest is a Request whose payload is a T
+	TypedRequest[T any] struct {
+		*Request
+	}
+
+	// TypedRequestBuilder is a RequestBuilder whose payload is a T
+	TypedRequestBuilder[T any] struct {
+		rb *RequestBuilder
+	}
+
+	// TypedResponse is the Response of DoTyped. Body is the decoded body of
+	// a response with a status code below 400 and ErrorBody the one of the
+	// other responses, the other is nil. Response.Body holds the same pointer.
+	TypedResponse[Resp, ErrResp any] struct {
+		*Response
+		Body      *Resp
+		ErrorBody *ErrResp
+	}
+)
+
+// NewTypedRequest is NewRequest with a payload of type T
+func NewTypedRequest[T any](name, method, url string, payload T, opts ...RequestOption) *TypedRequest[T] {
+	return &TypedRequest[T]{Request: NewRequest(name, method, url, payload, opts...)}
+}
+
+// TypedPayload returns the payload of the request, the zero value of T when
+// the payload was replaced by one of another type
+func (r *TypedRequest[T]) TypedPayload() T {
+	payload, _ := r.Payload.(T)
+	return payload
+}
+
+// NewTypedRequestBuilder is NewRequestBuilder for payloads of type T
+func NewTypedRequestBuilder[T any](name, method, basePath string) *TypedRequestBuilder[T] {
+	return &TypedRequestBuilder[T]{rb: NewRequestBuilder(name, method, basePath)}
+}
+
+func (b *TypedRequestBuilder[T]) Payload(payload T) *TypedRequestBuilder[T] {
+	b.rb.Payload(payload)
+	return b
+}
+
+func (b *TypedRequestBuilder[T]) Headers(m map[string]string) *TypedRequestBuilder[T] {
+	b.rb.Headers(m)
+	return b
+}
+
+func (b *TypedRequestBuilder[T]) BasicAuth(auth *BasicAuth) *TypedRequestBuilder[T] {
+	b.rb.BasicAuth(auth)
+	return b
+}
+
+func (b *TypedRequestBuilder[T]) QueryParams(params map[string]string) *TypedRequestBuilder[T] {
+	b.rb.QueryParams(params)
+	return b
+}
+
+func (b *TypedRequestBuilder[T]) Endpoint(endpoint string) *TypedRequestBuilder[T] {
+	b.rb.Endpoint(endpoint)
+	return b
+}
+
+func (b *TypedRequestBuilder[T]) Build() *TypedRequest[T] {
+	return &TypedRequest[T]{Request: b.rb.Build()}
+}
+
+// DoTyped is Client.Do with typed bodies: a response with a status code
+// below 400 is decoded into a new Resp, the others into a new ErrResp. Empty
+// and 204 bodies are not decoded, Body and ErrorBody are nil then. An error
+// body that is not JSON or XML, e.g. the HTML page of a gateway, leaves
+// ErrorBody nil and is reported in Response.Error. The payload type is
+// inferred from request:
+//
+//	response, err := base.DoTyped[PaymentResponse, GatewayError](ctx, client, request)
+//	if response.ErrorBody != nil { ... }
+func DoTyped[Resp, ErrResp, Req any](ctx context.Context, c *Client, request *TypedRequest[Req],
+	modifiers ...RequestModifier) (*TypedResponse[Resp, ErrResp], error) {
+	return doTyped[Resp, ErrResp](ctx, c, request.Request, new(Resp), new(ErrResp), false, modifiers...)
+}
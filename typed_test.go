@@ -0,0 +1,159 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) 2021 TECHCRAFT TECHNOLOGIES CO LTD.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ *
+ */
+
+package base
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDoTyped(t *testing.T) {
+	type (
+		payment struct {
+			Amount int `json:"amount"`
+		}
+		receipt struct {
+			Reference string `json:"reference"`
+		}
+		gatewayError struct {
+			Code string `json:"code"`
+		}
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case "/gateway":
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = io.WriteString(w, "<html><body>502 Bad Gateway</body></html>")
+			return
+		}
+		p := new(payment)
+		_ = json.NewDecoder(r.Body).Decode(p)
+		w.Header().Set("Content-Type", "application/json")
+		if p.Amount <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = io.WriteString(w, `{"code":"invalid_amount"}`)
+			return
+		}
+		_, _ = io.WriteString(w, `{"reference":"ref-1"}`)
+	}))
+	defer server.Close()
+
+	client := NewClient(WithDebugMode(false))
+	tests := []struct {
+		name     string
+		request  *TypedRequest[payment]
+		wantRef  string
+		wantCode string
+	}{
+		{"success", NewTypedRequest("payment", http.MethodPost, server.URL, payment{Amount: 100}), "ref-1", ""},
+		{"error body", NewTypedRequestBuilder[payment]("payment", http.MethodPost, server.URL).
+			Headers(map[string]string{"Content-Type": "application/json"}).
+			Payload(payment{Amount: 0}).Build(), "", "invalid_amount"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := DoTyped[receipt, gatewayError](context.Background(), client, tt.request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantRef != "" && (response.Body == nil || response.Body.Reference != tt.wantRef || response.ErrorBody != nil) {
+				t.Errorf("Body = %+v, ErrorBody = %+v, want reference %q", response.Body, response.ErrorBody, tt.wantRef)
+			}
+			if tt.wantCode != "" && (response.ErrorBody == nil || response.ErrorBody.Code != tt.wantCode || response.Error != DoErr) {
+				t.Errorf("ErrorBody = %+v, Error = %v, want code %q", response.ErrorBody, response.Error, tt.wantCode)
+			}
+			if tt.request.TypedPayload().Amount != tt.request.Payload.(payment).Amount {
+				t.Errorf("TypedPayload() = %+v", tt.request.TypedPayload())
+			}
+		})
+	}
+
+	t.Run("no content", func(t *testing.T) {
+		response, err := DoTyped[receipt, gatewayError](context.Background(), client,
+			NewTypedRequest("payment", http.MethodPost, server.URL+"/empty", payment{Amount: 1}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.StatusCode != http.StatusNoContent || response.Body != nil || response.ErrorBody != nil || response.Error != nil {
+			t.Errorf("response = %+v, want a 204 without bodies", response)
+		}
+	})
+
+	t.Run("undecodable error body", func(t *testing.T) {
+		response, err := DoTyped[receipt, gatewayError](context.Background(), client,
+			NewTypedRequest("payment", http.MethodPost, server.URL+"/gateway", payment{Amount: 1}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.StatusCode != http.StatusBadGateway || response.ErrorBody != nil || !errors.Is(response.Error, DoErr) {
+			t.Errorf("response = %+v, Error = %v, want a 502 with DoErr", response, response.Error)
+		}
+	})
+
+	// Do delegates to the typed implementation and keeps decoding into body
+	body := new(receipt)
+	response, err := client.Do(context.Background(), NewRequest("payment", http.MethodPost, server.URL, payment{Amount: 1}), body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body.Reference != "ref-1" || response.Body != body {
+		t.Errorf("Do() Body = %+v, decoded %+v", response.Body, body)
+	}
+}
+
+func TestClient_Do_NonPointerBody(t *testing.T) {
+	type receipt struct {
+		Reference string `json:"reference"`
+	}
+	sent := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = true
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"reference":"ref-1"}`)
+	}))
+	defer server.Close()
+
+	client := NewClient(WithDebugMode(false))
+	request := NewRequest("payment", http.MethodPost, server.URL, nil)
+	for _, body := range []interface{}{receipt{}, (*receipt)(nil)} {
+		if _, err := client.Do(context.Background(), request, body); !errors.Is(err, ErrInvalidBody) {
+			t.Errorf("Do(%T) error = %v, want %v", body, err, ErrInvalidBody)
+		}
+	}
+	if sent {
+		t.Error("Do() sent a request with an invalid body")
+	}
+}